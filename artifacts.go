@@ -0,0 +1,413 @@
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tests
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/onsi/ginkgo"
+)
+
+const (
+	// artifactsJournalLines is the number of lines
+	// kept from the journal of each unit
+	artifactsJournalLines = 500
+
+	// artifactsDmesgLines is the number of lines kept from dmesg
+	artifactsDmesgLines = 200
+
+	// artifactsNameLen is the maximum length of the
+	// spec text in the name of the artifacts directories
+	artifactsNameLen = 100
+)
+
+// artifactsJournals are the journalctl arguments selecting the
+// entries of each component, cc-shim logs to syslog
+var artifactsJournals = []struct {
+	name string
+	args []string
+}{
+	{"docker", []string{"-u", "docker"}},
+	{"cc-proxy", []string{"-u", "cc-proxy"}},
+	{"cc-shim", []string{"-t", "cc-shim"}},
+}
+
+// suiteStart is the time from which the journals are collected
+var suiteStart = time.Now()
+
+// artifactsNameRegexp matches the characters replaced
+// in the names of the artifacts directories
+var artifactsNameRegexp = regexp.MustCompile(`[^A-Za-z0-9_.-]+`)
+
+// liveBundles are the bundles not removed yet and the spec that
+// created them, the bundles of the spec that fails are collected
+// with its artifacts
+var liveBundles = struct {
+	sync.Mutex
+	bundles map[*Bundle]string
+}{
+	bundles: make(map[*Bundle]string),
+}
+
+// liveSessions are the sessions not closed yet and the spec that
+// started them, the output of the terminal of the sessions of the
+// spec that fails is collected with its artifacts
+var liveSessions = struct {
+	sync.Mutex
+	sessions map[*Session]string
+}{
+	sessions: make(map[*Session]string),
+}
+
+// lastCollected is the spec whose artifacts were collected last, the
+// failures of its AfterEach blocks do not collect them again
+var lastCollected struct {
+	sync.Mutex
+	spec string
+}
+
+func registerBundle(b *Bundle) {
+	liveBundles.Lock()
+	defer liveBundles.Unlock()
+
+	liveBundles.bundles[b] = currentSpec()
+}
+
+func unregisterBundle(b *Bundle) {
+	liveBundles.Lock()
+	defer liveBundles.Unlock()
+
+	delete(liveBundles.bundles, b)
+}
+
+func registerSession(s *Session) {
+	liveSessions.Lock()
+	defer liveSessions.Unlock()
+
+	liveSessions.sessions[s] = currentSpec()
+}
+
+func unregisterSession(s *Session) {
+	liveSessions.Lock()
+	defer liveSessions.Unlock()
+
+	delete(liveSessions.sessions, s)
+}
+
+// FailWithArtifacts is a ginkgo fail handler that collects the artifacts
+// of the failed spec in a directory of ArtifactsDir before failing it,
+// the artifacts are collected before AfterEach removes the bundles and
+// the docker objects. Suites register it instead of ginkgo.Fail:
+//
+//	RegisterFailHandler(FailWithArtifacts)
+func FailWithArtifacts(message string, callerSkip ...int) {
+	skip := 0
+	if len(callerSkip) > 0 {
+		skip = callerSkip[0]
+	}
+
+	collectSpecArtifacts(message)
+
+	ginkgo.Fail(message, skip+1)
+}
+
+// currentSpec returns the location and the text of the running
+// spec, empty when no spec is running
+func currentSpec() (spec string) {
+	// ginkgo panics when no suite is running, e.g. in the unit tests
+	defer func() {
+		if recover() != nil {
+			spec = ""
+		}
+	}()
+
+	desc := ginkgo.CurrentGinkgoTestDescription()
+	if desc.FullTestText == "" {
+		return ""
+	}
+
+	return fmt.Sprintf("%s:%d %s", desc.FileName, desc.LineNumber, desc.FullTestText)
+}
+
+func collectSpecArtifacts(message string) {
+	if ArtifactsDir == "" {
+		return
+	}
+
+	desc := ginkgo.CurrentGinkgoTestDescription()
+	spec := currentSpec()
+
+	lastCollected.Lock()
+	collected := lastCollected.spec == spec
+	lastCollected.spec = spec
+	lastCollected.Unlock()
+
+	if collected {
+		return
+	}
+
+	dir := filepath.Join(ArtifactsDir, artifactsDirName(desc.FullTestText, time.Now()))
+	message = fmt.Sprintf("%s\n\n%s\n%s:%d\n", desc.FullTestText, message, desc.FileName, desc.LineNumber)
+
+	if err := collectArtifacts(dir, spec, message); err != nil {
+		LogIfFail("Failed to collect the artifacts in %s: %v\n", dir, err)
+		return
+	}
+
+	LogIfFail("Artifacts of the failure collected in %s\n", dir)
+}
+
+// artifactsDirName returns the name of the artifacts directory of the spec
+func artifactsDirName(spec string, t time.Time) string {
+	name := strings.Trim(artifactsNameRegexp.ReplaceAllString(spec, "-"), "-")
+	if len(name) > artifactsNameLen {
+		name = name[:artifactsNameLen]
+	}
+
+	if name == "" {
+		name = "spec"
+	}
+
+	return fmt.Sprintf("%s-%s", name, t.Format("20060102-150405.000"))
+}
+
+// collectArtifacts writes in dir the failure message, the files of the
+// bundles of the spec (config.json and the runtime log), the output of
+// the sessions of the spec, the inspection of the tracked containers,
+// the journals, the process tree and dmesg. The artifacts that cannot
+// be collected are listed in errors.txt, only the errors creating dir
+// are returned
+func collectArtifacts(dir, spec, message string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	var errs []string
+	collect := func(what string, err error) {
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", what, err))
+		}
+	}
+
+	collect("failure", writeArtifact(dir, "failure.txt", []byte(message)))
+	collect("bundles", collectBundleArtifacts(filepath.Join(dir, "bundles"), spec))
+	collect("sessions", collectSessionArtifacts(dir, spec))
+	collect("docker", collectDockerArtifacts(filepath.Join(dir, "docker")))
+
+	for _, j := range artifactsJournals {
+		args := append([]string{"--no-pager", "-o", "short-precise",
+			"--since", suiteStart.Format("2006-01-02 15:04:05"),
+			"-n", fmt.Sprint(artifactsJournalLines)}, j.args...)
+		collect("journal "+j.name, collectCommandArtifact(dir, "journal-"+j.name+".txt", 0, "journalctl", args...))
+	}
+
+	collect("processes", collectCommandArtifact(dir, "processes.txt", 0,
+		"ps", "-eo", "pid,ppid,pgid,stat,lstart,args", "--forest"))
+	collect("dmesg", collectCommandArtifact(dir, "dmesg.txt", artifactsDmesgLines, "dmesg"))
+
+	if len(errs) > 0 {
+		return writeArtifact(dir, "errors.txt", []byte(strings.Join(errs, "\n")+"\n"))
+	}
+
+	return nil
+}
+
+func writeArtifact(dir, name string, content []byte) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(filepath.Join(dir, name), content, 0644)
+}
+
+// collectBundleArtifacts copies the regular files of the top directory
+// of the live bundles created by spec, the rootfs is not copied
+func collectBundleArtifacts(dir, spec string) error {
+	liveBundles.Lock()
+	var bundles []*Bundle
+	for b, bundleSpec := range liveBundles.bundles {
+		if bundleSpec == spec {
+			bundles = append(bundles, b)
+		}
+	}
+	liveBundles.Unlock()
+
+	var errs []string
+	for _, b := range bundles {
+		files, err := ioutil.ReadDir(b.Path)
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+
+		bundleDir := filepath.Join(dir, filepath.Base(b.Path))
+		for _, f := range files {
+			if !f.Mode().IsRegular() {
+				continue
+			}
+
+			if err := copyArtifact(filepath.Join(b.Path, f.Name()), filepath.Join(bundleDir, f.Name())); err != nil {
+				errs = append(errs, err.Error())
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+
+	return nil
+}
+
+func copyArtifact(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+
+	return out.Close()
+}
+
+// collectSessionArtifacts writes the command and the output of the
+// terminal of the live sessions started by spec in sessions.txt
+func collectSessionArtifacts(dir, spec string) error {
+	liveSessions.Lock()
+	var sessions []*Session
+	for s, sessionSpec := range liveSessions.sessions {
+		if sessionSpec == spec {
+			sessions = append(sessions, s)
+		}
+	}
+	liveSessions.Unlock()
+
+	if len(sessions) == 0 {
+		return nil
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].start.Before(sessions[j].start)
+	})
+
+	var content bytes.Buffer
+	for _, s := range sessions {
+		fmt.Fprintf(&content, "$ %s\n%s\n\n", strings.Join(s.cmd.Args, " "), s.Transcript())
+	}
+
+	return writeArtifact(dir, "sessions.txt", content.Bytes())
+}
+
+// collectDockerArtifacts writes the inspection of the containers
+// recorded by the trackers not cleaned up yet
+func collectDockerArtifacts(dir string) error {
+	names := trackedDockerObjects(dockerContainerType)
+	if len(names) == 0 {
+		return nil
+	}
+
+	backend := CurrentDockerBackend()
+
+	var errs []string
+	for _, name := range names {
+		content, err := backend.Inspect(dockerContainerType, name)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", name, err))
+			continue
+		}
+
+		if err := writeArtifact(dir, name+".json", content); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+
+	return nil
+}
+
+// collectCommandArtifact writes the output of the command in dir/name,
+// only the last lines are kept when lines is not 0. The command is not
+// run with NewCommand to keep it out of the spec output and transcript,
+// its time limit is the one of the artifacts operation
+func collectCommandArtifact(dir, name string, lines int, path string, args ...string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), OperationTimeout("artifacts"))
+	defer cancel()
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, path, args...)
+	cmd.Stderr = &stderr
+
+	out, err := cmd.Output()
+	stdout := string(out)
+
+	if lines > 0 {
+		stdout = tailLines(stdout, lines)
+	}
+
+	if stdout != "" {
+		if writeErr := writeArtifact(dir, name, []byte(stdout)); writeErr != nil {
+			return writeErr
+		}
+	}
+
+	if err != nil {
+		return fmt.Errorf("%s: %v: %s", path, err, strings.TrimSpace(stderr.String()))
+	}
+
+	return nil
+}
+
+// tailLines returns the last n lines of s
+func tailLines(s string, n int) string {
+	s = strings.TrimRight(s, "\n")
+	if s == "" {
+		return ""
+	}
+
+	lines := strings.Split(s, "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+
+	return strings.Join(lines, "\n") + "\n"
+}
@@ -0,0 +1,95 @@
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tests
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestArtifactsDirName(t *testing.T) {
+	assert := assert.New(t)
+
+	now := time.Date(2017, 10, 2, 15, 4, 5, 6000000, time.UTC)
+
+	assert.Equal("run-a-container-with-.-and-slashes-20171002-150405.006",
+		artifactsDirName("run a container, with ./ and slashes", now))
+	assert.Equal("spec-20171002-150405.006", artifactsDirName(" /// ", now))
+	assert.Len(artifactsDirName(strings.Repeat("a", 500), now), artifactsNameLen+len("-20171002-150405.006"))
+}
+
+func TestTailLines(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("", tailLines("", 2))
+	assert.Equal("b\nc\n", tailLines("a\nb\nc\n", 2))
+	assert.Equal("a\nb\n", tailLines("a\nb", 5))
+}
+
+func TestCollectArtifacts(t *testing.T) {
+	assert := assert.New(t)
+
+	c := newTestContainer(t, "true")
+	assert.NoError(ioutil.WriteFile(*c.LogFile, []byte("level=error msg=failed\n"), 0644))
+
+	removed := newTestContainer(t, "true")
+	assert.NoError(removed.Teardown())
+
+	// a bundle of another spec
+	other := newTestContainer(t, "true")
+	defer other.Teardown()
+	liveBundles.Lock()
+	liveBundles.bundles[other.Bundle] = "other_test.go:42 other spec"
+	liveBundles.Unlock()
+
+	dir, err := ioutil.TempDir(testDir, "artifacts")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	assert.NoError(collectArtifacts(filepath.Join(dir, "spec"), "", "spec failed\n"))
+
+	content, err := ioutil.ReadFile(filepath.Join(dir, "spec", "failure.txt"))
+	assert.NoError(err)
+	assert.Equal("spec failed\n", string(content))
+
+	bundleDir := filepath.Join(dir, "spec", "bundles", filepath.Base(c.Bundle.Path))
+	content, err = ioutil.ReadFile(filepath.Join(bundleDir, "log"))
+	assert.NoError(err)
+	assert.Equal("level=error msg=failed\n", string(content))
+
+	_, err = os.Stat(filepath.Join(bundleDir, "config.json"))
+	assert.NoError(err)
+
+	// the rootfs is not collected
+	_, err = os.Stat(filepath.Join(bundleDir, "rootfs"))
+	assert.True(os.IsNotExist(err))
+
+	// the removed bundles and the ones of other specs are not collected
+	_, err = os.Stat(filepath.Join(dir, "spec", "bundles", filepath.Base(removed.Bundle.Path)))
+	assert.True(os.IsNotExist(err))
+	_, err = os.Stat(filepath.Join(dir, "spec", "bundles", filepath.Base(other.Bundle.Path)))
+	assert.True(os.IsNotExist(err))
+
+	_, err = os.Stat(filepath.Join(dir, "spec", "processes.txt"))
+	assert.NoError(err)
+
+	assert.NoError(c.Teardown())
+}
@@ -15,24 +15,18 @@
 package tests
 
 import (
-	"bytes"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strings"
 
 	spec "github.com/opencontainers/specs/specs-go"
 )
 
-const image = "busybox"
-
 const tmpDir = "/tmp"
 
-const configTemplate = "src/github.com/clearcontainers/tests/data/config.json"
-
 // Bundle represents the root directory where config.json and rootfs are
 type Bundle struct {
 	// Config represents the config.json
@@ -40,100 +34,146 @@ type Bundle struct {
 
 	// Path to the bundle
 	Path string
+
+	// rootfsMounted is true if the rootfs is an overlay mount
+	// that must be unmounted before removing the bundle
+	rootfsMounted bool
 }
 
 // NewBundle creates a new bundle
+// the rootfs is exported from the docker image
 func NewBundle(workload []string) (*Bundle, error) {
-	path, err := ioutil.TempDir(tmpDir, "bundle")
-	if err != nil {
-		return nil, err
+	if !rootfsCacheEnabled {
+		return newBundle(workload, createRootfs)
 	}
 
-	if err := createRootfs(path); err != nil {
-		return nil, err
-	}
+	return newBundle(workload, func(b *Bundle) error {
+		key, err := dockerImageDigest(Image)
+		if err != nil {
+			return err
+		}
 
-	gopath := os.Getenv("GOPATH")
-	if gopath == "" {
-		return nil, fmt.Errorf("GOPATH is not set")
-	}
+		return rootfsCache.populate(b, key, exportImage)
+	})
+}
 
-	configTemplatePath := filepath.Join(gopath, configTemplate)
-	content, err := ioutil.ReadFile(configTemplatePath)
+// newBundle creates a new bundle using createRootfs to populate
+// the rootfs directory inside the bundle path
+func newBundle(workload []string, createRootfs func(b *Bundle) error) (*Bundle, error) {
+	dir, err := NodeDir()
 	if err != nil {
 		return nil, err
 	}
 
-	var config spec.Spec
-	err = json.Unmarshal(content, &config)
+	path, err := ioutil.TempDir(dir, "bundle")
 	if err != nil {
 		return nil, err
 	}
 
-	config.Process.Args = workload
-
 	bundle := &Bundle{
-		Path:   path,
-		Config: &config,
+		Path: path,
 	}
 
-	err = bundle.Save()
-	if err != nil {
+	if err := bundle.init(workload, createRootfs); err != nil {
+		bundle.Remove()
 		return nil, err
 	}
 
+	registerBundle(bundle)
+
 	return bundle, nil
 }
 
-// createRootfs creates a rootfs in the specific bundlePath
-func createRootfs(bundlePath string) error {
-	if bundlePath == "" {
-		return fmt.Errorf("bundle path should not be empty")
+func (b *Bundle) init(workload []string, createRootfs func(b *Bundle) error) error {
+	if err := createRootfs(b); err != nil {
+		return err
 	}
 
-	rootfsDir := filepath.Join(bundlePath, "rootfs")
-	if err := os.MkdirAll(rootfsDir, 0755); err != nil {
+	config, err := newConfig()
+	if err != nil {
 		return err
 	}
 
-	// create container
-	var container bytes.Buffer
-	createCmd := exec.Command("docker", "create", image)
-	createCmd.Stdout = &container
-	if err := createCmd.Run(); err != nil {
+	config.Process.Args = workload
+	b.Config = config
+
+	return b.ValidateAndSave()
+}
+
+// createRootfs creates a rootfs in the bundle path exporting the docker image
+func createRootfs(b *Bundle) error {
+	rootfsDir, err := mkRootfsDir(b.Path)
+	if err != nil {
 		return err
 	}
-	containerName := strings.TrimRight(container.String(), "\n")
+
+	return exportImage(rootfsDir)
+}
+
+// dockerImageDigest returns the digest of the docker image
+func dockerImageDigest(image string) (string, error) {
+	inspectCmd := NewCommand(Docker, "inspect", "--format", "{{.Id}}", image)
+	inspectCmd.Timeout = OperationTimeout("docker-inspect")
+	result := inspectCmd.RunResult()
+	if result.ExitCode != 0 {
+		return "", fmt.Errorf("failed to inspect %s: %s", image, result.Stderr)
+	}
+
+	return strings.TrimSpace(result.Stdout), nil
+}
+
+// exportImage extracts the filesystem of the docker image in rootfsDir,
+// the commands are run with NewCommand to show up in the transcripts
+func exportImage(rootfsDir string) (err error) {
+	// create container
+	createCmd := NewCommand(Docker, "create", Image)
+	createCmd.Timeout = OperationTimeout("docker-create")
+	result := createCmd.RunResult()
+	if result.ExitCode != 0 {
+		return fmt.Errorf("failed to create a container of %s: %s", Image, result.Stderr)
+	}
+	containerName := strings.TrimRight(result.Stdout, "\n")
+
+	// remove container
+	defer func() {
+		rmCmd := NewCommand(Docker, "rm", "-f", containerName)
+		rmCmd.Timeout = OperationTimeout("docker-rm")
+		if rmResult := rmCmd.RunResult(); rmResult.ExitCode != 0 && err == nil {
+			err = fmt.Errorf("failed to remove %s: %s", containerName, rmResult.Stderr)
+		}
+	}()
 
 	// export container
-	tarFile, err := ioutil.TempFile(tmpDir, "tar")
+	dir, err := NodeDir()
 	if err != nil {
 		return err
 	}
-	defer tarFile.Close()
 
-	exportCmd := exec.Command("docker", "export", "-o", tarFile.Name(), containerName)
-	if err := exportCmd.Run(); err != nil {
+	tarFile, err := ioutil.TempFile(dir, "tar")
+	if err != nil {
 		return err
 	}
 	defer os.Remove(tarFile.Name())
+	defer tarFile.Close()
 
-	// extract container
-	tarCmd := exec.Command("tar", "-C", rootfsDir, "-pxf", tarFile.Name())
-	if err := tarCmd.Run(); err != nil {
-		return err
+	exportCmd := NewCommand(Docker, "export", "-o", tarFile.Name(), containerName)
+	exportCmd.Timeout = OperationTimeout("docker-export")
+	if result := exportCmd.RunResult(); result.ExitCode != 0 {
+		return fmt.Errorf("failed to export %s: %s", containerName, result.Stderr)
 	}
 
-	// remove container
-	rmCmd := exec.Command("docker", "rm", "-f", containerName)
-	if err := rmCmd.Run(); err != nil {
-		return err
+	// extract container
+	tarCmd := NewCommand("tar", "-C", rootfsDir, "-pxf", tarFile.Name())
+	tarCmd.Timeout = OperationTimeout("tar")
+	if result := tarCmd.RunResult(); result.ExitCode != 0 {
+		return fmt.Errorf("failed to extract %s: %s", tarFile.Name(), result.Stderr)
 	}
 
 	return nil
 }
 
 // Save to disk the Config
+// the Config is not validated, see Validate
 func (b *Bundle) Save() error {
 	content, err := json.Marshal(b.Config)
 	if err != nil {
@@ -149,7 +189,24 @@ func (b *Bundle) Save() error {
 	return nil
 }
 
+// ValidateAndSave validates the Config and saves it to disk if it is valid
+func (b *Bundle) ValidateAndSave() error {
+	if err := b.Validate(); err != nil {
+		return err
+	}
+
+	return b.Save()
+}
+
 // Remove the bundle files and directories
 func (b *Bundle) Remove() error {
+	unregisterBundle(b)
+
+	if b.rootfsMounted {
+		if err := unmountRootfs(b); err != nil {
+			return err
+		}
+	}
+
 	return os.RemoveAll(b.Path)
 }
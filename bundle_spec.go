@@ -0,0 +1,314 @@
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tests
+
+import (
+	"strings"
+
+	spec "github.com/opencontainers/specs/specs-go"
+)
+
+// The methods below modify the Config of the bundle and return the
+// bundle so they can be chained, e.g.
+//
+//	err := b.WithEnv("FOO=bar").WithCwd("/tmp").WithTerminal(false).ValidateAndSave()
+//
+// None of them validate their arguments, Validate (called by
+// ValidateAndSave) reports the configuration errors.
+
+// WithArgs sets the arguments of the container process
+func (b *Bundle) WithArgs(args ...string) *Bundle {
+	b.Config.Process.Args = args
+	return b
+}
+
+// WithEnv adds environment variables in the form KEY=value to the
+// container process, variables already defined are replaced
+func (b *Bundle) WithEnv(env ...string) *Bundle {
+	for _, e := range env {
+		key := strings.SplitN(e, "=", 2)[0]
+
+		replaced := false
+		for i, cur := range b.Config.Process.Env {
+			if strings.SplitN(cur, "=", 2)[0] == key {
+				b.Config.Process.Env[i] = e
+				replaced = true
+				break
+			}
+		}
+
+		if !replaced {
+			b.Config.Process.Env = append(b.Config.Process.Env, e)
+		}
+	}
+
+	return b
+}
+
+// WithCwd sets the working directory of the container process
+func (b *Bundle) WithCwd(cwd string) *Bundle {
+	b.Config.Process.Cwd = cwd
+	return b
+}
+
+// WithUser sets the user, group and additional groups of the container process
+func (b *Bundle) WithUser(uid, gid uint32, additionalGids ...uint32) *Bundle {
+	b.Config.Process.User = spec.User{
+		UID:            uid,
+		GID:            gid,
+		AdditionalGids: additionalGids,
+	}
+
+	return b
+}
+
+// WithTerminal sets whether a terminal is attached to the container process
+func (b *Bundle) WithTerminal(terminal bool) *Bundle {
+	b.Config.Process.Terminal = terminal
+	return b
+}
+
+// WithNoNewPrivileges sets whether the container process can gain privileges
+func (b *Bundle) WithNoNewPrivileges(noNewPrivileges bool) *Bundle {
+	b.Config.Process.NoNewPrivileges = noNewPrivileges
+	return b
+}
+
+// WithHostname sets the hostname of the container
+func (b *Bundle) WithHostname(hostname string) *Bundle {
+	b.Config.Hostname = hostname
+	return b
+}
+
+// WithReadonlyRootfs sets whether the rootfs is mounted read-only
+func (b *Bundle) WithReadonlyRootfs(readonly bool) *Bundle {
+	b.Config.Root.Readonly = readonly
+	return b
+}
+
+// WithMount adds a mount, a mount with the same destination is replaced
+func (b *Bundle) WithMount(mount spec.Mount) *Bundle {
+	b.WithoutMount(mount.Destination)
+	b.Config.Mounts = append(b.Config.Mounts, mount)
+	return b
+}
+
+// WithoutMount removes the mount with the given destination
+func (b *Bundle) WithoutMount(destination string) *Bundle {
+	var mounts []spec.Mount
+
+	for _, m := range b.Config.Mounts {
+		if m.Destination != destination {
+			mounts = append(mounts, m)
+		}
+	}
+
+	b.Config.Mounts = mounts
+
+	return b
+}
+
+// WithRlimit sets the soft and hard resource limit of the container
+// process, e.g. RLIMIT_NOFILE, a limit of the same type is replaced
+func (b *Bundle) WithRlimit(rlimitType string, soft, hard uint64) *Bundle {
+	rlimit := spec.LinuxRlimit{
+		Type: rlimitType,
+		Hard: hard,
+		Soft: soft,
+	}
+
+	for i, r := range b.Config.Process.Rlimits {
+		if r.Type == rlimitType {
+			b.Config.Process.Rlimits[i] = rlimit
+			return b
+		}
+	}
+
+	b.Config.Process.Rlimits = append(b.Config.Process.Rlimits, rlimit)
+
+	return b
+}
+
+// WithCapabilities sets the bounding, effective, inheritable and
+// permitted capabilities of the container process, e.g. CAP_CHOWN
+func (b *Bundle) WithCapabilities(caps ...string) *Bundle {
+	b.Config.Process.Capabilities = &spec.LinuxCapabilities{
+		Bounding:    caps,
+		Effective:   caps,
+		Inheritable: caps,
+		Permitted:   caps,
+	}
+
+	return b
+}
+
+// WithAmbientCapabilities sets the ambient capabilities of the container process
+func (b *Bundle) WithAmbientCapabilities(caps ...string) *Bundle {
+	if b.Config.Process.Capabilities == nil {
+		b.Config.Process.Capabilities = &spec.LinuxCapabilities{}
+	}
+
+	b.Config.Process.Capabilities.Ambient = caps
+
+	return b
+}
+
+// WithNamespace adds a namespace, if path is not empty the container
+// joins the namespace in path, a namespace of the same type is replaced
+func (b *Bundle) WithNamespace(nsType spec.LinuxNamespaceType, path string) *Bundle {
+	linux := b.linux()
+	ns := spec.LinuxNamespace{
+		Type: nsType,
+		Path: path,
+	}
+
+	for i, n := range linux.Namespaces {
+		if n.Type == nsType {
+			linux.Namespaces[i] = ns
+			return b
+		}
+	}
+
+	linux.Namespaces = append(linux.Namespaces, ns)
+
+	return b
+}
+
+// WithoutNamespace removes the namespace of the given type
+func (b *Bundle) WithoutNamespace(nsType spec.LinuxNamespaceType) *Bundle {
+	linux := b.linux()
+
+	var namespaces []spec.LinuxNamespace
+	for _, n := range linux.Namespaces {
+		if n.Type != nsType {
+			namespaces = append(namespaces, n)
+		}
+	}
+
+	linux.Namespaces = namespaces
+
+	return b
+}
+
+// WithAnnotation adds an annotation
+func (b *Bundle) WithAnnotation(key, value string) *Bundle {
+	if b.Config.Annotations == nil {
+		b.Config.Annotations = make(map[string]string)
+	}
+
+	b.Config.Annotations[key] = value
+
+	return b
+}
+
+// WithPrestartHook adds a prestart hook
+func (b *Bundle) WithPrestartHook(hook spec.Hook) *Bundle {
+	hooks := b.hooks()
+	hooks.Prestart = append(hooks.Prestart, hook)
+	return b
+}
+
+// WithPoststartHook adds a poststart hook
+func (b *Bundle) WithPoststartHook(hook spec.Hook) *Bundle {
+	hooks := b.hooks()
+	hooks.Poststart = append(hooks.Poststart, hook)
+	return b
+}
+
+// WithPoststopHook adds a poststop hook
+func (b *Bundle) WithPoststopHook(hook spec.Hook) *Bundle {
+	hooks := b.hooks()
+	hooks.Poststop = append(hooks.Poststop, hook)
+	return b
+}
+
+// WithMemoryLimit sets the memory limit in bytes of the container
+func (b *Bundle) WithMemoryLimit(limit uint64) *Bundle {
+	resources := b.resources()
+	if resources.Memory == nil {
+		resources.Memory = &spec.LinuxMemory{}
+	}
+
+	resources.Memory.Limit = &limit
+
+	return b
+}
+
+// WithCPUShares sets the relative CPU weight of the container
+func (b *Bundle) WithCPUShares(shares uint64) *Bundle {
+	cpu := b.cpu()
+	cpu.Shares = &shares
+	return b
+}
+
+// WithCPUQuota sets the CPU time in microseconds the container
+// can use in each period of microseconds
+func (b *Bundle) WithCPUQuota(quota int64, period uint64) *Bundle {
+	cpu := b.cpu()
+	cpu.Quota = &quota
+	cpu.Period = &period
+	return b
+}
+
+// WithCpus sets the CPUs the container can use, e.g. "0-3"
+func (b *Bundle) WithCpus(cpus string) *Bundle {
+	cpu := b.cpu()
+	cpu.Cpus = cpus
+	return b
+}
+
+// WithPidsLimit sets the maximum number of processes of the container
+func (b *Bundle) WithPidsLimit(limit int64) *Bundle {
+	resources := b.resources()
+	resources.Pids = &spec.LinuxPids{
+		Limit: limit,
+	}
+
+	return b
+}
+
+func (b *Bundle) linux() *spec.Linux {
+	if b.Config.Linux == nil {
+		b.Config.Linux = &spec.Linux{}
+	}
+
+	return b.Config.Linux
+}
+
+func (b *Bundle) resources() *spec.LinuxResources {
+	linux := b.linux()
+	if linux.Resources == nil {
+		linux.Resources = &spec.LinuxResources{}
+	}
+
+	return linux.Resources
+}
+
+func (b *Bundle) cpu() *spec.LinuxCPU {
+	resources := b.resources()
+	if resources.CPU == nil {
+		resources.CPU = &spec.LinuxCPU{}
+	}
+
+	return resources.CPU
+}
+
+func (b *Bundle) hooks() *spec.Hooks {
+	if b.Config.Hooks == nil {
+		b.Config.Hooks = &spec.Hooks{}
+	}
+
+	return b.Config.Hooks
+}
@@ -0,0 +1,93 @@
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	spec "github.com/opencontainers/specs/specs-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewBundleFromTar(t *testing.T) {
+	assert := assert.New(t)
+
+	b, err := NewBundleFromTar([]string{"true"}, testRootfs)
+	assert.NoError(err)
+
+	for _, path := range []string{"config.json", "rootfs/bin", "rootfs/tmp"} {
+		_, err := os.Stat(filepath.Join(b.Path, path))
+		assert.NoError(err, path)
+	}
+
+	assert.Equal([]string{"true"}, b.Config.Process.Args)
+
+	assert.NoError(b.Remove())
+	_, err = os.Stat(b.Path)
+	assert.True(os.IsNotExist(err))
+}
+
+func TestBundleSpecBuilder(t *testing.T) {
+	assert := assert.New(t)
+
+	b, err := NewBundleFromTar([]string{"true"}, testRootfs)
+	assert.NoError(err)
+	defer b.Remove()
+
+	b.WithEnv("FOO=bar", "FOO=baz").
+		WithCwd("/tmp").
+		WithMount(spec.Mount{Destination: "/data", Type: "tmpfs", Source: "tmpfs"}).
+		WithMount(spec.Mount{Destination: "/data", Type: "bind", Source: "/tmp"}).
+		WithMemoryLimit(1<<20).
+		WithRlimit("RLIMIT_NOFILE", 1024, 4096)
+
+	assert.Contains(b.Config.Process.Env, "FOO=baz")
+	assert.NotContains(b.Config.Process.Env, "FOO=bar")
+	assert.Equal("/tmp", b.Config.Process.Cwd)
+
+	var data []spec.Mount
+	for _, m := range b.Config.Mounts {
+		if m.Destination == "/data" {
+			data = append(data, m)
+		}
+	}
+	assert.Equal([]spec.Mount{{Destination: "/data", Type: "bind", Source: "/tmp"}}, data)
+
+	assert.Equal(uint64(1<<20), *b.Config.Linux.Resources.Memory.Limit)
+	assert.Contains(b.Config.Process.Rlimits, spec.LinuxRlimit{Type: "RLIMIT_NOFILE", Soft: 1024, Hard: 4096})
+	assert.NoError(b.ValidateAndSave())
+}
+
+func TestBundleValidate(t *testing.T) {
+	assert := assert.New(t)
+
+	b, err := NewBundleFromTar([]string{"true"}, testRootfs)
+	assert.NoError(err)
+	defer b.Remove()
+
+	b.WithCwd("tmp").WithEnv("=bar").WithCapabilities("CAP_FOO", "chown")
+
+	err = b.Validate()
+	assert.Error(err)
+	assert.Contains(err.Error(), "process cwd")
+	assert.Contains(err.Error(), "process env")
+	assert.Contains(err.Error(), "process.capabilities.bounding.1")
+	assert.Error(b.ValidateAndSave())
+
+	// invalid configurations can be saved to check how the runtime handles them
+	assert.NoError(b.Save())
+}
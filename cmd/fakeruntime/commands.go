@@ -0,0 +1,370 @@
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	spec "github.com/opencontainers/specs/specs-go"
+	"github.com/urfave/cli"
+)
+
+// deleteTimeout is the time given to a killed container process to finish
+const deleteTimeout = 5 * time.Second
+
+var signals = map[string]syscall.Signal{
+	"ABRT": syscall.SIGABRT,
+	"ALRM": syscall.SIGALRM,
+	"CHLD": syscall.SIGCHLD,
+	"CONT": syscall.SIGCONT,
+	"HUP":  syscall.SIGHUP,
+	"INT":  syscall.SIGINT,
+	"KILL": syscall.SIGKILL,
+	"PIPE": syscall.SIGPIPE,
+	"QUIT": syscall.SIGQUIT,
+	"STOP": syscall.SIGSTOP,
+	"TERM": syscall.SIGTERM,
+	"TSTP": syscall.SIGTSTP,
+	"USR1": syscall.SIGUSR1,
+	"USR2": syscall.SIGUSR2,
+}
+
+var stateCommand = cli.Command{
+	Name:      "state",
+	Usage:     "output the state of a container",
+	ArgsUsage: "<container-id>",
+	Action: func(context *cli.Context) error {
+		c, err := loadContainer(context.Args().First())
+		if err != nil {
+			return err
+		}
+
+		return printJSON(c.ociState())
+	},
+}
+
+var killCommand = cli.Command{
+	Name:      "kill",
+	Usage:     "send a signal to the container process",
+	ArgsUsage: "<container-id> [signal]",
+	Flags: []cli.Flag{
+		cli.BoolFlag{
+			Name:  "all, a",
+			Usage: "ignored, the signal is sent to the container process",
+		},
+	},
+	Action: func(context *cli.Context) error {
+		c, err := loadContainer(context.Args().First())
+		if err != nil {
+			return err
+		}
+
+		signal, err := parseSignal(context.Args().Get(1))
+		if err != nil {
+			return err
+		}
+
+		if status := c.status(); status == statusStopped {
+			return fmt.Errorf("cannot kill a container in %s state", status)
+		}
+
+		return syscall.Kill(c.Pid, signal)
+	},
+}
+
+var deleteCommand = cli.Command{
+	Name:      "delete",
+	Usage:     "delete a container",
+	ArgsUsage: "<container-id>",
+	Flags: []cli.Flag{
+		cli.BoolFlag{
+			Name:  "force, f",
+			Usage: "kill the container process if it is running",
+		},
+	},
+	Action: func(context *cli.Context) error {
+		c, err := loadContainer(context.Args().First())
+		if err != nil {
+			return err
+		}
+
+		switch status := c.status(); status {
+		case statusRunning, statusPaused:
+			if !context.Bool("force") {
+				return fmt.Errorf("cannot delete a container in %s state, use --force", status)
+			}
+			fallthrough
+		case statusCreated:
+			if err := syscall.Kill(c.Pid, syscall.SIGKILL); err != nil && err != syscall.ESRCH {
+				return err
+			}
+
+			if err := waitForExit(c.Pid, deleteTimeout); err != nil {
+				return err
+			}
+
+			// the monitor of the container process records
+			// its exit code in the container directory
+			c.waitForExitCode(deleteTimeout)
+		}
+
+		return os.RemoveAll(containerDir(c.ID))
+	},
+}
+
+var listCommand = cli.Command{
+	Name:  "list",
+	Usage: "list the containers",
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "format, f",
+			Value: "table",
+			Usage: "table or json",
+		},
+		cli.BoolFlag{
+			Name:  "quiet, q",
+			Usage: "display only the container IDs",
+		},
+		cli.BoolFlag{
+			Name:  "all, a",
+			Usage: "ignored, all the containers are listed",
+		},
+	},
+	Action: func(context *cli.Context) error {
+		containers, err := listContainers()
+		if err != nil {
+			return err
+		}
+
+		if context.Bool("quiet") {
+			for _, c := range containers {
+				fmt.Println(c.ID)
+			}
+			return nil
+		}
+
+		switch format := context.String("format"); format {
+		case "table":
+			w := tabwriter.NewWriter(os.Stdout, 12, 1, 3, ' ', 0)
+			fmt.Fprint(w, "ID\tPID\tSTATUS\tBUNDLE\tCREATED\n")
+			for _, c := range containers {
+				fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\n", c.ID, c.Pid, c.status(), c.Bundle, c.Created.Format(time.RFC3339Nano))
+			}
+			return w.Flush()
+		case "json":
+			states := []spec.State{}
+			for _, c := range containers {
+				states = append(states, c.ociState())
+			}
+			return printJSON(states)
+		default:
+			return fmt.Errorf("invalid format option %q", format)
+		}
+	},
+}
+
+var pauseCommand = cli.Command{
+	Name:      "pause",
+	Usage:     "suspend the container process",
+	ArgsUsage: "<container-id>",
+	Action: func(context *cli.Context) error {
+		return transition(context.Args().First(), statusRunning, syscall.SIGSTOP, func(c *container) error {
+			return ioutil.WriteFile(c.path(pausedFile), nil, 0600)
+		})
+	},
+}
+
+var resumeCommand = cli.Command{
+	Name:      "resume",
+	Usage:     "resume the container process",
+	ArgsUsage: "<container-id>",
+	Action: func(context *cli.Context) error {
+		return transition(context.Args().First(), statusPaused, syscall.SIGCONT, func(c *container) error {
+			return os.Remove(c.path(pausedFile))
+		})
+	},
+}
+
+var psCommand = cli.Command{
+	Name:      "ps",
+	Usage:     "display the processes running in a container",
+	ArgsUsage: "<container-id> [ps options]",
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "format, f",
+			Value: "table",
+			Usage: "table or json",
+		},
+	},
+	SkipArgReorder: true,
+	Action: func(context *cli.Context) error {
+		c, err := loadContainer(context.Args().First())
+		if err != nil {
+			return err
+		}
+
+		if status := c.status(); status != statusRunning && status != statusPaused {
+			return fmt.Errorf("cannot list the processes of a container in %s state", status)
+		}
+
+		switch format := context.String("format"); format {
+		case "table":
+			cmdline, _ := ioutil.ReadFile(fmt.Sprintf("/proc/%d/cmdline", c.Pid))
+			fmt.Printf("PID\tCMD\n%d\t%s\n", c.Pid, strings.TrimSpace(strings.Replace(string(cmdline), "\x00", " ", -1)))
+			return nil
+		case "json":
+			return printJSON([]int{c.Pid})
+		default:
+			return fmt.Errorf("invalid format option %q", format)
+		}
+	},
+}
+
+var eventsCommand = cli.Command{
+	Name:      "events",
+	Usage:     "display the container events",
+	ArgsUsage: "<container-id>",
+	Flags: []cli.Flag{
+		cli.BoolFlag{
+			Name:  "stats",
+			Usage: "display the statistics once",
+		},
+		cli.DurationFlag{
+			Name:  "interval",
+			Value: 5 * time.Second,
+			Usage: "interval between statistics",
+		},
+	},
+	Action: func(context *cli.Context) error {
+		c, err := loadContainer(context.Args().First())
+		if err != nil {
+			return err
+		}
+
+		for {
+			if status := c.status(); status != statusRunning && status != statusPaused {
+				return fmt.Errorf("container %s is not running", c.ID)
+			}
+
+			// there are no statistics without cgroups
+			event := map[string]interface{}{
+				"type": "stats",
+				"id":   c.ID,
+				"data": map[string]interface{}{},
+			}
+
+			if err := printJSON(event); err != nil || context.Bool("stats") {
+				return err
+			}
+
+			time.Sleep(context.Duration("interval"))
+		}
+	},
+}
+
+var updateCommand = cli.Command{
+	Name:      "update",
+	Usage:     "update the container resources, they are only validated",
+	ArgsUsage: "<container-id>",
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "resources, r",
+			Usage: "path to a file with the resources",
+		},
+	},
+	Action: func(context *cli.Context) error {
+		c, err := loadContainer(context.Args().First())
+		if err != nil {
+			return err
+		}
+
+		if status := c.status(); status != statusRunning && status != statusPaused {
+			return fmt.Errorf("cannot update a container in %s state", status)
+		}
+
+		path := context.String("resources")
+		if path == "" {
+			return nil
+		}
+
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		var resources spec.LinuxResources
+		if err := json.Unmarshal(content, &resources); err != nil {
+			return fmt.Errorf("invalid resources: %v", err)
+		}
+
+		return nil
+	},
+}
+
+// transition sends signal to a container in status from and
+// calls done to record the new status
+func transition(id, from string, signal syscall.Signal, done func(c *container) error) error {
+	c, err := loadContainer(id)
+	if err != nil {
+		return err
+	}
+
+	if status := c.status(); status != from {
+		return fmt.Errorf("container %s is %s, not %s", id, status, from)
+	}
+
+	if err := syscall.Kill(c.Pid, signal); err != nil {
+		return err
+	}
+
+	return done(c)
+}
+
+// parseSignal parses signals by number or by name with or
+// without the SIG prefix, the default signal is SIGTERM
+func parseSignal(s string) (syscall.Signal, error) {
+	if s == "" {
+		return syscall.SIGTERM, nil
+	}
+
+	if n, err := strconv.Atoi(s); err == nil {
+		return syscall.Signal(n), nil
+	}
+
+	if signal, ok := signals[strings.TrimPrefix(strings.ToUpper(s), "SIG")]; ok {
+		return signal, nil
+	}
+
+	return 0, fmt.Errorf("unknown signal %q", s)
+}
+
+func printJSON(v interface{}) error {
+	content, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(string(content))
+
+	return nil
+}
@@ -0,0 +1,451 @@
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	spec "github.com/opencontainers/specs/specs-go"
+	"github.com/urfave/cli"
+)
+
+// selfExe is the path of the running binary,
+// used to start the monitor and init processes
+const selfExe = "/proc/self/exe"
+
+var createFlags = []cli.Flag{
+	cli.StringFlag{
+		Name:  "bundle, b",
+		Usage: "path to the root of the bundle directory, defaults to the current directory",
+	},
+	cli.StringFlag{
+		Name:  "console",
+		Usage: "path to a pseudo terminal used as stdio of the container process",
+	},
+	cli.StringFlag{
+		Name:  "pid-file",
+		Usage: "file to write the process id to",
+	},
+}
+
+var createCommand = cli.Command{
+	Name:      "create",
+	Usage:     "create a container",
+	ArgsUsage: "<container-id>",
+	Flags:     createFlags,
+	Action: func(context *cli.Context) error {
+		_, err := create(context)
+		return err
+	},
+}
+
+var startCommand = cli.Command{
+	Name:      "start",
+	Usage:     "start the process of a created container",
+	ArgsUsage: "<container-id>",
+	Action: func(context *cli.Context) error {
+		c, err := loadContainer(context.Args().First())
+		if err != nil {
+			return err
+		}
+
+		return start(c)
+	},
+}
+
+var runCommand = cli.Command{
+	Name:      "run",
+	Usage:     "create and start a container",
+	ArgsUsage: "<container-id>",
+	Flags: append(createFlags, cli.BoolFlag{
+		Name:  "detach, d",
+		Usage: "detach from the container process",
+	}),
+	Action: func(context *cli.Context) error {
+		if context.Bool("detach") {
+			c, err := create(context)
+			if err != nil {
+				return err
+			}
+
+			return start(c)
+		}
+
+		return run(context)
+	},
+}
+
+// shimCommand monitors the container process of a created
+// container, writes its pid to fd 3 and records its exit code
+var shimCommand = cli.Command{
+	Name:   "shim",
+	Hidden: true,
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name: "console",
+		},
+	},
+	Action: func(context *cli.Context) error {
+		c, err := loadContainer(context.Args().First())
+		if err != nil {
+			return err
+		}
+
+		// the container process must not keep the pipe open
+		pipe := os.NewFile(3, "pid")
+		syscall.CloseOnExec(int(pipe.Fd()))
+
+		stdio, err := openStdio(context.String("console"))
+		if err != nil {
+			return err
+		}
+		defer stdio.Close()
+
+		cmd := initCmd(c)
+		setStdio(cmd, stdio, context.String("console"))
+
+		if err := cmd.Start(); err != nil {
+			return err
+		}
+
+		_, err = fmt.Fprintf(pipe, "%d", cmd.Process.Pid)
+		pipe.Close()
+		if err != nil {
+			return err
+		}
+
+		return c.setExitCode(exitStatus(cmd.Wait()))
+	},
+}
+
+// initCommand waits for the start command and
+// executes the process of the container
+var initCommand = cli.Command{
+	Name:   "init",
+	Hidden: true,
+	Action: func(context *cli.Context) error {
+		c, err := loadContainer(context.Args().First())
+		if err != nil {
+			return err
+		}
+
+		config, err := loadSpec(c.Bundle)
+		if err != nil {
+			return err
+		}
+
+		// blocks until start opens the fifo
+		fifo, err := os.OpenFile(c.path(execFifo), os.O_WRONLY, 0)
+		if err != nil {
+			return err
+		}
+		_, err = fifo.Write([]byte{0})
+		fifo.Close()
+		if err != nil {
+			return err
+		}
+
+		return execProcess(&config.Process)
+	},
+}
+
+// loadSpec reads the config.json of the bundle
+func loadSpec(bundle string) (*spec.Spec, error) {
+	content, err := ioutil.ReadFile(filepath.Join(bundle, "config.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	var config spec.Spec
+	if err := json.Unmarshal(content, &config); err != nil {
+		return nil, fmt.Errorf("invalid config.json: %v", err)
+	}
+
+	if len(config.Process.Args) == 0 {
+		return nil, fmt.Errorf("config.json: process args cannot be empty")
+	}
+
+	return &config, nil
+}
+
+// newContainer creates the state directory of a container
+// from the options of create and run
+func newContainer(context *cli.Context) (*container, error) {
+	id := context.Args().First()
+	if id == "" {
+		return nil, fmt.Errorf("container id cannot be empty")
+	}
+
+	bundle := context.String("bundle")
+	if bundle == "" {
+		bundle = "."
+	}
+
+	bundle, err := filepath.Abs(bundle)
+	if err != nil {
+		return nil, err
+	}
+
+	config, err := loadSpec(bundle)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &container{
+		ID:          id,
+		Bundle:      bundle,
+		Created:     time.Now().UTC(),
+		Annotations: config.Annotations,
+	}
+
+	if err := os.Mkdir(containerDir(id), 0700); err != nil {
+		if os.IsExist(err) {
+			return nil, fmt.Errorf("container %s already exists", id)
+		}
+		return nil, err
+	}
+
+	if err := syscall.Mkfifo(c.path(execFifo), 0600); err != nil {
+		os.RemoveAll(containerDir(id))
+		return nil, err
+	}
+
+	if err := c.save(); err != nil {
+		os.RemoveAll(containerDir(id))
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// create creates a container whose process is monitored by a shim
+func create(context *cli.Context) (*container, error) {
+	c, err := newContainer(context)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := startShim(c, context.String("console")); err != nil {
+		os.RemoveAll(containerDir(c.ID))
+		return nil, err
+	}
+
+	if err := writePidFile(context.String("pid-file"), c.Pid); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// startShim starts the shim of the container and saves
+// the pid of the container process
+func startShim(c *container, console string) error {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	devNull, err := os.OpenFile(os.DevNull, os.O_RDWR, 0)
+	if err != nil {
+		w.Close()
+		return err
+	}
+	defer devNull.Close()
+
+	cmd := exec.Command(selfExe, "--root", stateRoot, "shim", "--console", console, c.ID)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = devNull, devNull, devNull
+	cmd.ExtraFiles = []*os.File{w}
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+
+	err = cmd.Start()
+	w.Close()
+	if err != nil {
+		return err
+	}
+
+	content, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	// the shim outlives this process
+	if err := cmd.Process.Release(); err != nil {
+		return err
+	}
+
+	c.Pid, err = strconv.Atoi(string(content))
+	if err != nil {
+		return fmt.Errorf("shim failed to start the container process")
+	}
+
+	return c.save()
+}
+
+// start lets the process of a created container run
+func start(c *container) error {
+	if status := c.status(); status != statusCreated {
+		return fmt.Errorf("cannot start a container in %s state", status)
+	}
+
+	fifo, err := os.OpenFile(c.path(execFifo), os.O_RDONLY, 0)
+	if err != nil {
+		return err
+	}
+	defer fifo.Close()
+
+	if _, err := fifo.Read(make([]byte, 1)); err != nil {
+		return fmt.Errorf("container process did not start: %v", err)
+	}
+
+	return os.Remove(c.path(execFifo))
+}
+
+// run creates and starts a container whose process is a child of this
+// process, it exits with the exit code of the container process
+// once the container is deleted
+func run(context *cli.Context) error {
+	c, err := newContainer(context)
+	if err != nil {
+		return err
+	}
+
+	cmd := initCmd(c)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+
+	if console := context.String("console"); console != "" {
+		stdio, err := openStdio(console)
+		if err != nil {
+			os.RemoveAll(containerDir(c.ID))
+			return err
+		}
+		defer stdio.Close()
+
+		setStdio(cmd, stdio, console)
+	}
+
+	if err := cmd.Start(); err != nil {
+		os.RemoveAll(containerDir(c.ID))
+		return err
+	}
+
+	c.Pid = cmd.Process.Pid
+	if err := c.save(); err != nil {
+		return err
+	}
+
+	if err := writePidFile(context.String("pid-file"), c.Pid); err != nil {
+		return err
+	}
+
+	if err := start(c); err != nil {
+		return err
+	}
+
+	code := exitStatus(cmd.Wait())
+
+	// a concurrent delete waits for the exit code
+	if err := c.setExitCode(code); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	if err := os.RemoveAll(containerDir(c.ID)); err != nil {
+		return err
+	}
+
+	os.Exit(code)
+
+	return nil
+}
+
+func initCmd(c *container) *exec.Cmd {
+	return exec.Command(selfExe, "--root", stateRoot, "init", c.ID)
+}
+
+// openStdio opens the console, if it is empty /dev/null is opened
+func openStdio(console string) (*os.File, error) {
+	if console == "" {
+		console = os.DevNull
+	}
+
+	// the process that opens the console must not get it as controlling
+	// terminal, the container process does
+	return os.OpenFile(console, os.O_RDWR|syscall.O_NOCTTY, 0)
+}
+
+// setStdio makes stdio the standard streams of cmd, a console
+// is also the controlling terminal of the new session of cmd
+func setStdio(cmd *exec.Cmd, stdio *os.File, console string) {
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = stdio, stdio, stdio
+
+	if console != "" {
+		cmd.SysProcAttr = &syscall.SysProcAttr{
+			Setsid:  true,
+			Setctty: true,
+		}
+	}
+}
+
+func writePidFile(path string, pid int) error {
+	if path == "" {
+		return nil
+	}
+
+	return ioutil.WriteFile(path, []byte(strconv.Itoa(pid)), 0644)
+}
+
+// execProcess replaces the running binary with the process
+func execProcess(process *spec.Process) error {
+	path, err := lookPath(process)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Chdir(hostDir(process.Cwd)); err != nil {
+		return err
+	}
+
+	return syscall.Exec(path, process.Args, process.Env)
+}
+
+// lookPath looks for the executable of the process in its PATH
+func lookPath(process *spec.Process) (string, error) {
+	for _, e := range process.Env {
+		if strings.HasPrefix(e, "PATH=") {
+			os.Setenv("PATH", strings.TrimPrefix(e, "PATH="))
+		}
+	}
+
+	return exec.LookPath(process.Args[0])
+}
+
+// hostDir returns dir if it exists in the host, else /,
+// there is no rootfs, the processes run in the host
+func hostDir(dir string) string {
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return "/"
+	}
+
+	return dir
+}
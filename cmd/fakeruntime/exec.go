@@ -0,0 +1,187 @@
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"syscall"
+
+	spec "github.com/opencontainers/specs/specs-go"
+	"github.com/urfave/cli"
+)
+
+var execCommand = cli.Command{
+	Name:      "exec",
+	Usage:     "execute a new process in a running container",
+	ArgsUsage: "<container-id> <command> [command options]",
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "console",
+			Usage: "path to a pseudo terminal used as stdio of the process",
+		},
+		cli.StringFlag{
+			Name:  "tty, t",
+			Usage: "ignored, there is no terminal support",
+		},
+		cli.BoolFlag{
+			Name:  "detach, d",
+			Usage: "detach from the process",
+		},
+		cli.StringFlag{
+			Name:  "pid-file",
+			Usage: "file to write the process id to",
+		},
+		cli.StringFlag{
+			Name:  "process, p",
+			Usage: "path to the process.json",
+		},
+		cli.StringSliceFlag{
+			Name:  "env, e",
+			Usage: "set environment variables",
+		},
+		cli.StringFlag{
+			Name:  "cwd",
+			Usage: "current working directory of the process",
+		},
+		cli.StringFlag{
+			Name:  "user, u",
+			Usage: "ignored, the process runs as the caller",
+		},
+		cli.StringSliceFlag{
+			Name:  "cap, c",
+			Usage: "ignored, the process runs with the caller capabilities",
+		},
+		cli.BoolFlag{
+			Name:  "no-new-privs",
+			Usage: "ignored, the process runs with the caller privileges",
+		},
+	},
+	// the command options must not be parsed as exec options
+	SkipArgReorder: true,
+	Action: func(context *cli.Context) error {
+		c, err := loadContainer(context.Args().First())
+		if err != nil {
+			return err
+		}
+
+		if status := c.status(); status != statusRunning {
+			return fmt.Errorf("cannot exec in a container in %s state", status)
+		}
+
+		process, err := execProcessSpec(context, c)
+		if err != nil {
+			return err
+		}
+
+		if context.String("user") != "" || len(context.StringSlice("cap")) > 0 || context.Bool("no-new-privs") {
+			logWarnf("user, capabilities and privileges are ignored")
+		}
+
+		path, err := lookPath(process)
+		if err != nil {
+			return err
+		}
+
+		cmd := exec.Command(path, process.Args[1:]...)
+		cmd.Args[0] = process.Args[0]
+		cmd.Env = append(process.Env, context.StringSlice("env")...)
+		cmd.Dir = hostDir(process.Cwd)
+		if cwd := context.String("cwd"); cwd != "" {
+			cmd.Dir = cwd
+		}
+
+		stdio, err := openStdio(context.String("console"))
+		if err != nil {
+			return err
+		}
+		defer stdio.Close()
+
+		if context.Bool("detach") {
+			setStdio(cmd, stdio, context.String("console"))
+			if cmd.SysProcAttr == nil {
+				cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+			}
+
+			if err := cmd.Start(); err != nil {
+				return err
+			}
+
+			if err := writePidFile(context.String("pid-file"), cmd.Process.Pid); err != nil {
+				return err
+			}
+
+			return cmd.Process.Release()
+		}
+
+		cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+		if console := context.String("console"); console != "" {
+			setStdio(cmd, stdio, console)
+		}
+
+		if err := cmd.Start(); err != nil {
+			return err
+		}
+
+		if err := writePidFile(context.String("pid-file"), cmd.Process.Pid); err != nil {
+			return err
+		}
+
+		os.Exit(exitStatus(cmd.Wait()))
+
+		return nil
+	},
+}
+
+// execProcessSpec returns the process described by the --process
+// option or by the command line, it inherits the environment and
+// working directory of the container process
+func execProcessSpec(context *cli.Context, c *container) (*spec.Process, error) {
+	if path := context.String("process"); path != "" {
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		var process spec.Process
+		if err := json.Unmarshal(content, &process); err != nil {
+			return nil, fmt.Errorf("invalid process.json: %v", err)
+		}
+
+		if len(process.Args) == 0 {
+			return nil, fmt.Errorf("process.json: args cannot be empty")
+		}
+
+		return &process, nil
+	}
+
+	args := context.Args().Tail()
+	if len(args) == 0 {
+		return nil, fmt.Errorf("exec: command cannot be empty")
+	}
+
+	config, err := loadSpec(c.Bundle)
+	if err != nil {
+		return nil, err
+	}
+
+	process := config.Process
+	process.Args = args
+
+	return &process, nil
+}
@@ -0,0 +1,85 @@
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"time"
+)
+
+// logger writes entries in the text and JSON formats of logrus,
+// like the runtimes do
+type logger struct {
+	out  io.Writer
+	json bool
+}
+
+// runtimeLog is the --log file of the runtime
+var runtimeLog = &logger{out: ioutil.Discard}
+
+func logDebugf(format string, args ...interface{}) {
+	runtimeLog.log("debug", fmt.Sprintf(format, args...))
+}
+
+func logWarnf(format string, args ...interface{}) {
+	runtimeLog.log("warning", fmt.Sprintf(format, args...))
+}
+
+func logErrorf(format string, args ...interface{}) {
+	runtimeLog.log("error", fmt.Sprintf(format, args...))
+}
+
+func (l *logger) log(level, msg string) {
+	now := time.Now().Format(time.RFC3339Nano)
+	pid := os.Getpid()
+
+	var line []byte
+	if l.json {
+		line, _ = json.Marshal(map[string]interface{}{
+			"time":   now,
+			"level":  level,
+			"msg":    msg,
+			"source": "runtime",
+			"name":   name,
+			"pid":    pid,
+		})
+	} else {
+		var b bytes.Buffer
+		fmt.Fprintf(&b, "time=%s level=%s msg=%s name=%s pid=%d source=runtime",
+			logValue(now), level, logValue(msg), name, pid)
+		line = b.Bytes()
+	}
+
+	_, _ = l.out.Write(append(line, '\n'))
+}
+
+// logValue quotes the values that are not made of
+// letters, digits and -._/@^+ as logrus does
+func logValue(value string) string {
+	for _, c := range value {
+		if !((c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') ||
+			c == '-' || c == '.' || c == '_' || c == '/' || c == '@' || c == '^' || c == '+') {
+			return strconv.Quote(value)
+		}
+	}
+
+	return value
+}
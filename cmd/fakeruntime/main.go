@@ -0,0 +1,124 @@
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// fakeruntime implements the command line of an OCI runtime without
+// any isolation: the container process runs in the host, the state of
+// the containers is kept in a directory. It is used to test the test
+// framework on hosts that cannot run Clear Containers.
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/urfave/cli"
+)
+
+const (
+	name  = "fakeruntime"
+	usage = "fake OCI runtime to test the test framework"
+
+	// rootEnv is the environment variable used when
+	// the --root option is not specified
+	rootEnv = "FAKERUNTIME_ROOT"
+)
+
+// stateRoot is the directory where the state of the containers is kept
+var stateRoot string
+
+func defaultRoot() string {
+	if os.Geteuid() == 0 {
+		return "/run/" + name
+	}
+
+	return filepath.Join(os.TempDir(), fmt.Sprintf("%s-%d", name, os.Geteuid()))
+}
+
+func main() {
+	app := cli.NewApp()
+	app.Name = name
+	app.Usage = usage
+
+	app.Flags = []cli.Flag{
+		cli.StringFlag{
+			Name:   "root",
+			Value:  defaultRoot(),
+			Usage:  "directory where the state of the containers is kept",
+			EnvVar: rootEnv,
+		},
+		cli.StringFlag{
+			Name:  "log",
+			Usage: "set the log file path",
+		},
+		cli.StringFlag{
+			Name:  "log-format",
+			Value: "text",
+			Usage: "set the format of the log file (text or json)",
+		},
+	}
+
+	app.Commands = []cli.Command{
+		createCommand,
+		startCommand,
+		runCommand,
+		stateCommand,
+		killCommand,
+		deleteCommand,
+		listCommand,
+		execCommand,
+		pauseCommand,
+		resumeCommand,
+		psCommand,
+		eventsCommand,
+		updateCommand,
+		shimCommand,
+		initCommand,
+	}
+
+	app.Before = func(context *cli.Context) error {
+		switch format := context.GlobalString("log-format"); format {
+		case "text":
+		case "json":
+			runtimeLog.json = true
+		default:
+			return fmt.Errorf("unknown log format %q", format)
+		}
+
+		if path := context.GlobalString("log"); path != "" {
+			f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND|os.O_SYNC, 0640)
+			if err != nil {
+				return err
+			}
+			runtimeLog.out = f
+		}
+
+		logDebugf("%v", os.Args)
+
+		stateRoot = context.GlobalString("root")
+
+		return os.MkdirAll(stateRoot, 0700)
+	}
+
+	// cli prints the errors and exits, they are logged first below
+	cli.ErrWriter = ioutil.Discard
+	cli.OsExiter = func(int) {}
+
+	if err := app.Run(os.Args); err != nil {
+		logErrorf("%v", err)
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
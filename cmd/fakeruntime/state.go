@@ -0,0 +1,240 @@
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	spec "github.com/opencontainers/specs/specs-go"
+)
+
+const (
+	stateFile = "state.json"
+
+	// execFifo blocks the container process until start is called
+	execFifo = "exec.fifo"
+
+	// exitFile holds the exit code of the container process
+	exitFile = "exit"
+
+	// pausedFile exists while the container is paused
+	pausedFile = "paused"
+
+	statusCreated = "created"
+	statusRunning = "running"
+	statusPaused  = "paused"
+	statusStopped = "stopped"
+)
+
+// container is the state of a container kept in its directory
+type container struct {
+	ID          string            `json:"id"`
+	Pid         int               `json:"pid"`
+	Bundle      string            `json:"bundle"`
+	Created     time.Time         `json:"created"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+func containerDir(id string) string {
+	return filepath.Join(stateRoot, id)
+}
+
+func (c *container) path(file string) string {
+	return filepath.Join(containerDir(c.ID), file)
+}
+
+// loadContainer reads the state of the container id
+func loadContainer(id string) (*container, error) {
+	if id == "" {
+		return nil, fmt.Errorf("container id cannot be empty")
+	}
+
+	content, err := ioutil.ReadFile(filepath.Join(containerDir(id), stateFile))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("container %s does not exist", id)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var c container
+	if err := json.Unmarshal(content, &c); err != nil {
+		return nil, err
+	}
+
+	return &c, nil
+}
+
+// listContainers returns the state of all the containers
+func listContainers() ([]*container, error) {
+	entries, err := ioutil.ReadDir(stateRoot)
+	if err != nil {
+		return nil, err
+	}
+
+	var containers []*container
+	for _, e := range entries {
+		c, err := loadContainer(e.Name())
+		if err != nil {
+			// the container is being created or deleted
+			continue
+		}
+		containers = append(containers, c)
+	}
+
+	return containers, nil
+}
+
+func (c *container) save() error {
+	content, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+
+	// write and rename, other commands could be reading the state
+	tmp := c.path(stateFile + ".tmp")
+	if err := ioutil.WriteFile(tmp, content, 0600); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, c.path(stateFile))
+}
+
+func (c *container) exists(file string) bool {
+	_, err := os.Stat(c.path(file))
+	return err == nil
+}
+
+// status returns the current status of the container
+func (c *container) status() string {
+	switch {
+	case c.exists(exitFile) || !processAlive(c.Pid):
+		return statusStopped
+	case c.exists(execFifo):
+		return statusCreated
+	case c.exists(pausedFile):
+		return statusPaused
+	default:
+		return statusRunning
+	}
+}
+
+// exitCode returns the exit code of the container process
+// recorded by its monitor, -1 if it is not known
+func (c *container) exitCode() int {
+	content, err := ioutil.ReadFile(c.path(exitFile))
+	if err != nil {
+		return -1
+	}
+
+	code, err := strconv.Atoi(strings.TrimSpace(string(content)))
+	if err != nil {
+		return -1
+	}
+
+	return code
+}
+
+func (c *container) setExitCode(code int) error {
+	return ioutil.WriteFile(c.path(exitFile), []byte(strconv.Itoa(code)), 0600)
+}
+
+func (c *container) ociState() spec.State {
+	return spec.State{
+		Version:     spec.Version,
+		ID:          c.ID,
+		Status:      c.status(),
+		Pid:         c.Pid,
+		Bundle:      c.Bundle,
+		Annotations: c.Annotations,
+	}
+}
+
+// processAlive returns true if pid is running, zombies are not running
+func processAlive(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+
+	if err := syscall.Kill(pid, 0); err != nil {
+		return false
+	}
+
+	content, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return false
+	}
+
+	// the state follows the command name, which is between parentheses
+	stat := string(content)
+	if i := strings.LastIndex(stat, ")"); i >= 0 && i+2 < len(stat) {
+		return stat[i+2] != 'Z'
+	}
+
+	return true
+}
+
+// waitForExit waits until the process pid is not running
+func waitForExit(pid int, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+
+	for processAlive(pid) {
+		if time.Now().After(deadline) {
+			return fmt.Errorf("process %d still running after %s", pid, timeout)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	return nil
+}
+
+// waitForExitCode waits until the exit code of the container process
+// is recorded, it gives up after timeout if the monitor has finished
+// without recording it
+func (c *container) waitForExitCode(timeout time.Duration) {
+	deadline := time.Now().Add(timeout)
+
+	for !c.exists(exitFile) && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// exitStatus returns the exit code of a finished command,
+// processes killed by a signal return 128 + signal
+func exitStatus(err error) int {
+	if err == nil {
+		return 0
+	}
+
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		if ws, ok := exitErr.Sys().(syscall.WaitStatus); ok {
+			if ws.Signaled() {
+				return 128 + int(ws.Signal())
+			}
+			return ws.ExitStatus()
+		}
+	}
+
+	return 255
+}
@@ -16,79 +16,316 @@ package tests
 
 import (
 	"bytes"
+	"context"
 	"flag"
+	"io"
+	"os"
 	"os/exec"
+	"sync"
 	"syscall"
 	"time"
 )
 
-// Runtime is the path of Clear Containers Runtime
+// Runtime is the path of the OCI runtime, by default Clear Containers Runtime,
+// CurrentRuntime describes its capabilities
 var Runtime string
 
-// Timeout specifies the time limit in seconds for each test
-var Timeout int
+// Timeout specifies the time limit of the commands,
+// OperationTimeout gives the time limit of an operation
+var Timeout = 5 * time.Second
+
+// operationTimeouts are the time limits of the operations that
+// need more or less time than Timeout, e.g. docker-pull
+var operationTimeouts = map[string]time.Duration{
+	// the collectors of the artifacts, journalctl reads
+	// the journal since the start of the suite
+	"artifacts":     time.Minute,
+	"docker-attach": 15 * time.Second,
+	"docker-export": 5 * time.Minute,
+	"docker-load":   5 * time.Minute,
+	"docker-pull":   10 * time.Minute,
+	"docker-push":   10 * time.Minute,
+	"docker-rmi":    15 * time.Second,
+	// docker waits 10 seconds before killing the container
+	"docker-stop": 15 * time.Second,
+	// tar extracts the rootfs exported by docker export
+	"tar": 5 * time.Minute,
+}
+
+// waitDelay is the time to wait for the output pipes to be closed once
+// the command has exited, processes that inherited them (e.g. a daemonized
+// shim) must not block the command forever
+var waitDelay = 5 * time.Second
 
 // Command contains the information of the command to run
 type Command struct {
 	// cmd exec.Cmd
 	cmd *exec.Cmd
 
-	// Timeout is the time limit of seconds of the command
+	// Timeout is the time limit of the command
 	Timeout time.Duration
+
+	// Env are the environment variables added to
+	// the environment of the command, e.g. FOO=bar
+	Env []string
+
+	// Stdout, if not nil, receives a copy of the command's stdout
+	// while the command is running
+	Stdout io.Writer
+
+	// Stderr, if not nil, receives a copy of the command's stderr
+	// while the command is running
+	Stderr io.Writer
 }
 
 func init() {
-	flag.StringVar(&Runtime, "runtime", "cc-runtime", "Path of Clear Containers Runtime")
-	flag.IntVar(&Timeout, "timeout", 5, "Time limit in seconds for each test")
-
-	flag.Parse()
+	flag.StringVar(&Runtime, suiteFlag("runtime"), "cc-runtime", "Path of the OCI runtime")
+	flag.Var(timeoutValue{&Timeout}, suiteFlag("timeout"), "Time limit of the commands, in seconds or as a duration (e.g. 1m30s)")
+	flag.Var(timeoutsValue{operationTimeouts}, suiteFlag("operation-timeout"), "Comma separated time limits of the operations, e.g. docker-pull=20m")
 }
 
 // NewCommand returns a new instance of Command
 func NewCommand(path string, args ...string) *Command {
 	c := new(Command)
 	c.cmd = exec.Command(path, args...)
-	c.Timeout = time.Duration(Timeout)
+	c.Timeout = Timeout
 
 	return c
 }
 
+// OperationTimeout returns the time limit of the operation,
+// Timeout if it has no specific time limit
+func OperationTimeout(operation string) time.Duration {
+	if timeout, ok := operationTimeouts[operation]; ok {
+		return timeout
+	}
+
+	return Timeout
+}
+
+// CommandResult describes the outcome of a command
+type CommandResult struct {
+	// Stdout is the standard output of the command
+	Stdout string
+
+	// Stderr is the standard error of the command
+	Stderr string
+
+	// ExitCode is the exit code of the command, -1 if the command
+	// could not be started or was terminated by a signal
+	ExitCode int
+
+	// Signal is the signal that terminated the command,
+	// 0 if the command was not terminated by a signal
+	Signal syscall.Signal
+
+	// TimedOut is true if the command was killed because its
+	// time limit was reached
+	TimedOut bool
+
+	// Start is the time when the command was started
+	Start time.Time
+
+	// Duration is the wall-clock time the command took to complete
+	Duration time.Duration
+}
+
+// values returns the stdout, stderr and exit code of the result
+func (r *CommandResult) values() (string, string, int) {
+	return r.Stdout, r.Stderr, r.ExitCode
+}
+
 // Run runs a command returning its stdout, stderr and exit code
 func (c *Command) Run() (string, string, int) {
+	return c.RunResult().values()
+}
+
+// RunResult runs a command honouring its Timeout and returns its result
+func (c *Command) RunResult() *CommandResult {
+	ctx := context.Background()
+
+	if c.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.Timeout)
+		defer cancel()
+	}
+
+	return c.RunContext(ctx)
+}
+
+// RunContext runs a command and returns its result.
+// The command runs in its own process group, if the context is done before
+// the command exits, the whole process group is killed and the output
+// produced so far is returned with -1 as exit code.
+func (c *Command) RunContext(ctx context.Context) *CommandResult {
 	LogIfFail("Running command '%s %s'\n", c.cmd.Path, c.cmd.Args)
 
 	var stdout, stderr bytes.Buffer
-	c.cmd.Stdout = &stdout
-	c.cmd.Stderr = &stderr
 
-	if err := c.cmd.Start(); err != nil {
+	result := &CommandResult{
+		ExitCode: -1,
+		Start:    time.Now(),
+	}
+
+	stdoutPipe, err := newOutputPipe(teeWriter(&stdout, c.Stdout))
+	if err != nil {
 		LogIfFail("could no start command: %v\n", err)
+		recordCommand(c, result, err)
+		return result
 	}
 
-	done := make(chan error)
-	go func() { done <- c.cmd.Wait() }()
+	stderrPipe, err := newOutputPipe(teeWriter(&stderr, c.Stderr))
+	if err != nil {
+		stdoutPipe.close()
+		LogIfFail("could no start command: %v\n", err)
+		recordCommand(c, result, err)
+		return result
+	}
 
-	var timeout <-chan time.Time
-	if c.Timeout > 0 {
-		timeout = time.After(c.Timeout * time.Second)
+	c.cmd.Stdout = stdoutPipe.w
+	c.cmd.Stderr = stderrPipe.w
+	c.cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+
+	if len(c.Env) > 0 {
+		c.cmd.Env = append(os.Environ(), c.Env...)
 	}
 
+	err = c.cmd.Start()
+
+	// the command has its own copy of the write ends
+	stdoutPipe.w.Close()
+	stderrPipe.w.Close()
+
+	if err != nil {
+		stdoutPipe.close()
+		stderrPipe.close()
+		LogIfFail("could no start command: %v\n", err)
+		recordCommand(c, result, err)
+		return result
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- c.cmd.Wait() }()
+
 	select {
-	case <-timeout:
-		LogIfFail("Killing process timeout reached '%d' seconds\n", c.Timeout)
-		_ = c.cmd.Process.Kill()
-		return "", "", -1
+	case <-ctx.Done():
+		LogIfFail("Killing process group: %v\n", ctx.Err())
+
+		// a negative pid means the whole process group
+		_ = syscall.Kill(-c.cmd.Process.Pid, syscall.SIGKILL)
+		<-done
+
+		result.TimedOut = ctx.Err() == context.DeadlineExceeded
 
 	case err := <-done:
 		if err != nil {
 			LogIfFail("command failed error '%s'\n", err)
 		}
+	}
+
+	// the processes that inherited the output pipes
+	// can keep them open after the command exits
+	expired := make(chan struct{})
+	timer := time.AfterFunc(waitDelay, func() { close(expired) })
+	stdoutPipe.wait(expired)
+	stderrPipe.wait(expired)
+	timer.Stop()
+
+	result.Duration = time.Since(result.Start)
+	result.Stdout = stdout.String()
+	result.Stderr = stderr.String()
+
+	status := c.cmd.ProcessState.Sys().(syscall.WaitStatus)
+	result.ExitCode = status.ExitStatus()
+	if status.Signaled() {
+		result.Signal = status.Signal()
+	}
+
+	LogIfFail("%+v\nTimeout: %s\nTimed out: %t\nDuration: %s\nExit Code: %d\nSignal: %d\nStdout: %s\nStderr: %s\n",
+		c.cmd.Args, c.Timeout, result.TimedOut, result.Duration, result.ExitCode,
+		result.Signal, result.Stdout, result.Stderr)
+
+	recordCommand(c, result, nil)
+
+	return result
+}
+
+// teeWriter returns a writer that writes to buf and, if not nil, to w
+func teeWriter(buf *bytes.Buffer, w io.Writer) io.Writer {
+	if w == nil {
+		return buf
+	}
+
+	return io.MultiWriter(buf, w)
+}
 
-		exitCode := c.cmd.ProcessState.Sys().(syscall.WaitStatus).ExitStatus()
+// outputPipe is a pipe receiving the output of a command, what the
+// command writes to it is copied to a writer until it is closed
+type outputPipe struct {
+	r *os.File
+	w *os.File
 
-		LogIfFail("%+v\nTimeout: %d seconds\nExit Code: %d\nStdout: %s\nStderr: %s\n",
-			c.cmd.Args, c.Timeout, exitCode, stdout.String(), stderr.String())
+	// done is closed once the copy is done
+	done chan struct{}
 
-		return stdout.String(), stderr.String(), exitCode
+	// mutex protects out and closed, once the pipe is closed
+	// what the copy reads is dropped
+	mutex  sync.Mutex
+	out    io.Writer
+	closed bool
+}
+
+func newOutputPipe(out io.Writer) (*outputPipe, error) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+
+	p := &outputPipe{
+		r:    r,
+		w:    w,
+		out:  out,
+		done: make(chan struct{}),
+	}
+
+	go func() {
+		_, _ = io.Copy(p, r)
+		close(p.done)
+	}()
+
+	return p, nil
+}
+
+// Write writes to the writer of the pipe until it is closed
+func (p *outputPipe) Write(b []byte) (int, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.closed {
+		return len(b), nil
 	}
+
+	return p.out.Write(b)
+}
+
+// wait waits until every writer has closed the pipe or expired
+// is closed, then closes the pipe keeping the output written so far
+func (p *outputPipe) wait(expired <-chan struct{}) {
+	select {
+	case <-p.done:
+	case <-expired:
+	}
+
+	p.close()
+}
+
+// close closes the pipe, the copy may still be blocked reading it
+// until the processes that inherited it exit but its output is dropped
+func (p *outputPipe) close() {
+	p.mutex.Lock()
+	p.closed = true
+	p.mutex.Unlock()
+
+	p.w.Close()
+	p.r.Close()
 }
@@ -0,0 +1,90 @@
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tests
+
+import (
+	"bytes"
+	"context"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCommandRun(t *testing.T) {
+	assert := assert.New(t)
+
+	cmd := NewCommand("sh", "-c", "echo out; echo err >&2; exit 3")
+	stdout, stderr, exitCode := cmd.Run()
+
+	assert.Equal("out\n", stdout)
+	assert.Equal("err\n", stderr)
+	assert.Equal(3, exitCode)
+}
+
+func TestCommandRunResultTee(t *testing.T) {
+	assert := assert.New(t)
+
+	var stdout bytes.Buffer
+	cmd := NewCommand("echo", "hello")
+	cmd.Stdout = &stdout
+
+	result := cmd.RunResult()
+	assert.Equal(0, result.ExitCode)
+	assert.Equal("hello\n", result.Stdout)
+	assert.Equal("hello\n", stdout.String())
+	assert.False(result.TimedOut)
+}
+
+func TestCommandTimeout(t *testing.T) {
+	assert := assert.New(t)
+
+	cmd := NewCommand("sh", "-c", "echo started; sleep 30")
+	cmd.Timeout = time.Second
+
+	result := cmd.RunResult()
+	assert.True(result.TimedOut)
+	assert.Equal(syscall.SIGKILL, result.Signal)
+	assert.Equal("started\n", result.Stdout)
+	assert.True(result.Duration < 10*time.Second)
+}
+
+func TestCommandRunContextCancel(t *testing.T) {
+	assert := assert.New(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	result := NewCommand("sleep", "30").RunContext(ctx)
+	assert.False(result.TimedOut)
+	assert.Equal(syscall.SIGKILL, result.Signal)
+}
+
+func TestCommandInheritedOutput(t *testing.T) {
+	assert := assert.New(t)
+
+	defer func(delay time.Duration) { waitDelay = delay }(waitDelay)
+	waitDelay = 100 * time.Millisecond
+
+	// the background sleep keeps the output pipes open
+	cmd := NewCommand("sh", "-c", "sleep 3 & echo done")
+	cmd.Timeout = 0
+
+	result := cmd.RunResult()
+	assert.Equal(0, result.ExitCode)
+	assert.Equal("done\n", result.Stdout)
+	assert.True(result.Duration < 3*time.Second)
+}
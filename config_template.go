@@ -0,0 +1,275 @@
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tests
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	spec "github.com/opencontainers/specs/specs-go"
+)
+
+const (
+	// DefaultConfigTemplate is the name of the template used when
+	// no other template has been selected
+	DefaultConfigTemplate = "default"
+
+	// MinimalConfigTemplate only contains the settings needed to run a container
+	MinimalConfigTemplate = "minimal"
+
+	// PrivilegedConfigTemplate runs containers with all the capabilities
+	// and devices, and without masked or read-only paths
+	PrivilegedConfigTemplate = "privileged"
+
+	// ReadonlyConfigTemplate runs containers with a read-only rootfs,
+	// only /tmp is writable
+	ReadonlyConfigTemplate = "readonly"
+
+	// configTemplateEnv is the environment variable used when the
+	// -config-template option is not specified
+	configTemplateEnv = "CC_TESTS_CONFIG_TEMPLATE"
+
+	// configTemplateNameEnv is the environment variable used when
+	// the -config-template-name option is not specified
+	configTemplateNameEnv = "CC_TESTS_CONFIG_TEMPLATE_NAME"
+)
+
+// configTemplatePath is the path of a config.json that replaces
+// the default one as base of all the templates
+var configTemplatePath string
+
+// ConfigTemplate modifies the base configuration of the bundles
+type ConfigTemplate func(config *spec.Spec)
+
+var configTemplates = struct {
+	sync.Mutex
+
+	// current is the name of the template used by new bundles,
+	// selected with the -config-template-name option or with
+	// UseConfigTemplate
+	current string
+
+	templates map[string]ConfigTemplate
+}{
+	current: DefaultConfigTemplate,
+	templates: map[string]ConfigTemplate{
+		DefaultConfigTemplate:    func(*spec.Spec) {},
+		MinimalConfigTemplate:    minimalConfig,
+		PrivilegedConfigTemplate: privilegedConfig,
+		ReadonlyConfigTemplate:   readonlyConfig,
+	},
+}
+
+func init() {
+	flag.StringVar(&configTemplatePath, suiteFlag("config-template"), os.Getenv(configTemplateEnv), "Path of the config.json used as base of the bundles")
+	flag.StringVar(&configTemplates.current, suiteFlag("config-template-name"), configTemplateName(), "Name of the config template of the bundles (default, minimal, privileged, readonly)")
+}
+
+// allCapabilities is the list of capabilities given to privileged containers
+var allCapabilities = []string{
+	"CAP_AUDIT_CONTROL", "CAP_AUDIT_READ", "CAP_AUDIT_WRITE", "CAP_BLOCK_SUSPEND",
+	"CAP_CHOWN", "CAP_DAC_OVERRIDE", "CAP_DAC_READ_SEARCH", "CAP_FOWNER",
+	"CAP_FSETID", "CAP_IPC_LOCK", "CAP_IPC_OWNER", "CAP_KILL", "CAP_LEASE",
+	"CAP_LINUX_IMMUTABLE", "CAP_MAC_ADMIN", "CAP_MAC_OVERRIDE", "CAP_MKNOD",
+	"CAP_NET_ADMIN", "CAP_NET_BIND_SERVICE", "CAP_NET_BROADCAST", "CAP_NET_RAW",
+	"CAP_SETFCAP", "CAP_SETGID", "CAP_SETPCAP", "CAP_SETUID", "CAP_SYS_ADMIN",
+	"CAP_SYS_BOOT", "CAP_SYS_CHROOT", "CAP_SYS_MODULE", "CAP_SYS_NICE",
+	"CAP_SYS_PACCT", "CAP_SYS_PTRACE", "CAP_SYS_RAWIO", "CAP_SYS_RESOURCE",
+	"CAP_SYS_TIME", "CAP_SYS_TTY_CONFIG", "CAP_SYSLOG", "CAP_WAKE_ALARM",
+}
+
+// RegisterConfigTemplate adds a named template, an existing
+// template with the same name is replaced
+func RegisterConfigTemplate(name string, template ConfigTemplate) {
+	configTemplates.Lock()
+	defer configTemplates.Unlock()
+
+	configTemplates.templates[name] = template
+}
+
+// UseConfigTemplate selects the template used by the bundles created
+// from now on, suites usually call it from BeforeSuite
+func UseConfigTemplate(name string) error {
+	configTemplates.Lock()
+	defer configTemplates.Unlock()
+
+	if _, ok := configTemplates.templates[name]; !ok {
+		return fmt.Errorf("unknown config template '%s', available templates: %s",
+			name, strings.Join(configTemplateNames(), ", "))
+	}
+
+	configTemplates.current = name
+
+	return nil
+}
+
+// configTemplateName returns the name of the template selected by
+// the environment, the default template if there is none
+func configTemplateName() string {
+	if name := os.Getenv(configTemplateNameEnv); name != "" {
+		return name
+	}
+
+	return DefaultConfigTemplate
+}
+
+// currentConfigTemplate returns the template used by new bundles
+func currentConfigTemplate() (ConfigTemplate, error) {
+	configTemplates.Lock()
+	defer configTemplates.Unlock()
+
+	template, ok := configTemplates.templates[configTemplates.current]
+	if !ok {
+		return nil, fmt.Errorf("unknown config template '%s', available templates: %s",
+			configTemplates.current, strings.Join(configTemplateNames(), ", "))
+	}
+
+	return template, nil
+}
+
+// configTemplateNames returns the sorted names of the registered
+// templates, configTemplates must be locked
+func configTemplateNames() []string {
+	var names []string
+	for name := range configTemplates.templates {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// newConfig returns a new configuration created from the current template
+func newConfig() (*spec.Spec, error) {
+	template, err := currentConfigTemplate()
+	if err != nil {
+		return nil, err
+	}
+
+	content := []byte(defaultConfig)
+
+	if configTemplatePath != "" {
+		content, err = ioutil.ReadFile(configTemplatePath)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var config spec.Spec
+	if err := json.Unmarshal(content, &config); err != nil {
+		return nil, err
+	}
+
+	template(&config)
+
+	return &config, nil
+}
+
+func minimalConfig(config *spec.Spec) {
+	var env []string
+	for _, e := range config.Process.Env {
+		if strings.HasPrefix(e, "PATH=") {
+			env = append(env, e)
+		}
+	}
+
+	*config = spec.Spec{
+		Version:  config.Version,
+		Platform: config.Platform,
+		Process: spec.Process{
+			Env: env,
+			Cwd: "/",
+		},
+		Root: spec.Root{
+			Path: config.Root.Path,
+		},
+		Mounts: []spec.Mount{
+			{
+				Destination: "/proc",
+				Type:        "proc",
+				Source:      "proc",
+			},
+		},
+		Linux: &spec.Linux{
+			Namespaces: []spec.LinuxNamespace{
+				{Type: spec.PIDNamespace},
+				{Type: spec.MountNamespace},
+			},
+		},
+	}
+}
+
+func privilegedConfig(config *spec.Spec) {
+	config.Process.NoNewPrivileges = false
+	config.Process.Capabilities = &spec.LinuxCapabilities{
+		Bounding:    allCapabilities,
+		Effective:   allCapabilities,
+		Inheritable: allCapabilities,
+		Permitted:   allCapabilities,
+	}
+
+	// sysfs and cgroups are writable
+	for i, m := range config.Mounts {
+		var options []string
+		for _, o := range m.Options {
+			if o != "ro" {
+				options = append(options, o)
+			}
+		}
+		config.Mounts[i].Options = options
+	}
+
+	if config.Linux == nil {
+		config.Linux = &spec.Linux{}
+	}
+
+	config.Linux.MaskedPaths = nil
+	config.Linux.ReadonlyPaths = nil
+
+	if config.Linux.Resources == nil {
+		config.Linux.Resources = &spec.LinuxResources{}
+	}
+
+	config.Linux.Resources.Devices = []spec.LinuxDeviceCgroup{
+		{
+			Allow:  true,
+			Access: "rwm",
+		},
+	}
+}
+
+func readonlyConfig(config *spec.Spec) {
+	config.Root.Readonly = true
+
+	for _, m := range config.Mounts {
+		if m.Destination == "/tmp" {
+			return
+		}
+	}
+
+	config.Mounts = append(config.Mounts, spec.Mount{
+		Destination: "/tmp",
+		Type:        "tmpfs",
+		Source:      "tmpfs",
+		Options:     []string{"nosuid", "nodev", "mode=1777"},
+	})
+}
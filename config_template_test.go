@@ -0,0 +1,46 @@
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tests
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDefaultConfig(t *testing.T) {
+	assert := assert.New(t)
+
+	content, err := ioutil.ReadFile("data/config.json")
+	assert.NoError(err)
+	assert.Equal(string(content), defaultConfig, "config_default.go must be updated with data/config.json")
+}
+
+func TestConfigTemplateName(t *testing.T) {
+	assert := assert.New(t)
+
+	defer func(current string) { configTemplates.current = current }(configTemplates.current)
+
+	configTemplates.current = ReadonlyConfigTemplate
+	config, err := newConfig()
+	assert.NoError(err)
+	assert.True(config.Root.Readonly)
+
+	configTemplates.current = "unknown"
+	_, err = newConfig()
+	assert.Error(err)
+	assert.Contains(err.Error(), "minimal, privileged, readonly")
+}
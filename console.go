@@ -0,0 +1,428 @@
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tests
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+	"unsafe"
+
+	"github.com/clearcontainers/tests/pty"
+	"golang.org/x/sys/unix"
+)
+
+// ctrlC is the default interrupt character of the terminals
+const ctrlC = "\x03"
+
+// Console is a pseudo terminal pair, the slave is the terminal of the
+// container process and the spec reads and writes the master.
+// Reads of the master fail with EIO once all the slave ends are closed.
+type Console struct {
+	master    *os.File
+	slavePath string
+
+	// fd is the descriptor of the master, taken once when the console
+	// is opened: os.File.Fd puts the file in blocking mode and closing
+	// the file does not interrupt the reads anymore
+	fd int
+
+	// wake is the self pipe polled by the reads along with the master,
+	// Close closes its write end to wake them up
+	wake [2]int
+
+	// readLock is held by the reads, Close takes it once they are
+	// woken up before closing the descriptors they poll
+	readLock  sync.RWMutex
+	closed    bool
+	closeOnce sync.Once
+
+	// deadline is the read deadline, zero if the reads do not time out
+	deadline time.Time
+}
+
+// errConsoleClosed is returned by the reads of a closed console
+var errConsoleClosed = fmt.Errorf("console closed")
+
+// consoleTimeoutError is returned by the reads of the
+// console once the read deadline is reached
+type consoleTimeoutError struct{}
+
+func (consoleTimeoutError) Error() string {
+	return "console read deadline reached"
+}
+
+// Timeout is true, like the timeout errors of the net package
+func (consoleTimeoutError) Timeout() bool {
+	return true
+}
+
+// winsize is the struct winsize of the TIOCGWINSZ and TIOCSWINSZ ioctls
+type winsize struct {
+	Row    uint16
+	Col    uint16
+	Xpixel uint16
+	Ypixel uint16
+}
+
+// NewConsole allocates a new pseudo terminal pair
+func NewConsole() (*Console, error) {
+	master, slavePath, err := pty.Open()
+	if err != nil {
+		return nil, err
+	}
+
+	console, err := newConsole(master, slavePath)
+	if err != nil {
+		master.Close()
+		return nil, err
+	}
+
+	return console, nil
+}
+
+func newConsole(master *os.File, slavePath string) (*Console, error) {
+	wake := make([]int, 2)
+	if err := unix.Pipe2(wake, unix.O_CLOEXEC|unix.O_NONBLOCK); err != nil {
+		return nil, err
+	}
+
+	return &Console{
+		master:    master,
+		slavePath: slavePath,
+		fd:        int(master.Fd()),
+		wake:      [2]int{wake[0], wake[1]},
+	}, nil
+}
+
+// consoleFromMaster returns the console of the pseudo terminal master
+func consoleFromMaster(master *os.File) (*Console, error) {
+	slavePath, err := pty.Ptsname(master)
+	if err != nil {
+		return nil, err
+	}
+
+	return newConsole(master, slavePath)
+}
+
+// SlavePath returns the path of the slave, e.g. /dev/pts/3
+func (c *Console) SlavePath() string {
+	return c.slavePath
+}
+
+// Master returns the master of the pseudo terminal
+func (c *Console) Master() *os.File {
+	return c.master
+}
+
+// Read reads from the master, it fails with a timeout error once the
+// read deadline is reached and is interrupted when the console is closed
+func (c *Console) Read(b []byte) (int, error) {
+	c.readLock.RLock()
+	defer c.readLock.RUnlock()
+
+	if c.closed {
+		return 0, errConsoleClosed
+	}
+
+	for {
+		if err := waitReadable(c.fd, c.wake[0], c.deadline); err != nil {
+			return 0, err
+		}
+
+		n, err := unix.Read(c.fd, b)
+		if err == unix.EAGAIN || err == unix.EINTR {
+			continue
+		}
+
+		if err != nil {
+			return 0, &os.PathError{Op: "read", Path: c.master.Name(), Err: err}
+		}
+
+		if n == 0 && len(b) > 0 {
+			return 0, io.EOF
+		}
+
+		return n, nil
+	}
+}
+
+// Write writes to the master, i.e. types in the terminal
+func (c *Console) Write(b []byte) (int, error) {
+	return c.master.Write(b)
+}
+
+// SetReadDeadline sets the deadline of the reads of the master,
+// a zero time means that the reads do not time out
+func (c *Console) SetReadDeadline(t time.Time) error {
+	c.deadline = t
+	return nil
+}
+
+// Interrupt types Ctrl-C in the terminal
+func (c *Console) Interrupt() error {
+	_, err := c.master.Write([]byte(ctrlC))
+	return err
+}
+
+// Resize sets the window size of the terminal, the foreground
+// process of the terminal gets a SIGWINCH
+func (c *Console) Resize(rows, cols uint16) error {
+	ws := winsize{
+		Row: rows,
+		Col: cols,
+	}
+
+	return c.ioctl(unix.TIOCSWINSZ, uintptr(unsafe.Pointer(&ws)))
+}
+
+// Size returns the window size of the terminal
+func (c *Console) Size() (rows, cols uint16, err error) {
+	var ws winsize
+	if err := c.ioctl(unix.TIOCGWINSZ, uintptr(unsafe.Pointer(&ws))); err != nil {
+		return 0, 0, err
+	}
+
+	return ws.Row, ws.Col, nil
+}
+
+func (c *Console) ioctl(request, data uintptr) error {
+	if _, _, errno := unix.Syscall(unix.SYS_IOCTL, uintptr(c.fd), request, data); errno != 0 {
+		return errno
+	}
+
+	return nil
+}
+
+// Close closes the master, the pending reads return errConsoleClosed
+func (c *Console) Close() error {
+	var err error
+
+	c.closeOnce.Do(func() {
+		unix.Close(c.wake[1])
+
+		c.readLock.Lock()
+		defer c.readLock.Unlock()
+
+		c.closed = true
+		unix.Close(c.wake[0])
+		err = c.master.Close()
+	})
+
+	return err
+}
+
+// AttachConsole allocates a console for the container, the container
+// process gets a terminal and the slave is passed with --console
+func (c *Container) AttachConsole() (*Console, error) {
+	if err := c.withTerminal(); err != nil {
+		return nil, err
+	}
+
+	console, err := NewConsole()
+	if err != nil {
+		return nil, err
+	}
+
+	slavePath := console.SlavePath()
+	c.Console = &slavePath
+	c.ConsoleSocket = nil
+
+	return console, nil
+}
+
+// AttachConsoleSocket creates a console socket for the container, the
+// container process gets a terminal and the socket is passed with
+// --console-socket, Accept returns the console once the container is created
+func (c *Container) AttachConsoleSocket() (*ConsoleSocket, error) {
+	if err := c.withTerminal(); err != nil {
+		return nil, err
+	}
+
+	socket, err := NewConsoleSocket()
+	if err != nil {
+		return nil, err
+	}
+
+	socketPath := socket.Path()
+	c.ConsoleSocket = &socketPath
+	c.Console = nil
+
+	return socket, nil
+}
+
+func (c *Container) withTerminal() error {
+	if c.Bundle == nil {
+		return nil
+	}
+
+	return c.Bundle.WithTerminal(true).ValidateAndSave()
+}
+
+// ConsoleSocket is the unix socket given to the runtime with
+// --console-socket, the runtime sends the master of the
+// pseudo terminal it allocates for the container
+type ConsoleSocket struct {
+	dir      string
+	listener *net.UnixListener
+}
+
+// consoleSocketName is the name of the socket in its directory,
+// socket paths are limited to 108 bytes
+const consoleSocketName = "console.sock"
+
+// NewConsoleSocket creates a console socket in a new temporary directory
+func NewConsoleSocket() (*ConsoleSocket, error) {
+	nodeDir, err := NodeDir()
+	if err != nil {
+		return nil, err
+	}
+
+	dir, err := ioutil.TempDir(nodeDir, "console")
+	if err != nil {
+		return nil, err
+	}
+
+	addr := &net.UnixAddr{
+		Name: filepath.Join(dir, consoleSocketName),
+		Net:  "unix",
+	}
+
+	listener, err := net.ListenUnix("unix", addr)
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+
+	return &ConsoleSocket{
+		dir:      dir,
+		listener: listener,
+	}, nil
+}
+
+// Path returns the path of the socket
+func (s *ConsoleSocket) Path() string {
+	return filepath.Join(s.dir, consoleSocketName)
+}
+
+// Accept waits for the runtime to send the master of the pseudo terminal
+func (s *ConsoleSocket) Accept(timeout time.Duration) (*Console, error) {
+	if err := s.listener.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return nil, err
+	}
+
+	conn, err := s.listener.AcceptUnix()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	// the runtime sends the name of the terminal and its fd
+	name := make([]byte, 4096)
+	oob := make([]byte, unix.CmsgSpace(4))
+
+	n, oobn, _, _, err := conn.ReadMsgUnix(name, oob)
+	if err != nil {
+		return nil, err
+	}
+
+	msgs, err := unix.ParseSocketControlMessage(oob[:oobn])
+	if err != nil {
+		return nil, err
+	}
+
+	if len(msgs) != 1 {
+		return nil, fmt.Errorf("expected 1 control message, got %d", len(msgs))
+	}
+
+	fds, err := unix.ParseUnixRights(&msgs[0])
+	if err != nil {
+		return nil, err
+	}
+
+	if len(fds) != 1 {
+		for _, fd := range fds {
+			unix.Close(fd)
+		}
+		return nil, fmt.Errorf("expected 1 file descriptor, got %d", len(fds))
+	}
+
+	master := os.NewFile(uintptr(fds[0]), string(name[:n]))
+
+	console, err := consoleFromMaster(master)
+	if err != nil {
+		master.Close()
+		return nil, err
+	}
+
+	return console, nil
+}
+
+// Close closes the socket and removes its directory
+func (s *ConsoleSocket) Close() error {
+	err := s.listener.Close()
+
+	if rmErr := os.RemoveAll(s.dir); err == nil {
+		err = rmErr
+	}
+
+	return err
+}
+
+// waitReadable waits until fd can be read, the deadline is reached or
+// wake is closed. A zero deadline waits forever, the reads of the
+// console do not rely on the deadlines of os.File
+func waitReadable(fd, wake int, deadline time.Time) error {
+	fds := []unix.PollFd{
+		{Fd: int32(fd), Events: unix.POLLIN},
+		{Fd: int32(wake), Events: unix.POLLIN},
+	}
+
+	for {
+		timeout := -1
+		if !deadline.IsZero() {
+			remaining := deadline.Sub(time.Now())
+			if remaining <= 0 {
+				return consoleTimeoutError{}
+			}
+
+			// round up, a timeout of less than a millisecond does not wait
+			timeout = int((remaining + time.Millisecond - 1) / time.Millisecond)
+		}
+
+		n, err := unix.Poll(fds, timeout)
+		if err == unix.EINTR {
+			continue
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if fds[1].Revents != 0 {
+			return errConsoleClosed
+		}
+
+		// POLLHUP and POLLERR are reported by the read
+		if n > 0 {
+			return nil
+		}
+	}
+}
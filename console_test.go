@@ -0,0 +1,197 @@
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tests
+
+import (
+	"bytes"
+	"net"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/sys/unix"
+)
+
+const testConsoleTimeout = 5 * time.Second
+
+// readConsoleUntil reads the console until its output contains s
+func readConsoleUntil(c *Console, s string) (string, error) {
+	if err := c.SetReadDeadline(time.Now().Add(testConsoleTimeout)); err != nil {
+		return "", err
+	}
+
+	var out bytes.Buffer
+	buf := make([]byte, 1024)
+
+	for !strings.Contains(out.String(), s) {
+		n, err := c.Read(buf)
+		out.Write(buf[:n])
+		if err != nil {
+			return out.String(), err
+		}
+	}
+
+	return out.String(), nil
+}
+
+func TestConsole(t *testing.T) {
+	assert := assert.New(t)
+
+	console, err := NewConsole()
+	if !assert.NoError(err) {
+		return
+	}
+	defer console.Close()
+
+	assert.True(strings.HasPrefix(console.SlavePath(), "/dev/pts/"))
+
+	slave, err := os.OpenFile(console.SlavePath(), os.O_RDWR|unix.O_NOCTTY, 0)
+	if !assert.NoError(err) {
+		return
+	}
+	defer slave.Close()
+
+	_, err = slave.Write([]byte("hello\n"))
+	assert.NoError(err)
+
+	// onlcr is disabled, there is no \r
+	out, err := readConsoleUntil(console, "\n")
+	assert.NoError(err)
+	assert.Equal("hello\n", out)
+
+	assert.NoError(console.Resize(40, 120))
+	rows, cols, err := console.Size()
+	assert.NoError(err)
+	assert.Equal(uint16(40), rows)
+	assert.Equal(uint16(120), cols)
+}
+
+func TestConsoleCloseInterruptsRead(t *testing.T) {
+	assert := assert.New(t)
+
+	console, err := NewConsole()
+	if !assert.NoError(err) {
+		return
+	}
+
+	// the slave stays open, the reads do not fail with EIO
+	slave, err := os.OpenFile(console.SlavePath(), os.O_RDWR|unix.O_NOCTTY, 0)
+	if !assert.NoError(err) {
+		console.Close()
+		return
+	}
+	defer slave.Close()
+
+	readErr := make(chan error)
+	go func() {
+		_, err := console.Read(make([]byte, 16))
+		readErr <- err
+	}()
+
+	// let the read block
+	time.Sleep(100 * time.Millisecond)
+	assert.NoError(console.Close())
+
+	select {
+	case err := <-readErr:
+		assert.Equal(errConsoleClosed, err)
+	case <-time.After(testConsoleTimeout):
+		assert.Fail("Close did not interrupt Read")
+	}
+
+	_, err = console.Read(make([]byte, 16))
+	assert.Equal(errConsoleClosed, err)
+	assert.NoError(console.Close())
+}
+
+func TestConsoleSocket(t *testing.T) {
+	assert := assert.New(t)
+
+	socket, err := NewConsoleSocket()
+	if !assert.NoError(err) {
+		return
+	}
+
+	runtimeConsole, err := NewConsole()
+	if !assert.NoError(err) {
+		return
+	}
+	defer runtimeConsole.Close()
+
+	// send the master as runtimes do
+	sent := make(chan struct{})
+	go func() {
+		defer close(sent)
+
+		conn, err := net.Dial("unix", socket.Path())
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		rights := unix.UnixRights(int(runtimeConsole.Master().Fd()))
+		conn.(*net.UnixConn).WriteMsgUnix([]byte(runtimeConsole.SlavePath()), rights, nil)
+	}()
+
+	console, err := socket.Accept(testConsoleTimeout)
+	<-sent
+	if !assert.NoError(err) {
+		return
+	}
+	defer console.Close()
+
+	assert.Equal(runtimeConsole.SlavePath(), console.SlavePath())
+
+	assert.NoError(socket.Close())
+	_, err = os.Stat(socket.Path())
+	assert.True(os.IsNotExist(err))
+}
+
+func TestContainerConsole(t *testing.T) {
+	assert := assert.New(t)
+
+	c := newTestContainer(t, "sh", "-c",
+		`stty size; trap "echo interrupted; exit 3" INT; echo ready; while :; do sleep 1; done`)
+	defer c.Teardown()
+
+	console, err := c.AttachConsole()
+	if !assert.NoError(err) {
+		return
+	}
+	defer console.Close()
+
+	assert.True(c.Bundle.Config.Process.Terminal)
+	assert.NoError(console.Resize(40, 120))
+
+	_, stderr, exitCode := c.Create()
+	assert.Equal(0, exitCode, stderr)
+
+	_, stderr, exitCode = c.Start()
+	assert.Equal(0, exitCode, stderr)
+
+	out, err := readConsoleUntil(console, "ready")
+	assert.NoError(err)
+	assert.Contains(out, "40 120")
+
+	assert.NoError(console.Interrupt())
+
+	_, err = readConsoleUntil(console, "interrupted")
+	assert.NoError(err)
+
+	_, err = c.WaitForState("stopped", testStateTimeout)
+	assert.NoError(err)
+}
@@ -15,6 +15,7 @@
 package tests
 
 import (
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -22,6 +23,9 @@ import (
 	"strconv"
 	"strings"
 	"syscall"
+	"time"
+
+	spec "github.com/opencontainers/specs/specs-go"
 )
 
 // Container represents a clear container
@@ -34,6 +38,11 @@ type Container struct {
 	// if nil then try to run the container without --console option
 	Console *string
 
+	// ConsoleSocket is the path of the unix socket the runtime sends
+	// the console master to, if nil then try to run the container
+	// without --console-socket option
+	ConsoleSocket *string
+
 	// PidFile where process id is written
 	// if nil then try to run the container without --pid-file option
 	PidFile *string
@@ -42,6 +51,10 @@ type Container struct {
 	// if nil then try to run the container without --log option
 	LogFile *string
 
+	// LogFormat is the format of LogFile, text or json
+	// if nil then try to run the container without --log-format option
+	LogFormat *string
+
 	// Detach allows to run the process detached from the shell
 	Detach bool
 
@@ -50,13 +63,43 @@ type Container struct {
 	ID *string
 }
 
+// stateInterval is the time between state polls of WaitForState
+const stateInterval = 100 * time.Millisecond
+
 // Process describes a process to be executed on a running container.
+// Processes are run with Container.ExecResult, which returns their
+// CommandResult, or with Container.Exec.
 type Process struct {
-	ContainerID *string
-	Console     *string
-	Tty         *string
-	Detach      bool
-	Workload    []string
+	ContainerID   *string
+	Console       *string
+	ConsoleSocket *string
+	Tty           *string
+	Detach        bool
+	Workload      []string
+
+	// PidFile where process id is written
+	// if nil then try to exec the process without --pid-file option
+	PidFile *string
+
+	// Spec is the full description of the process, if not nil it
+	// is written to a file passed with the --process option and
+	// Workload is ignored, the short flags below are still passed
+	Spec *spec.Process
+
+	// Env variables in the form KEY=value passed with --env
+	Env []string
+
+	// Cwd passed with --cwd
+	Cwd string
+
+	// User in the form uid[:gid] passed with --user
+	User string
+
+	// Capabilities passed with --cap
+	Capabilities []string
+
+	// NoNewPrivileges passes --no-new-privs
+	NoNewPrivileges bool
 }
 
 // NewContainer returns a new Container
@@ -72,6 +115,16 @@ func NewContainer(workload []string, detach bool) (*Container, error) {
 	logFile := filepath.Join(b.Path, "log")
 	id := RandID(20)
 
+	// the cgroups of the container are created below a known parent
+	// so that SnapshotHostState finds them when they are leaked
+	if b.Config.Linux != nil && b.Config.Linux.CgroupsPath == "" {
+		b.Config.Linux.CgroupsPath = filepath.Join("/", containerCgroupParent, id)
+		if err := b.ValidateAndSave(); err != nil {
+			b.Remove()
+			return nil, err
+		}
+	}
+
 	return &Container{
 		Bundle:  b,
 		Console: &console,
@@ -85,12 +138,22 @@ func NewContainer(workload []string, detach bool) (*Container, error) {
 // Run the container
 // calls to run command returning its stdout, stderr and exit code
 func (c *Container) Run() (string, string, int) {
+	return c.RunResult().values()
+}
+
+// RunResult runs the container
+// calls to run command returning its result
+func (c *Container) RunResult() *CommandResult {
 	args := []string{}
 
 	if c.LogFile != nil {
 		args = append(args, "--log", *c.LogFile)
 	}
 
+	if c.LogFormat != nil {
+		args = append(args, "--log-format", *c.LogFormat)
+	}
+
 	args = append(args, "run")
 
 	if c.Bundle != nil {
@@ -101,6 +164,10 @@ func (c *Container) Run() (string, string, int) {
 		args = append(args, "--console", *c.Console)
 	}
 
+	if c.ConsoleSocket != nil {
+		args = append(args, "--console-socket", *c.ConsoleSocket)
+	}
+
 	if c.PidFile != nil {
 		args = append(args, "--pid-file", *c.PidFile)
 	}
@@ -113,14 +180,291 @@ func (c *Container) Run() (string, string, int) {
 		args = append(args, *c.ID)
 	}
 
-	cmd := NewCommand(Runtime, args...)
+	cmd := NewRuntimeCommand(args...)
+
+	return cmd.RunResult()
+}
+
+// Create the container
+// calls to create command returning its stdout, stderr and exit code
+func (c *Container) Create() (string, string, int) {
+	return c.CreateResult().values()
+}
+
+// CreateResult creates the container
+// calls to create command returning its result
+func (c *Container) CreateResult() *CommandResult {
+	args := []string{}
+
+	if c.LogFile != nil {
+		args = append(args, "--log", *c.LogFile)
+	}
+
+	if c.LogFormat != nil {
+		args = append(args, "--log-format", *c.LogFormat)
+	}
+
+	args = append(args, "create")
+
+	if c.Bundle != nil {
+		args = append(args, "--bundle", c.Bundle.Path)
+	}
+
+	if c.Console != nil {
+		args = append(args, "--console", *c.Console)
+	}
+
+	if c.ConsoleSocket != nil {
+		args = append(args, "--console-socket", *c.ConsoleSocket)
+	}
+
+	if c.PidFile != nil {
+		args = append(args, "--pid-file", *c.PidFile)
+	}
+
+	if c.ID != nil {
+		args = append(args, *c.ID)
+	}
+
+	cmd := NewRuntimeCommand(args...)
+
+	return cmd.RunResult()
+}
+
+// Start the container
+// calls to start command returning its stdout, stderr and exit code
+func (c *Container) Start() (string, string, int) {
+	return c.StartResult().values()
+}
+
+// StartResult starts the container
+// calls to start command returning its result
+func (c *Container) StartResult() *CommandResult {
+	return c.runWithID("start")
+}
+
+// State returns the state of the container
+// calls to state command parsing its output
+func (c *Container) State() (*spec.State, error) {
+	result := c.StateResult()
+	if result.ExitCode != 0 {
+		return nil, fmt.Errorf("failed to get state of container: %s", result.Stderr)
+	}
+
+	var state spec.State
+	if err := json.Unmarshal([]byte(result.Stdout), &state); err != nil {
+		return nil, fmt.Errorf("failed to parse state of container: %v", err)
+	}
+
+	return &state, nil
+}
+
+// WaitForState polls the state of the container until its status is
+// the given one (e.g. created, running, stopped) or the timeout expires,
+// on timeout the error reports the last state observed
+func (c *Container) WaitForState(status string, timeout time.Duration) (*spec.State, error) {
+	deadline := time.Now().Add(timeout)
+
+	for {
+		state, err := c.State()
+		if err == nil && state.Status == status {
+			return state, nil
+		}
+
+		if time.Now().After(deadline) {
+			if err != nil {
+				return state, fmt.Errorf("container did not reach the %s state in %s: %v", status, timeout, err)
+			}
+
+			return state, fmt.Errorf("container did not reach the %s state in %s, last state: %s",
+				status, timeout, state.Status)
+		}
+
+		time.Sleep(stateInterval)
+	}
+}
+
+// StateResult gets the state of the container
+// calls to state command returning its result
+func (c *Container) StateResult() *CommandResult {
+	return c.runWithID("state")
+}
+
+// Pause the container
+// calls to pause command returning its stdout, stderr and exit code
+func (c *Container) Pause() (string, string, int) {
+	return c.PauseResult().values()
+}
+
+// PauseResult pauses the container
+// calls to pause command returning its result
+func (c *Container) PauseResult() *CommandResult {
+	return c.runWithID("pause")
+}
+
+// Resume the container
+// calls to resume command returning its stdout, stderr and exit code
+func (c *Container) Resume() (string, string, int) {
+	return c.ResumeResult().values()
+}
+
+// ResumeResult resumes the container
+// calls to resume command returning its result
+func (c *Container) ResumeResult() *CommandResult {
+	return c.runWithID("resume")
+}
+
+// Ps lists the processes running in the container
+// format can be table or json, psArgs are passed to ps
+// calls to ps command returning its stdout, stderr and exit code
+func (c *Container) Ps(format string, psArgs ...string) (string, string, int) {
+	return c.PsResult(format, psArgs...).values()
+}
+
+// PsResult lists the processes running in the container
+// format can be table or json, psArgs are passed to ps
+// calls to ps command returning its result
+func (c *Container) PsResult(format string, psArgs ...string) *CommandResult {
+	args := []string{"ps"}
+
+	if format != "" {
+		args = append(args, "--format", format)
+	}
+
+	if c.ID != nil {
+		args = append(args, *c.ID)
+	}
+
+	args = append(args, psArgs...)
+
+	cmd := NewRuntimeCommand(args...)
+
+	return cmd.RunResult()
+}
+
+// Events displays the container events
+// if stats is true only the statistics are displayed once, else the
+// events are displayed every interval until the command times out
+// calls to events command returning its stdout, stderr and exit code
+func (c *Container) Events(stats bool, interval time.Duration) (string, string, int) {
+	return c.EventsResult(stats, interval).values()
+}
+
+// EventsResult displays the container events
+// if stats is true only the statistics are displayed once, else the
+// events are displayed every interval until the command times out
+// calls to events command returning its result
+func (c *Container) EventsResult(stats bool, interval time.Duration) *CommandResult {
+	args := []string{"events"}
+
+	if stats {
+		args = append(args, "--stats")
+	}
+
+	if interval > 0 {
+		args = append(args, "--interval", interval.String())
+	}
+
+	if c.ID != nil {
+		args = append(args, *c.ID)
+	}
+
+	cmd := NewRuntimeCommand(args...)
+
+	return cmd.RunResult()
+}
+
+// Update the container resources
+// calls to update command returning its stdout, stderr and exit code
+func (c *Container) Update(resources *spec.LinuxResources) (string, string, int) {
+	return c.UpdateResult(resources).values()
+}
+
+// UpdateResult updates the container resources
+// the resources are written in a file passed with the --resources option
+// calls to update command returning its result
+func (c *Container) UpdateResult(resources *spec.LinuxResources) *CommandResult {
+	args := []string{"update"}
+
+	if resources != nil {
+		resourcesFile, err := writeJSONFile(c.tmpDir(), "resources", resources)
+		if err != nil {
+			LogIfFail("could not write resources file: %v\n", err)
+			return &CommandResult{ExitCode: -1}
+		}
+		defer os.Remove(resourcesFile)
+
+		args = append(args, "--resources", resourcesFile)
+	}
+
+	if c.ID != nil {
+		args = append(args, *c.ID)
+	}
+
+	cmd := NewRuntimeCommand(args...)
+
+	return cmd.RunResult()
+}
+
+// runWithID runs a runtime command that only takes the container ID
+func (c *Container) runWithID(command string) *CommandResult {
+	args := []string{command}
+
+	if c.ID != nil {
+		args = append(args, *c.ID)
+	}
+
+	cmd := NewRuntimeCommand(args...)
+
+	return cmd.RunResult()
+}
+
+// tmpDir returns the directory where the temporary files
+// of the container are created
+func (c *Container) tmpDir() string {
+	if c.Bundle != nil {
+		return c.Bundle.Path
+	}
+
+	dir, err := NodeDir()
+	if err != nil {
+		return tmpDir
+	}
+
+	return dir
+}
+
+// writeJSONFile writes v as JSON in a new temporary file in dir
+// returning the path of the file
+func writeJSONFile(dir, prefix string, v interface{}) (string, error) {
+	content, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+
+	f, err := ioutil.TempFile(dir, prefix)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	if _, err := f.Write(content); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
 
-	return cmd.Run()
+	return f.Name(), nil
 }
 
 // Delete the container
 // calls to delete command returning its stdout, stderr and exit code
 func (c *Container) Delete(force bool) (string, string, int) {
+	return c.DeleteResult(force).values()
+}
+
+// DeleteResult deletes the container
+// calls to delete command returning its result
+func (c *Container) DeleteResult(force bool) *CommandResult {
 	args := []string{"delete"}
 
 	if force {
@@ -131,14 +475,20 @@ func (c *Container) Delete(force bool) (string, string, int) {
 		args = append(args, *c.ID)
 	}
 
-	cmd := NewCommand(Runtime, args...)
+	cmd := NewRuntimeCommand(args...)
 
-	return cmd.Run()
+	return cmd.RunResult()
 }
 
 // Kill the container
 // calls to kill command returning its stdout, stderr and exit code
 func (c *Container) Kill(all bool, signal interface{}) (string, string, int) {
+	return c.KillResult(all, signal).values()
+}
+
+// KillResult kills the container
+// calls to kill command returning its result
+func (c *Container) KillResult(all bool, signal interface{}) *CommandResult {
 	args := []string{"kill"}
 
 	if all {
@@ -156,26 +506,40 @@ func (c *Container) Kill(all bool, signal interface{}) (string, string, int) {
 		args = append(args, t)
 	}
 
-	cmd := NewCommand(Runtime, args...)
+	cmd := NewRuntimeCommand(args...)
 
-	return cmd.Run()
+	return cmd.RunResult()
 }
 
 // Exec the container
 // calls into exec command returning its stdout, stderr and exit code
 func (c *Container) Exec(process Process) (string, string, int) {
+	return c.ExecResult(process).values()
+}
+
+// ExecResult executes a process in the container
+// calls into exec command returning its result
+func (c *Container) ExecResult(process Process) *CommandResult {
 	args := []string{}
 
 	if c.LogFile != nil {
 		args = append(args, "--log", *c.LogFile)
 	}
 
+	if c.LogFormat != nil {
+		args = append(args, "--log-format", *c.LogFormat)
+	}
+
 	args = append(args, "exec")
 
 	if process.Console != nil {
 		args = append(args, "--console", *process.Console)
 	}
 
+	if process.ConsoleSocket != nil {
+		args = append(args, "--console-socket", *process.ConsoleSocket)
+	}
+
 	if process.Tty != nil {
 		args = append(args, "--tty", *process.Tty)
 	}
@@ -184,20 +548,79 @@ func (c *Container) Exec(process Process) (string, string, int) {
 		args = append(args, "--detach")
 	}
 
+	if process.PidFile != nil {
+		args = append(args, "--pid-file", *process.PidFile)
+	}
+
+	if process.Spec != nil {
+		processFile, err := writeJSONFile(c.tmpDir(), "process", process.Spec)
+		if err != nil {
+			LogIfFail("could not write process file: %v\n", err)
+			return &CommandResult{ExitCode: -1}
+		}
+		defer os.Remove(processFile)
+
+		args = append(args, "--process", processFile)
+	}
+
+	for _, e := range process.Env {
+		args = append(args, "--env", e)
+	}
+
+	if process.Cwd != "" {
+		args = append(args, "--cwd", process.Cwd)
+	}
+
+	if process.User != "" {
+		args = append(args, "--user", process.User)
+	}
+
+	for _, capability := range process.Capabilities {
+		args = append(args, "--cap", capability)
+	}
+
+	if process.NoNewPrivileges {
+		args = append(args, "--no-new-privs")
+	}
+
 	if process.ContainerID != nil {
 		args = append(args, *process.ContainerID)
 	}
 
-	args = append(args, process.Workload...)
+	if process.Spec == nil {
+		args = append(args, process.Workload...)
+	}
 
-	cmd := NewCommand(Runtime, args...)
+	cmd := NewRuntimeCommand(args...)
 
-	return cmd.Run()
+	return cmd.RunResult()
+}
+
+// NewProcessSpec returns the description of a process that runs workload
+// with the same settings (env, cwd, user...) as the container process
+func (c *Container) NewProcessSpec(workload []string) *spec.Process {
+	var process spec.Process
+
+	if c.Bundle != nil && c.Bundle.Config != nil {
+		process = c.Bundle.Config.Process
+	}
+
+	process.Env = append([]string{}, process.Env...)
+	process.Args = workload
+	process.Terminal = false
+
+	return &process
 }
 
 // List the containers
 // calls to list command returning its stdout, stderr and exit code
 func (c *Container) List(format string, quiet bool, all bool) (string, string, int) {
+	return c.ListResult(format, quiet, all).values()
+}
+
+// ListResult lists the containers
+// calls to list command returning its result
+func (c *Container) ListResult(format string, quiet bool, all bool) *CommandResult {
 	args := []string{"list"}
 
 	if format != "" {
@@ -212,15 +635,15 @@ func (c *Container) List(format string, quiet bool, all bool) (string, string, i
 		args = append(args, "--all")
 	}
 
-	cmd := NewCommand(Runtime, args...)
+	cmd := NewRuntimeCommand(args...)
 
-	return cmd.Run()
+	return cmd.RunResult()
 }
 
 // SetWorkload sets a workload for the container
 func (c *Container) SetWorkload(workload []string) error {
 	c.Bundle.Config.Process.Args = workload
-	return c.Bundle.Save()
+	return c.Bundle.ValidateAndSave()
 }
 
 // RemoveOption removes a specific option
@@ -232,6 +655,8 @@ func (c *Container) RemoveOption(option string) error {
 		c.Bundle = nil
 	case "--console":
 		c.Console = nil
+	case "--console-socket":
+		c.ConsoleSocket = nil
 	case "--pid-file":
 		c.PidFile = nil
 	default:
@@ -274,10 +699,14 @@ func (c *Container) Teardown() error {
 // Exist returns true if any of next cases is true:
 // - list command shows the container
 // - the process id specified in the pid file is running (cc-shim)
-// - the VM is running (qemu)
+// - the VM is running (qemu), only for VM based runtimes
 // else false is returned
 func (c *Container) Exist() bool {
-	return c.isListed() || c.isWorkloadRunning() || IsVMRunning(*c.ID)
+	if c.isListed() || c.isWorkloadRunning() {
+		return true
+	}
+
+	return CurrentRuntime().Capabilities().VMBased && IsVMRunning(*c.ID)
 }
 
 func (c *Container) isListed() bool {
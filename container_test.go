@@ -0,0 +1,141 @@
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tests
+
+import (
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testStateTimeout = 5 * time.Second
+
+func TestContainerLifecycle(t *testing.T) {
+	assert := assert.New(t)
+
+	c := newTestContainer(t, "sleep", "30")
+	defer c.Teardown()
+
+	_, stderr, exitCode := c.Create()
+	assert.Equal(0, exitCode, stderr)
+
+	state, err := c.State()
+	assert.NoError(err)
+	assert.Equal(*c.ID, state.ID)
+	assert.Equal("created", state.Status)
+	assert.Equal(c.Bundle.Path, state.Bundle)
+
+	content, err := ioutil.ReadFile(*c.PidFile)
+	assert.NoError(err)
+	assert.Equal(strconv.Itoa(state.Pid), string(content))
+
+	_, stderr, exitCode = c.Start()
+	assert.Equal(0, exitCode, stderr)
+
+	_, err = c.WaitForState("running", testStateTimeout)
+	assert.NoError(err)
+
+	_, _, exitCode = c.Pause()
+	assert.Equal(0, exitCode)
+
+	state, err = c.State()
+	assert.NoError(err)
+	assert.Equal("paused", state.Status)
+
+	_, _, exitCode = c.Resume()
+	assert.Equal(0, exitCode)
+
+	stdout, _, exitCode := c.List("", true, false)
+	assert.Equal(0, exitCode)
+	assert.Contains(stdout, *c.ID)
+
+	_, _, exitCode = c.Kill(false, syscall.SIGKILL)
+	assert.Equal(0, exitCode)
+
+	_, err = c.WaitForState("stopped", testStateTimeout)
+	assert.NoError(err)
+
+	_, stderr, exitCode = c.Delete(false)
+	assert.Equal(0, exitCode, stderr)
+	assert.False(c.Exist())
+}
+
+func TestContainerRun(t *testing.T) {
+	assert := assert.New(t)
+
+	c := newTestContainer(t, "sh", "-c", "echo hello; exit 3")
+	defer c.Teardown()
+
+	stdout, _, exitCode := c.Run()
+	assert.Equal("hello\n", stdout)
+	assert.Equal(3, exitCode)
+	assert.False(c.Exist())
+}
+
+func TestContainerDeleteRunning(t *testing.T) {
+	assert := assert.New(t)
+
+	c := newTestContainer(t, "sleep", "30")
+	c.Detach = true
+	defer c.Teardown()
+
+	_, stderr, exitCode := c.Run()
+	assert.Equal(0, exitCode, stderr)
+
+	_, _, exitCode = c.Delete(false)
+	assert.NotEqual(0, exitCode)
+	assert.True(c.Exist())
+
+	assert.NoError(c.Teardown())
+	assert.False(c.Exist())
+}
+
+func TestContainerExec(t *testing.T) {
+	assert := assert.New(t)
+
+	c := newTestContainer(t, "sleep", "30")
+	c.Detach = true
+	c.Bundle.WithEnv("FOO=bar")
+	assert.NoError(c.Bundle.Save())
+	defer c.Teardown()
+
+	_, stderr, exitCode := c.Run()
+	assert.Equal(0, exitCode, stderr)
+
+	stdout, _, exitCode := c.Exec(Process{
+		ContainerID: c.ID,
+		Workload:    []string{"sh", "-c", "echo $FOO $BAR; exit 2"},
+		Env:         []string{"BAR=baz"},
+	})
+	assert.Equal("bar baz\n", stdout)
+	assert.Equal(2, exitCode)
+
+	spec := c.NewProcessSpec([]string{"sh", "-c", "echo $FOO"})
+	stdout, _, exitCode = c.Exec(Process{
+		ContainerID: c.ID,
+		Spec:        spec,
+	})
+	assert.Equal("bar\n", stdout)
+	assert.Equal(0, exitCode)
+
+	stdout, _, exitCode = c.Ps("json")
+	assert.Equal(0, exitCode)
+	assert.True(strings.HasPrefix(strings.TrimSpace(stdout), "["))
+}
@@ -15,13 +15,11 @@
 package tests
 
 import (
-	"fmt"
-	"strconv"
-	"strings"
+	"flag"
 	"time"
 )
 
-const (
+var (
 	// Docker command
 	Docker = "docker"
 
@@ -33,74 +31,70 @@ const (
 
 	// PostgresImage is the postgres image
 	PostgresImage = "postgres"
-
-	// timeout for docker rmi command
-	// 15 seconds should be enough to complete this task
-	dockerRmiTimeout = 15
 )
 
-func runDockerCommandWithTimeout(timeout time.Duration, command string, args ...string) (string, string, int) {
+func init() {
+	flag.StringVar(&Docker, suiteFlag("docker"), Docker, "Path of the docker command")
+	flag.StringVar(&Image, suiteFlag("busybox-image"), Image, "Image used to run containers")
+	flag.StringVar(&AlpineImage, suiteFlag("alpine-image"), AlpineImage, "Name of the alpine image")
+	flag.StringVar(&PostgresImage, suiteFlag("postgres-image"), PostgresImage, "Name of the postgres image")
+}
+
+func runDockerCommandWithTimeout(timeout time.Duration, command string, args ...string) *CommandResult {
 	a := []string{command}
 	a = append(a, args...)
 
 	cmd := NewCommand(Docker, a...)
 	cmd.Timeout = timeout
 
-	return cmd.Run()
+	result := cmd.RunResult()
+	trackDockerCommand(command, args, result)
+
+	return result
 }
 
-func runDockerCommand(command string, args ...string) (string, string, int) {
-	return runDockerCommandWithTimeout(time.Duration(Timeout), command, args...)
+func runDockerCommand(command string, args ...string) *CommandResult {
+	return runDockerCommandWithTimeout(OperationTimeout("docker-"+command), command, args...)
 }
 
 // StatusDockerContainer returns the container status
 func StatusDockerContainer(name string) string {
-	args := []string{"-a", "-f", "name=" + name, "--format", "{{.Status}}"}
-
-	stdout, _, exitCode := runDockerCommand("ps", args...)
-
-	if exitCode != 0 || stdout == "" {
+	status, err := CurrentDockerBackend().ContainerStatus(name)
+	if err != nil {
+		LogIfFail("failed to get container status: %v\n", err)
 		return ""
 	}
 
-	state := strings.Split(stdout, " ")
-	return state[0]
+	return status
 }
 
 // ExitCodeDockerContainer returns the container exit code
 func ExitCodeDockerContainer(name string) (int, error) {
-	args := []string{"--format={{.State.ExitCode}}", name}
-
-	stdout, _, exitCode := runDockerCommand("inspect", args...)
-
-	if exitCode != 0 || stdout == "" {
-		return -1, fmt.Errorf("failed to run docker inspect command")
+	container, err := InspectDockerContainer(name)
+	if err != nil {
+		return -1, err
 	}
 
-	return strconv.Atoi(strings.TrimSpace(stdout))
+	return container.State.ExitCode, nil
 }
 
 // IsRunningDockerContainer inspects a container
 // returns true if is running
 func IsRunningDockerContainer(name string) bool {
-	stdout, _, exitCode := runDockerCommand("inspect", "--format={{.State.Running}}", name)
-
-	if exitCode != 0 {
+	container, err := InspectDockerContainer(name)
+	if err != nil {
+		LogIfFail("failed to inspect container: %v\n", err)
 		return false
 	}
 
-	output := strings.TrimSpace(stdout)
-	LogIfFail("container running: " + output)
-	if output == "false" {
-		return false
-	}
+	LogIfFail("container running: %t\n", container.State.Running)
 
-	return true
+	return container.State.Running
 }
 
 // ExistDockerContainer returns true if any of next cases is true:
 // - 'docker ps -a' command shows the container
-// - the VM is running (qemu)
+// - a hypervisor process of the container is running
 // else false is returned
 func ExistDockerContainer(name string) bool {
 	state := StatusDockerContainer(name)
@@ -108,33 +102,49 @@ func ExistDockerContainer(name string) bool {
 		return true
 	}
 
-	return IsVMRunning(name)
+	processes, err := DockerContainerVMProcesses(name)
+	if err != nil {
+		LogIfFail("could not look for hypervisor processes: %v\n", err)
+		return false
+	}
+
+	return len(processes) > 0
 }
 
-// RemoveDockerContainer removes a container using docker rm -f
-func RemoveDockerContainer(name string) bool {
-	_, _, exitCode := DockerRm("-f", name)
-	if exitCode != 0 {
-		return false
+// DockerContainerVMProcesses returns the hypervisor processes still
+// running the container, e.g. to kill the VMs that docker leaked
+func DockerContainerVMProcesses(name string) ([]HypervisorProcess, error) {
+	processes, err := FindVMProcesses(name)
+	if err != nil {
+		return nil, err
 	}
 
-	return true
+	for _, p := range processes {
+		LogIfFail("%s process %d running container %s: %s\n", p.Hypervisor, p.PID, name, p.Cmdline)
+	}
+
+	return processes, nil
+}
+
+// RemoveDockerContainer removes a container using docker rm -f
+func RemoveDockerContainer(name string) bool {
+	return logDockerError(CurrentDockerBackend().RemoveContainer(name))
 }
 
 // StopDockerContainer stops a container
 func StopDockerContainer(name string) bool {
-	_, _, exitCode := DockerStop(name)
-	if exitCode != 0 {
-		return false
-	}
-
-	return true
+	return logDockerError(CurrentDockerBackend().StopContainer(name))
 }
 
 // KillDockerContainer kills a container
 func KillDockerContainer(name string) bool {
-	_, _, exitCode := DockerKill(name)
-	if exitCode != 0 {
+	return logDockerError(CurrentDockerBackend().KillContainer(name))
+}
+
+// logDockerError returns true if err is nil, else err is logged
+func logDockerError(err error) bool {
+	if err != nil {
+		LogIfFail("%v\n", err)
 		return false
 	}
 
@@ -143,132 +153,300 @@ func KillDockerContainer(name string) bool {
 
 // DockerRm removes a container
 func DockerRm(args ...string) (string, string, int) {
+	return DockerRmResult(args...).values()
+}
+
+// DockerRmResult removes a container
+func DockerRmResult(args ...string) *CommandResult {
 	return runDockerCommand("rm", args...)
 }
 
 // DockerStop stops a container
 // returns true on success else false
 func DockerStop(args ...string) (string, string, int) {
+	return DockerStopResult(args...).values()
+}
+
+// DockerStopResult stops a container
+// returns true on success else false
+func DockerStopResult(args ...string) *CommandResult {
 	// docker stop takes ~15 seconds
-	return runDockerCommandWithTimeout(15, "stop", args...)
+	return runDockerCommand("stop", args...)
 }
 
 // DockerPull downloads the specific image
 func DockerPull(args ...string) (string, string, int) {
+	return DockerPullResult(args...).values()
+}
+
+// DockerPullResult downloads the specific image
+func DockerPullResult(args ...string) *CommandResult {
 	// 10 minutes should be enough to download a image
-	return runDockerCommandWithTimeout(600, "pull", args...)
+	return runDockerCommand("pull", args...)
+}
+
+// DockerPush uploads an image to a registry
+func DockerPush(args ...string) (string, string, int) {
+	return DockerPushResult(args...).values()
+}
+
+// DockerPushResult uploads an image to a registry
+func DockerPushResult(args ...string) *CommandResult {
+	return runDockerCommand("push", args...)
+}
+
+// DockerTag creates a tag that refers to an image
+func DockerTag(args ...string) (string, string, int) {
+	return DockerTagResult(args...).values()
+}
+
+// DockerTagResult creates a tag that refers to an image
+func DockerTagResult(args ...string) *CommandResult {
+	return runDockerCommand("tag", args...)
+}
+
+// DockerLoad loads images from an archive written by docker save
+func DockerLoad(args ...string) (string, string, int) {
+	return DockerLoadResult(args...).values()
+}
+
+// DockerLoadResult loads images from an archive written by docker save
+func DockerLoadResult(args ...string) *CommandResult {
+	return runDockerCommand("load", args...)
 }
 
 // DockerRun runs a container
 func DockerRun(args ...string) (string, string, int) {
+	return DockerRunResult(args...).values()
+}
+
+// DockerRunResult runs a container
+func DockerRunResult(args ...string) *CommandResult {
 	return runDockerCommand("run", args...)
 }
 
 // DockerKill kills a container
 func DockerKill(args ...string) (string, string, int) {
+	return DockerKillResult(args...).values()
+}
+
+// DockerKillResult kills a container
+func DockerKillResult(args ...string) *CommandResult {
 	return runDockerCommand("kill", args...)
 }
 
 // DockerVolume manages volumes
 func DockerVolume(args ...string) (string, string, int) {
+	return DockerVolumeResult(args...).values()
+}
+
+// DockerVolumeResult manages volumes
+func DockerVolumeResult(args ...string) *CommandResult {
 	return runDockerCommand("volume", args...)
 }
 
 // DockerAttach attach to a running container
 func DockerAttach(args ...string) (string, string, int) {
+	return DockerAttachResult(args...).values()
+}
+
+// DockerAttachResult attach to a running container
+func DockerAttachResult(args ...string) *CommandResult {
 	// 15 seconds should be enough to wait for the container workload
-	return runDockerCommandWithTimeout(15, "attach", args...)
+	return runDockerCommand("attach", args...)
 }
 
 // DockerCommit creates a new image from a container's changes
 func DockerCommit(args ...string) (string, string, int) {
+	return DockerCommitResult(args...).values()
+}
+
+// DockerCommitResult creates a new image from a container's changes
+func DockerCommitResult(args ...string) *CommandResult {
 	return runDockerCommand("commit", args...)
 }
 
 // DockerImages list images
 func DockerImages(args ...string) (string, string, int) {
+	return DockerImagesResult(args...).values()
+}
+
+// DockerImagesResult list images
+func DockerImagesResult(args ...string) *CommandResult {
 	return runDockerCommand("images", args...)
 }
 
 // DockerRmi removes one or more images
 func DockerRmi(args ...string) (string, string, int) {
+	return DockerRmiResult(args...).values()
+}
+
+// DockerRmiResult removes one or more images
+func DockerRmiResult(args ...string) *CommandResult {
 	// docker takes more than 5 seconds to remove an image, it depends
 	// of the image size and this operation does not involve to the
 	// runtime
-	return runDockerCommandWithTimeout(dockerRmiTimeout, "rmi", args...)
+	return runDockerCommand("rmi", args...)
 }
 
 // DockerCp copies files/folders between a container and the local filesystem
 func DockerCp(args ...string) (string, string, int) {
+	return DockerCpResult(args...).values()
+}
+
+// DockerCpResult copies files/folders between a container and the local filesystem
+func DockerCpResult(args ...string) *CommandResult {
 	return runDockerCommand("cp", args...)
 }
 
 // DockerExec runs a command in a running container
 func DockerExec(args ...string) (string, string, int) {
+	return DockerExecResult(args...).values()
+}
+
+// DockerExecResult runs a command in a running container
+func DockerExecResult(args ...string) *CommandResult {
 	return runDockerCommand("exec", args...)
 }
 
 // DockerPs list containers
 func DockerPs(args ...string) (string, string, int) {
+	return DockerPsResult(args...).values()
+}
+
+// DockerPsResult list containers
+func DockerPsResult(args ...string) *CommandResult {
 	return runDockerCommand("ps", args...)
 }
 
 // DockerSearch searchs docker hub images
 func DockerSearch(args ...string) (string, string, int) {
+	return DockerSearchResult(args...).values()
+}
+
+// DockerSearchResult searchs docker hub images
+func DockerSearchResult(args ...string) *CommandResult {
 	return runDockerCommand("search", args...)
 }
 
 // DockerCreate creates a new container
 func DockerCreate(args ...string) (string, string, int) {
+	return DockerCreateResult(args...).values()
+}
+
+// DockerCreateResult creates a new container
+func DockerCreateResult(args ...string) *CommandResult {
 	return runDockerCommand("create", args...)
 }
 
 // DockerDiff inspect changes to files or directories on a container’s filesystem
 func DockerDiff(args ...string) (string, string, int) {
+	return DockerDiffResult(args...).values()
+}
+
+// DockerDiffResult inspect changes to files or directories on a container’s filesystem
+func DockerDiffResult(args ...string) *CommandResult {
 	return runDockerCommand("diff", args...)
 }
 
 // DockerBuild builds an image from a Dockerfile
 func DockerBuild(args ...string) (string, string, int) {
+	return DockerBuildResult(args...).values()
+}
+
+// DockerBuildResult builds an image from a Dockerfile
+func DockerBuildResult(args ...string) *CommandResult {
 	return runDockerCommand("build", args...)
 }
 
 // DockerNetwork manages networks
 func DockerNetwork(args ...string) (string, string, int) {
+	return DockerNetworkResult(args...).values()
+}
+
+// DockerNetworkResult manages networks
+func DockerNetworkResult(args ...string) *CommandResult {
 	return runDockerCommand("network", args...)
 }
 
 // DockerExport will export a container’s filesystem as a tar archive
 func DockerExport(args ...string) (string, string, int) {
+	return DockerExportResult(args...).values()
+}
+
+// DockerExportResult will export a container’s filesystem as a tar archive
+func DockerExportResult(args ...string) *CommandResult {
 	return runDockerCommand("export", args...)
 }
 
 // DockerInfo displays system-wide information
 func DockerInfo() (string, string, int) {
+	return DockerInfoResult().values()
+}
+
+// DockerInfoResult displays system-wide information
+func DockerInfoResult() *CommandResult {
 	return runDockerCommand("info")
 }
 
 // DockerSwarm manages swarm
 func DockerSwarm(args ...string) (string, string, int) {
+	return DockerSwarmResult(args...).values()
+}
+
+// DockerSwarmResult manages swarm
+func DockerSwarmResult(args ...string) *CommandResult {
 	return runDockerCommand("swarm", args...)
 }
 
 // DockerService manages services
 func DockerService(args ...string) (string, string, int) {
+	return DockerServiceResult(args...).values()
+}
+
+// DockerServiceResult manages services
+func DockerServiceResult(args ...string) *CommandResult {
 	return runDockerCommand("service", args...)
 }
 
 // DockerStart starts one or more stopped containers
 func DockerStart(args ...string) (string, string, int) {
+	return DockerStartResult(args...).values()
+}
+
+// DockerStartResult starts one or more stopped containers
+func DockerStartResult(args ...string) *CommandResult {
 	return runDockerCommand("start", args...)
 }
 
 // DockerPause pauses all processes within one or more containers
 func DockerPause(args ...string) (string, string, int) {
+	return DockerPauseResult(args...).values()
+}
+
+// DockerPauseResult pauses all processes within one or more containers
+func DockerPauseResult(args ...string) *CommandResult {
 	return runDockerCommand("pause", args...)
 }
 
 // DockerUnpause unpauses all processes within one or more containers
 func DockerUnpause(args ...string) (string, string, int) {
+	return DockerUnpauseResult(args...).values()
+}
+
+// DockerUnpauseResult unpauses all processes within one or more containers
+func DockerUnpauseResult(args ...string) *CommandResult {
 	return runDockerCommand("unpause", args...)
 }
+
+// DockerCommand runs a docker command that has no helper of its own,
+// e.g. DockerCommand("logs", name), the objects it creates are tracked
+func DockerCommand(command string, args ...string) (string, string, int) {
+	return DockerCommandResult(command, args...).values()
+}
+
+// DockerCommandResult runs a docker command that has no helper of
+// its own, the objects it creates are tracked
+func DockerCommandResult(command string, args ...string) *CommandResult {
+	return runDockerCommand(command, args...)
+}
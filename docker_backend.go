@@ -0,0 +1,350 @@
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	// DockerCLIBackend runs the docker command
+	DockerCLIBackend = "cli"
+
+	// DockerAPIBackend talks to the Engine API through the docker socket
+	DockerAPIBackend = "api"
+
+	// defaultDockerSocket is used when DOCKER_HOST is not a unix socket
+	defaultDockerSocket = "/var/run/docker.sock"
+
+	// dockerAPIVersion is the oldest Engine API version
+	// supported by the docker releases tested
+	dockerAPIVersion = "v1.24"
+)
+
+var (
+	// dockerBackendName selects the backend of the typed docker helpers
+	dockerBackendName string
+
+	// dockerSocket is the path of the docker socket used by the API backend
+	dockerSocket string
+)
+
+func init() {
+	flag.StringVar(&dockerBackendName, suiteFlag("docker-backend"), DockerCLIBackend, "Backend of the docker helpers, cli runs the docker command, api talks to the Engine API")
+	flag.StringVar(&dockerSocket, suiteFlag("docker-socket"), defaultDockerSocketPath(), "Path of the docker socket used by the api docker backend")
+}
+
+// DockerBackend performs the docker operations of the typed helpers
+// (StatusDockerContainer, RemoveDockerContainer...), the Docker*
+// helpers that take docker command line arguments always run the
+// docker command
+type DockerBackend interface {
+	// ContainerStatus returns the first word of the status shown
+	// by docker ps (e.g. Up, Exited, Created), or an empty
+	// string if the container does not exist
+	ContainerStatus(name string) (string, error)
+
+	// Inspect returns the JSON description of the object name of type
+	// objectType (container, image, network or volume)
+	Inspect(objectType, name string) (json.RawMessage, error)
+
+	// RemoveContainer removes the container, killing it if it is running
+	RemoveContainer(name string) error
+
+	// StopContainer stops the container
+	StopContainer(name string) error
+
+	// KillContainer kills the container
+	KillContainer(name string) error
+}
+
+// CurrentDockerBackend returns the backend selected
+// by the -docker-backend option
+func CurrentDockerBackend() DockerBackend {
+	if dockerBackendName == DockerAPIBackend {
+		return NewDockerAPI(dockerSocket)
+	}
+
+	return &DockerCLI{}
+}
+
+// defaultDockerSocketPath returns the socket of DOCKER_HOST
+// if it is a unix socket, else the default socket
+func defaultDockerSocketPath() string {
+	if host := os.Getenv("DOCKER_HOST"); strings.HasPrefix(host, "unix://") {
+		return strings.TrimPrefix(host, "unix://")
+	}
+
+	return defaultDockerSocket
+}
+
+// DockerCLI is the backend that runs the docker command
+type DockerCLI struct{}
+
+// ContainerStatus returns the status of the container shown by docker ps
+func (d *DockerCLI) ContainerStatus(name string) (string, error) {
+	result := runDockerCommand("ps", "-a", "-f", "name="+name, "--format", "{{.Names}} {{.Status}}")
+	if result.ExitCode != 0 {
+		return "", fmt.Errorf("docker ps failed: %s", result.Stderr)
+	}
+
+	// the name filter matches substrings of the names
+	for _, line := range strings.Split(result.Stdout, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) > 1 && fields[0] == name {
+			return fields[1], nil
+		}
+	}
+
+	return "", nil
+}
+
+// Inspect returns the description of the object shown by docker inspect
+func (d *DockerCLI) Inspect(objectType, name string) (json.RawMessage, error) {
+	result := runDockerCommand("inspect", "--type", objectType, name)
+	if result.ExitCode != 0 {
+		return nil, fmt.Errorf("docker inspect failed: %s", strings.TrimSpace(result.Stderr))
+	}
+
+	var objects []json.RawMessage
+	if err := json.Unmarshal([]byte(result.Stdout), &objects); err != nil {
+		return nil, fmt.Errorf("failed to parse docker inspect output: %v", err)
+	}
+
+	if len(objects) != 1 {
+		return nil, fmt.Errorf("docker inspect returned %d objects", len(objects))
+	}
+
+	return objects[0], nil
+}
+
+// RemoveContainer runs docker rm -f
+func (d *DockerCLI) RemoveContainer(name string) error {
+	return cliError(DockerRmResult("-f", name))
+}
+
+// StopContainer runs docker stop
+func (d *DockerCLI) StopContainer(name string) error {
+	return cliError(DockerStopResult(name))
+}
+
+// KillContainer runs docker kill
+func (d *DockerCLI) KillContainer(name string) error {
+	return cliError(DockerKillResult(name))
+}
+
+func cliError(result *CommandResult) error {
+	if result.ExitCode != 0 {
+		return fmt.Errorf("docker failed with exit code %d: %s", result.ExitCode, strings.TrimSpace(result.Stderr))
+	}
+
+	return nil
+}
+
+// DockerAPI is the backend that talks to the Engine API
+type DockerAPI struct {
+	client *http.Client
+}
+
+// NewDockerAPI returns a new DockerAPI using the unix socket path
+func NewDockerAPI(socket string) *DockerAPI {
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", socket)
+		},
+		DisableKeepAlives: true,
+	}
+
+	return &DockerAPI{
+		client: &http.Client{
+			Transport: transport,
+		},
+	}
+}
+
+// dockerAPIError is the body of the Engine API error responses
+type dockerAPIError struct {
+	Message string `json:"message"`
+}
+
+// do sends a request to the Engine API, the response body
+// is decoded in v if it is not nil, statuses in ok are not errors.
+// The path is escaped, its names are escaped with url.PathEscape
+func (d *DockerAPI) do(method, path string, query url.Values, timeout time.Duration, v interface{}, ok ...int) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	rawPath, err := url.PathUnescape(path)
+	if err != nil {
+		return 0, err
+	}
+
+	// the host is ignored, the connections go to the socket.
+	// RawPath keeps the escaped slashes of the names, e.g. of
+	// localhost:5000%2Fbusybox, which Path alone would lose
+	u := url.URL{
+		Scheme:   "http",
+		Host:     "docker",
+		Path:     "/" + dockerAPIVersion + rawPath,
+		RawPath:  "/" + dockerAPIVersion + path,
+		RawQuery: query.Encode(),
+	}
+
+	req, err := http.NewRequest(method, u.String(), nil)
+	if err != nil {
+		return 0, err
+	}
+
+	LogIfFail("Docker API request: %s %s\n", method, u.RequestURI())
+
+	// the requests are recorded in the transcript like
+	// commands, their arguments are the method and the URI
+	args := []string{method, u.RequestURI()}
+	result := &CommandResult{ExitCode: -1, Start: time.Now()}
+
+	resp, err := d.client.Do(req.WithContext(ctx))
+	var body []byte
+	if err == nil {
+		body, err = ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+	}
+
+	result.Duration = time.Since(result.Start)
+	result.TimedOut = ctx.Err() == context.DeadlineExceeded
+
+	if err != nil {
+		recordResult(args, nil, result, err)
+		return 0, err
+	}
+
+	for _, code := range ok {
+		if resp.StatusCode == code {
+			result.ExitCode = 0
+			result.Stdout = string(body)
+			recordResult(args, nil, result, nil)
+
+			if v == nil {
+				return resp.StatusCode, nil
+			}
+
+			return resp.StatusCode, json.Unmarshal(body, v)
+		}
+	}
+
+	var apiErr dockerAPIError
+	if json.Unmarshal(body, &apiErr) != nil || apiErr.Message == "" {
+		apiErr.Message = strings.TrimSpace(string(body))
+	}
+
+	result.ExitCode = 1
+	result.Stderr = fmt.Sprintf("%s (%d)", apiErr.Message, resp.StatusCode)
+	recordResult(args, nil, result, nil)
+
+	return resp.StatusCode, fmt.Errorf("%s %s: %s (%d)", method, path, apiErr.Message, resp.StatusCode)
+}
+
+func (d *DockerAPI) timeout() time.Duration {
+	return Timeout
+}
+
+// ContainerStatus returns the status of the container listed by the Engine API
+func (d *DockerAPI) ContainerStatus(name string) (string, error) {
+	filters, err := json.Marshal(map[string][]string{
+		"name": {"^/" + name + "$"},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	query := url.Values{}
+	query.Set("all", "1")
+	query.Set("filters", string(filters))
+
+	var containers []struct {
+		Names  []string
+		Status string
+	}
+
+	if _, err := d.do("GET", "/containers/json", query, d.timeout(), &containers, http.StatusOK); err != nil {
+		return "", err
+	}
+
+	for _, c := range containers {
+		for _, n := range c.Names {
+			if n == "/"+name {
+				return strings.SplitN(c.Status, " ", 2)[0], nil
+			}
+		}
+	}
+
+	return "", nil
+}
+
+// dockerInspectPaths are the Engine API paths that describe each object type
+var dockerInspectPaths = map[string]string{
+	dockerContainerType: "/containers/%s/json",
+	dockerImageType:     "/images/%s/json",
+	dockerNetworkType:   "/networks/%s",
+	dockerVolumeType:    "/volumes/%s",
+}
+
+// Inspect returns the description of the object returned by the Engine API
+func (d *DockerAPI) Inspect(objectType, name string) (json.RawMessage, error) {
+	path, ok := dockerInspectPaths[objectType]
+	if !ok {
+		return nil, fmt.Errorf("unknown docker object type %q", objectType)
+	}
+
+	var object json.RawMessage
+	if _, err := d.do("GET", fmt.Sprintf(path, url.PathEscape(name)), nil, d.timeout(), &object, http.StatusOK); err != nil {
+		return nil, err
+	}
+
+	return object, nil
+}
+
+// RemoveContainer removes the container through the Engine API
+func (d *DockerAPI) RemoveContainer(name string) error {
+	query := url.Values{}
+	query.Set("force", "1")
+
+	_, err := d.do("DELETE", "/containers/"+url.PathEscape(name), query, d.timeout(), nil, http.StatusNoContent)
+	return err
+}
+
+// StopContainer stops the container through the Engine API,
+// stopping a container that is not running is not an error
+func (d *DockerAPI) StopContainer(name string) error {
+	_, err := d.do("POST", "/containers/"+url.PathEscape(name)+"/stop", nil, OperationTimeout("docker-stop"), nil,
+		http.StatusNoContent, http.StatusNotModified)
+	return err
+}
+
+// KillContainer kills the container through the Engine API
+func (d *DockerAPI) KillContainer(name string) error {
+	_, err := d.do("POST", "/containers/"+url.PathEscape(name)+"/kill", nil, d.timeout(), nil, http.StatusNoContent)
+	return err
+}
@@ -0,0 +1,277 @@
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tests
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeDockerAPI is a stand-in of the Engine API keeping
+// the containers and the descriptions of other objects by name
+type fakeDockerAPI struct {
+	sync.Mutex
+	containers map[string]*DockerContainerInfo
+	objects    map[string]string
+	requests   []string
+}
+
+func (f *fakeDockerAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.Lock()
+	defer f.Unlock()
+
+	f.requests = append(f.requests, r.Method+" "+r.URL.Path)
+
+	// the objects are kept by escaped path, e.g. their names have slashes
+	escaped := strings.TrimPrefix(r.URL.EscapedPath(), "/"+dockerAPIVersion)
+	if object, ok := f.objects[escaped]; ok && r.Method == "GET" {
+		_, _ = w.Write([]byte(object))
+		return
+	}
+
+	path := strings.TrimPrefix(r.URL.Path, "/"+dockerAPIVersion)
+
+	if path == "/containers/json" && r.Method == "GET" {
+		var filters map[string][]string
+		if err := json.Unmarshal([]byte(r.URL.Query().Get("filters")), &filters); err != nil {
+			http.Error(w, `{"message": "invalid filters"}`, http.StatusBadRequest)
+			return
+		}
+
+		list := []map[string]interface{}{}
+		for name, container := range f.containers {
+			if filters["name"][0] != "^/"+name+"$" {
+				continue
+			}
+
+			status := "Up 2 seconds"
+			if !container.State.Running {
+				status = "Exited (0) 1 second ago"
+			}
+
+			list = append(list, map[string]interface{}{
+				"Names":  []string{"/" + name},
+				"Status": status,
+			})
+		}
+
+		_ = json.NewEncoder(w).Encode(list)
+		return
+	}
+
+	parts := strings.Split(strings.TrimPrefix(path, "/containers/"), "/")
+	container, ok := f.containers[parts[0]]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte(`{"message": "No such container: ` + parts[0] + `"}`))
+		return
+	}
+
+	switch {
+	case len(parts) == 2 && parts[1] == "json" && r.Method == "GET":
+		_ = json.NewEncoder(w).Encode(container)
+	case len(parts) == 1 && r.Method == "DELETE":
+		delete(f.containers, parts[0])
+		w.WriteHeader(http.StatusNoContent)
+	case len(parts) == 2 && parts[1] == "stop" && r.Method == "POST":
+		state := &container.State
+		if !state.Running {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		state.Running = false
+		state.Status = "exited"
+		state.ExitCode = 143
+		w.WriteHeader(http.StatusNoContent)
+	case len(parts) == 2 && parts[1] == "kill" && r.Method == "POST":
+		state := &container.State
+		if !state.Running {
+			w.WriteHeader(http.StatusConflict)
+			_, _ = w.Write([]byte(`{"message": "Container is not running"}`))
+			return
+		}
+		state.Running = false
+		state.Status = "exited"
+		state.ExitCode = 137
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// startFakeDockerAPI serves the fake Engine API on a unix socket
+func startFakeDockerAPI(t *testing.T) (*fakeDockerAPI, *DockerAPI, func()) {
+	api := &fakeDockerAPI{
+		containers: map[string]*DockerContainerInfo{
+			"running": {
+				Name:  "/running",
+				State: DockerContainerState{Status: "running", Running: true, Pid: 42},
+			},
+			"exited": {
+				Name:  "/exited",
+				State: DockerContainerState{Status: "exited", ExitCode: 3},
+			},
+		},
+		objects: map[string]string{
+			"/images/busybox/json":                  `{"Id": "sha256:abc", "RepoTags": ["busybox:latest"], "Config": {"Cmd": ["sh"]}}`,
+			"/images/localhost:5000%2Fbusybox/json": `{"Id": "sha256:def", "RepoTags": ["localhost:5000/busybox:latest"]}`,
+			"/networks/net0":                        `{"Id": "n0", "Name": "net0", "Driver": "bridge", "IPAM": {"Config": [{"Subnet": "172.18.0.0/16"}]}}`,
+			"/volumes/vol0":                         `{"Name": "vol0", "Driver": "local", "Mountpoint": "/var/lib/docker/volumes/vol0/_data"}`,
+		},
+	}
+
+	socket := filepath.Join(testDir, "docker.sock")
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatalf("failed to listen on %s: %v", socket, err)
+	}
+
+	server := httptest.NewUnstartedServer(api)
+	server.Listener = l
+	server.Start()
+
+	return api, NewDockerAPI(socket), server.Close
+}
+
+func TestDockerAPIContainerStatus(t *testing.T) {
+	assert := assert.New(t)
+
+	_, d, stop := startFakeDockerAPI(t)
+	defer stop()
+
+	status, err := d.ContainerStatus("running")
+	assert.NoError(err)
+	assert.Equal("Up", status)
+
+	status, err = d.ContainerStatus("exited")
+	assert.NoError(err)
+	assert.Equal("Exited", status)
+
+	status, err = d.ContainerStatus("missing")
+	assert.NoError(err)
+	assert.Equal("", status)
+}
+
+func TestDockerAPIInspect(t *testing.T) {
+	assert := assert.New(t)
+
+	_, d, stop := startFakeDockerAPI(t)
+	defer stop()
+
+	content, err := d.Inspect(dockerContainerType, "exited")
+	assert.NoError(err)
+	assert.Contains(string(content), `"ExitCode":3`)
+
+	content, err = d.Inspect(dockerVolumeType, "vol0")
+	assert.NoError(err)
+	assert.Contains(string(content), `"Driver": "local"`)
+
+	// the slash of the repository is escaped once
+	content, err = d.Inspect(dockerImageType, "localhost:5000/busybox")
+	assert.NoError(err)
+	assert.Contains(string(content), `"Id": "sha256:def"`)
+
+	_, err = d.Inspect(dockerContainerType, "missing")
+	assert.Error(err)
+	assert.Contains(err.Error(), "No such container: missing")
+
+	_, err = d.Inspect("plugin", "foo")
+	assert.Error(err)
+}
+
+func TestDockerAPITranscript(t *testing.T) {
+	assert := assert.New(t)
+
+	_, d, stop := startFakeDockerAPI(t)
+	defer stop()
+
+	enableTranscript(true)
+	defer enableTranscript(false)
+
+	_, err := d.Inspect(dockerContainerType, "exited")
+	assert.NoError(err)
+	_, err = d.Inspect(dockerContainerType, "missing")
+	assert.Error(err)
+
+	records := takeTranscript()
+	if !assert.Len(records, 2) {
+		return
+	}
+
+	assert.Equal([]string{"GET", "/" + dockerAPIVersion + "/containers/exited/json"}, records[0].Args)
+	assert.Equal(0, records[0].ExitCode)
+	assert.Contains(records[0].Stdout, `"ExitCode":3`)
+	assert.Empty(records[0].Env)
+
+	assert.Equal(1, records[1].ExitCode)
+	assert.Contains(records[1].Stderr, "No such container: missing (404)")
+}
+
+func TestDockerAPIStopKillRemove(t *testing.T) {
+	assert := assert.New(t)
+
+	api, d, stop := startFakeDockerAPI(t)
+	defer stop()
+
+	assert.NoError(d.StopContainer("running"))
+	assert.NoError(d.StopContainer("running"), "stopping a stopped container")
+	assert.Error(d.KillContainer("exited"))
+
+	assert.Equal(143, api.containers["running"].State.ExitCode)
+
+	assert.NoError(d.RemoveContainer("running"))
+	assert.Error(d.RemoveContainer("running"))
+
+	assert.Contains(api.requests, "DELETE /"+dockerAPIVersion+"/containers/running")
+}
+
+// useFakeDockerAPI makes the docker helpers use the fake Engine API
+func useFakeDockerAPI(t *testing.T) func() {
+	_, _, stop := startFakeDockerAPI(t)
+
+	backend, socket := dockerBackendName, dockerSocket
+	dockerBackendName, dockerSocket = DockerAPIBackend, filepath.Join(testDir, "docker.sock")
+
+	return func() {
+		dockerBackendName, dockerSocket = backend, socket
+		stop()
+	}
+}
+
+func TestDockerHelpersAPIBackend(t *testing.T) {
+	assert := assert.New(t)
+
+	defer useFakeDockerAPI(t)()
+
+	assert.Equal("Up", StatusDockerContainer("running"))
+	assert.True(IsRunningDockerContainer("running"))
+	assert.True(KillDockerContainer("running"))
+	assert.False(IsRunningDockerContainer("running"))
+
+	exitCode, err := ExitCodeDockerContainer("running")
+	assert.NoError(err)
+	assert.Equal(137, exitCode)
+
+	assert.True(RemoveDockerContainer("running"))
+	assert.Equal("", StatusDockerContainer("running"))
+}
@@ -0,0 +1,273 @@
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// docker object types accepted by docker inspect --type
+const (
+	dockerContainerType = "container"
+	dockerImageType     = "image"
+	dockerNetworkType   = "network"
+	dockerVolumeType    = "volume"
+)
+
+// The types below describe the docker inspect output, only the fields
+// used by the tests are declared, their names match the JSON keys.
+
+// DockerContainerInfo describes a docker container
+type DockerContainerInfo struct {
+	ID              string `json:"Id"`
+	Created         string
+	Path            string
+	Args            []string
+	State           DockerContainerState
+	Image           string
+	Name            string
+	RestartCount    int
+	Driver          string
+	Mounts          []DockerMountPoint
+	Config          DockerContainerConfig
+	HostConfig      DockerHostConfig
+	NetworkSettings DockerNetworkSettings
+}
+
+// DockerContainerState is the state of a docker container
+type DockerContainerState struct {
+	// Status is one of created, running, paused, restarting,
+	// removing, exited or dead
+	Status     string
+	Running    bool
+	Paused     bool
+	Restarting bool
+	OOMKilled  bool
+	Dead       bool
+	Pid        int
+	ExitCode   int
+	Error      string
+	StartedAt  string
+	FinishedAt string
+}
+
+// DockerMountPoint is a volume or a bind mount of a container
+type DockerMountPoint struct {
+	// Type is volume, bind or tmpfs
+	Type        string
+	Name        string
+	Source      string
+	Destination string
+	Driver      string
+	Mode        string
+	RW          bool
+	Propagation string
+}
+
+// DockerContainerConfig is the configuration of a container or an image
+type DockerContainerConfig struct {
+	Hostname     string
+	Domainname   string
+	User         string
+	Tty          bool
+	OpenStdin    bool
+	Env          []string
+	Cmd          []string
+	Entrypoint   []string
+	Image        string
+	WorkingDir   string
+	Labels       map[string]string
+	ExposedPorts map[string]struct{}
+	StopSignal   string
+}
+
+// DockerHostConfig is the host configuration of a container
+type DockerHostConfig struct {
+	Binds          []string
+	NetworkMode    string
+	PortBindings   map[string][]DockerPortBinding
+	RestartPolicy  DockerRestartPolicy
+	AutoRemove     bool
+	Privileged     bool
+	ReadonlyRootfs bool
+	CapAdd         []string
+	CapDrop        []string
+	DNS            []string `json:"Dns"`
+	ExtraHosts     []string
+	Runtime        string
+	Memory         int64
+	CPUShares      int64 `json:"CpuShares"`
+	NanoCPUs       int64 `json:"NanoCpus"`
+	CpusetCpus     string
+	PidsLimit      *int64
+	ShmSize        int64
+	Tmpfs          map[string]string
+	Sysctls        map[string]string
+}
+
+// DockerRestartPolicy is the restart policy of a container
+type DockerRestartPolicy struct {
+	Name              string
+	MaximumRetryCount int
+}
+
+// DockerPortBinding is a host port bound to a container port
+type DockerPortBinding struct {
+	HostIP   string `json:"HostIp"`
+	HostPort string
+}
+
+// DockerNetworkSettings are the network settings of a container
+type DockerNetworkSettings struct {
+	SandboxKey string
+	IPAddress  string
+	Gateway    string
+	MacAddress string
+
+	// Ports maps the container ports (e.g. 80/tcp) to the host ports
+	Ports map[string][]DockerPortBinding
+
+	// Networks maps the network names to the container endpoints
+	Networks map[string]DockerEndpointSettings
+}
+
+// DockerEndpointSettings describe a container endpoint in a network
+type DockerEndpointSettings struct {
+	NetworkID         string
+	EndpointID        string
+	Gateway           string
+	IPAddress         string
+	IPPrefixLen       int
+	IPv6Gateway       string
+	GlobalIPv6Address string
+	MacAddress        string
+	Aliases           []string
+}
+
+// DockerImageInfo describes a docker image
+type DockerImageInfo struct {
+	ID           string `json:"Id"`
+	RepoTags     []string
+	RepoDigests  []string
+	Parent       string
+	Created      string
+	Architecture string
+	Os           string
+	Size         int64
+	Config       DockerContainerConfig
+	RootFS       struct {
+		Type   string
+		Layers []string
+	}
+}
+
+// DockerNetworkInfo describes a docker network
+type DockerNetworkInfo struct {
+	ID         string `json:"Id"`
+	Name       string
+	Created    string
+	Scope      string
+	Driver     string
+	EnableIPv6 bool
+	Internal   bool
+	Attachable bool
+	IPAM       struct {
+		Driver string
+		Config []struct {
+			Subnet  string
+			IPRange string
+			Gateway string
+		}
+	}
+
+	// Containers maps the container IDs to their endpoints
+	Containers map[string]DockerNetworkContainer
+	Options    map[string]string
+	Labels     map[string]string
+}
+
+// DockerNetworkContainer is a container endpoint in a docker network
+type DockerNetworkContainer struct {
+	Name        string
+	EndpointID  string
+	MacAddress  string
+	IPv4Address string
+	IPv6Address string
+}
+
+// DockerVolumeInfo describes a docker volume
+type DockerVolumeInfo struct {
+	Name       string
+	Driver     string
+	Mountpoint string
+	Scope      string
+	CreatedAt  string
+	Labels     map[string]string
+	Options    map[string]string
+}
+
+// InspectDockerContainer returns the description of the container name
+func InspectDockerContainer(name string) (*DockerContainerInfo, error) {
+	var container DockerContainerInfo
+	if err := inspectDocker(dockerContainerType, name, &container); err != nil {
+		return nil, err
+	}
+
+	return &container, nil
+}
+
+// InspectDockerImage returns the description of the image name
+func InspectDockerImage(name string) (*DockerImageInfo, error) {
+	var image DockerImageInfo
+	if err := inspectDocker(dockerImageType, name, &image); err != nil {
+		return nil, err
+	}
+
+	return &image, nil
+}
+
+// InspectDockerNetwork returns the description of the network name
+func InspectDockerNetwork(name string) (*DockerNetworkInfo, error) {
+	var network DockerNetworkInfo
+	if err := inspectDocker(dockerNetworkType, name, &network); err != nil {
+		return nil, err
+	}
+
+	return &network, nil
+}
+
+// InspectDockerVolume returns the description of the volume name
+func InspectDockerVolume(name string) (*DockerVolumeInfo, error) {
+	var volume DockerVolumeInfo
+	if err := inspectDocker(dockerVolumeType, name, &volume); err != nil {
+		return nil, err
+	}
+
+	return &volume, nil
+}
+
+func inspectDocker(objectType, name string, v interface{}) error {
+	content, err := CurrentDockerBackend().Inspect(objectType, name)
+	if err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(content, v); err != nil {
+		return fmt.Errorf("failed to parse %s %s: %v", objectType, name, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,72 @@
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tests
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInspectDockerContainer(t *testing.T) {
+	assert := assert.New(t)
+
+	defer useFakeDockerAPI(t)()
+
+	container, err := InspectDockerContainer("exited")
+	assert.NoError(err)
+	assert.Equal("/exited", container.Name)
+	assert.Equal("exited", container.State.Status)
+	assert.Equal(3, container.State.ExitCode)
+
+	_, err = InspectDockerContainer("missing")
+	assert.Error(err)
+}
+
+func TestInspectDockerImage(t *testing.T) {
+	assert := assert.New(t)
+
+	defer useFakeDockerAPI(t)()
+
+	image, err := InspectDockerImage("busybox")
+	assert.NoError(err)
+	assert.Equal("sha256:abc", image.ID)
+	assert.Equal([]string{"busybox:latest"}, image.RepoTags)
+	assert.Equal([]string{"sh"}, image.Config.Cmd)
+}
+
+func TestInspectDockerNetwork(t *testing.T) {
+	assert := assert.New(t)
+
+	defer useFakeDockerAPI(t)()
+
+	network, err := InspectDockerNetwork("net0")
+	assert.NoError(err)
+	assert.Equal("bridge", network.Driver)
+	if assert.Len(network.IPAM.Config, 1) {
+		assert.Equal("172.18.0.0/16", network.IPAM.Config[0].Subnet)
+	}
+}
+
+func TestInspectDockerVolume(t *testing.T) {
+	assert := assert.New(t)
+
+	defer useFakeDockerAPI(t)()
+
+	volume, err := InspectDockerVolume("vol0")
+	assert.NoError(err)
+	assert.Equal("local", volume.Driver)
+	assert.Equal("/var/lib/docker/volumes/vol0/_data", volume.Mountpoint)
+}
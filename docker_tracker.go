@@ -0,0 +1,542 @@
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tests
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// dockerBuildIDRegexp matches the image ID printed by docker build
+var dockerBuildIDRegexp = regexp.MustCompile(`Successfully built ([0-9a-f]+)`)
+
+// dockerResource is a docker object created by the tests
+type dockerResource struct {
+	// objectType is container, volume, network or image
+	objectType string
+	name       string
+}
+
+// dockerRemoveArgs are the docker arguments that remove each object type
+var dockerRemoveArgs = map[string][]string{
+	dockerContainerType: {"rm", "-f", "-v"},
+	dockerVolumeType:    {"volume", "rm"},
+	dockerNetworkType:   {"network", "rm"},
+	dockerImageType:     {"rmi", "-f"},
+}
+
+// DockerTracker records the docker objects created through the
+// Docker* helpers and removes them in reverse order, e.g.
+//
+//	var tracker *DockerTracker
+//
+//	BeforeEach(func() {
+//		tracker = TrackDockerResources()
+//	})
+//
+//	AfterEach(func() {
+//		Expect(tracker.Cleanup()).To(Succeed())
+//	})
+//
+// Ginkgo runs AfterEach when a spec fails or panics, call
+// CleanupDockerTrackers from AfterSuite to also clean up
+// when the suite is interrupted.
+// Containers run without --name nor --detach cannot be
+// tracked, their ID is not printed by docker run.
+type DockerTracker struct {
+	sync.Mutex
+	resources []dockerResource
+}
+
+// dockerTrackers are the trackers not cleaned up yet, the
+// docker commands are recorded by the last one
+var dockerTrackers struct {
+	sync.Mutex
+	trackers []*DockerTracker
+}
+
+// TrackDockerResources returns a new tracker recording the
+// docker objects created from now on until it is cleaned up
+func TrackDockerResources() *DockerTracker {
+	t := &DockerTracker{}
+
+	dockerTrackers.Lock()
+	dockerTrackers.trackers = append(dockerTrackers.trackers, t)
+	dockerTrackers.Unlock()
+
+	return t
+}
+
+// Track records an object created without the Docker* helpers,
+// objectType is container, volume, network or image, the objects
+// already tracked are ignored
+func (t *DockerTracker) Track(objectType, name string) {
+	t.Lock()
+	defer t.Unlock()
+
+	r := dockerResource{
+		objectType: objectType,
+		name:       name,
+	}
+
+	for _, tracked := range t.resources {
+		if tracked == r {
+			return
+		}
+	}
+
+	t.resources = append(t.resources, r)
+}
+
+// Cleanup stops tracking and removes the tracked objects in reverse
+// order of creation, the objects already removed are ignored, the
+// error lists the objects that could not be removed
+func (t *DockerTracker) Cleanup() error {
+	dockerTrackers.Lock()
+	for i, tracker := range dockerTrackers.trackers {
+		if tracker == t {
+			dockerTrackers.trackers = append(dockerTrackers.trackers[:i], dockerTrackers.trackers[i+1:]...)
+			break
+		}
+	}
+	dockerTrackers.Unlock()
+
+	t.Lock()
+	resources := t.resources
+	t.resources = nil
+	t.Unlock()
+
+	var failed []string
+	for i := len(resources) - 1; i >= 0; i-- {
+		r := resources[i]
+		if err := removeDockerResource(r); err != nil {
+			failed = append(failed, fmt.Sprintf("%s %s: %v", r.objectType, r.name, err))
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to remove docker objects:\n%s", strings.Join(failed, "\n"))
+	}
+
+	return nil
+}
+
+// trackedDockerObjects returns the names of the objects of objectType
+// recorded by the trackers not cleaned up yet
+func trackedDockerObjects(objectType string) []string {
+	dockerTrackers.Lock()
+	trackers := append([]*DockerTracker{}, dockerTrackers.trackers...)
+	dockerTrackers.Unlock()
+
+	var names []string
+	for _, t := range trackers {
+		t.Lock()
+		for _, r := range t.resources {
+			if r.objectType == objectType {
+				names = append(names, r.name)
+			}
+		}
+		t.Unlock()
+	}
+
+	return names
+}
+
+// CleanupDockerTrackers cleans up all the trackers in use
+func CleanupDockerTrackers() error {
+	dockerTrackers.Lock()
+	trackers := append([]*DockerTracker{}, dockerTrackers.trackers...)
+	dockerTrackers.Unlock()
+
+	var errs []string
+	for i := len(trackers) - 1; i >= 0; i-- {
+		if err := trackers[i].Cleanup(); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("%s", strings.Join(errs, "\n"))
+	}
+
+	return nil
+}
+
+func removeDockerResource(r dockerResource) error {
+	args := append(append([]string{}, dockerRemoveArgs[r.objectType]...), r.name)
+	result := runDockerCommand(args[0], args[1:]...)
+	if result.ExitCode == 0 {
+		return nil
+	}
+
+	// removed by the spec
+	stderr := strings.ToLower(result.Stderr)
+	if strings.Contains(stderr, "no such") || strings.Contains(stderr, "not found") {
+		return nil
+	}
+
+	return fmt.Errorf("%s", strings.TrimSpace(result.Stderr))
+}
+
+// trackDockerCommand records the objects created by a successful
+// docker command in the last tracker
+func trackDockerCommand(command string, args []string, result *CommandResult) {
+	if result.ExitCode != 0 {
+		return
+	}
+
+	trackDockerResources(createdDockerResources(command, args, strings.TrimSpace(result.Stdout)))
+}
+
+// trackDockerResources records the objects in the last tracker
+func trackDockerResources(resources []dockerResource) {
+	dockerTrackers.Lock()
+	var t *DockerTracker
+	if n := len(dockerTrackers.trackers); n > 0 {
+		t = dockerTrackers.trackers[n-1]
+	}
+	dockerTrackers.Unlock()
+
+	if t == nil {
+		return
+	}
+
+	for _, r := range resources {
+		t.Track(r.objectType, r.name)
+	}
+}
+
+// createdDockerResources returns the objects created by a docker command
+func createdDockerResources(command string, args []string, stdout string) []dockerResource {
+	var resources []dockerResource
+	add := func(objectType, name string) {
+		if name != "" {
+			resources = append(resources, dockerResource{objectType, name})
+		}
+	}
+
+	switch command {
+	case "run", "create":
+		if name := dockerOptionValue(command, args, "--name"); name != "" {
+			add(dockerContainerType, name)
+		} else if command == "create" || dockerDetached(args) {
+			add(dockerContainerType, lastLine(stdout))
+		}
+	case "volume", "network":
+		objectType := dockerVolumeType
+		if command == "network" {
+			objectType = dockerNetworkType
+		}
+
+		// both print the name or ID of the new object
+		if len(args) > 0 && args[0] == "create" {
+			add(objectType, lastLine(stdout))
+		}
+	case "build":
+		tags := dockerOptionValues(command, args, "-t", "--tag")
+		for _, tag := range tags {
+			add(dockerImageType, tag)
+		}
+
+		if m := dockerBuildIDRegexp.FindStringSubmatch(stdout); len(tags) == 0 && m != nil {
+			add(dockerImageType, m[1])
+		}
+	case "commit":
+		add(dockerImageType, lastLine(stdout))
+	case "tag":
+		if len(args) > 0 {
+			add(dockerImageType, args[len(args)-1])
+		}
+	}
+
+	return resources
+}
+
+// dockerValueOptions are the options taking a value of the docker
+// commands by command, the other options are boolean. Unknown options
+// are taken as boolean so that they do not swallow the next argument,
+// a value can always be given as --name=value
+var dockerValueOptions = map[string]map[string]bool{
+	"run":    dockerRunValueOptions,
+	"create": dockerRunValueOptions,
+	"exec": {
+		"-e": true, "--env": true,
+		"-u": true, "--user": true,
+		"-w": true, "--workdir": true,
+		"--detach-keys": true,
+	},
+	"build": {
+		"-c": true, "--cpu-shares": true,
+		"-f": true, "--file": true,
+		"-m": true, "--memory": true,
+		"-t": true, "--tag": true,
+		"--add-host":      true,
+		"--build-arg":     true,
+		"--cache-from":    true,
+		"--cgroup-parent": true,
+		"--cpu-period":    true,
+		"--cpu-quota":     true,
+		"--cpuset-cpus":   true,
+		"--cpuset-mems":   true,
+		"--iidfile":       true,
+		"--isolation":     true,
+		"--label":         true,
+		"--memory-swap":   true,
+		"--network":       true,
+		"--platform":      true,
+		"--security-opt":  true,
+		"--shm-size":      true,
+		"--target":        true,
+		"--ulimit":        true,
+	},
+	"volume": {
+		"-d": true, "--driver": true,
+		"-o": true, "--opt": true,
+		"--label": true,
+		"--name":  true,
+	},
+	"network": {
+		"-d": true, "--driver": true,
+		"-o": true, "--opt": true,
+		"--aux-address": true,
+		"--config-from": true,
+		"--gateway":     true,
+		"--ip-range":    true,
+		"--ipam-driver": true,
+		"--ipam-opt":    true,
+		"--label":       true,
+		"--scope":       true,
+		"--subnet":      true,
+	},
+	"commit": {
+		"-a": true, "--author": true,
+		"-c": true, "--change": true,
+		"-m": true, "--message": true,
+	},
+}
+
+var dockerRunValueOptions = map[string]bool{
+	"-a": true, "--attach": true,
+	"-c": true, "--cpu-shares": true,
+	"-e": true, "--env": true,
+	"-h": true, "--hostname": true,
+	"-l": true, "--label": true,
+	"-m": true, "--memory": true,
+	"-p": true, "--publish": true,
+	"-u": true, "--user": true,
+	"-v": true, "--volume": true,
+	"-w": true, "--workdir": true,
+	"--add-host":            true,
+	"--blkio-weight":        true,
+	"--blkio-weight-device": true,
+	"--cap-add":             true,
+	"--cap-drop":            true,
+	"--cgroup-parent":       true,
+	"--cidfile":             true,
+	"--cpu-count":           true,
+	"--cpu-percent":         true,
+	"--cpu-period":          true,
+	"--cpu-quota":           true,
+	"--cpu-rt-period":       true,
+	"--cpu-rt-runtime":      true,
+	"--cpus":                true,
+	"--cpuset-cpus":         true,
+	"--cpuset-mems":         true,
+	"--detach-keys":         true,
+	"--device":              true,
+	"--device-cgroup-rule":  true,
+	"--device-read-bps":     true,
+	"--device-read-iops":    true,
+	"--device-write-bps":    true,
+	"--device-write-iops":   true,
+	"--dns":                 true,
+	"--dns-opt":             true,
+	"--dns-option":          true,
+	"--dns-search":          true,
+	"--entrypoint":          true,
+	"--env-file":            true,
+	"--expose":              true,
+	"--group-add":           true,
+	"--health-cmd":          true,
+	"--health-interval":     true,
+	"--health-retries":      true,
+	"--health-start-period": true,
+	"--health-timeout":      true,
+	"--init-path":           true,
+	"--ip":                  true,
+	"--ip6":                 true,
+	"--ipc":                 true,
+	"--isolation":           true,
+	"--kernel-memory":       true,
+	"--label-file":          true,
+	"--link":                true,
+	"--link-local-ip":       true,
+	"--log-driver":          true,
+	"--log-opt":             true,
+	"--mac-address":         true,
+	"--memory-reservation":  true,
+	"--memory-swap":         true,
+	"--memory-swappiness":   true,
+	"--mount":               true,
+	"--name":                true,
+	"--net":                 true,
+	"--net-alias":           true,
+	"--network":             true,
+	"--network-alias":       true,
+	"--oom-score-adj":       true,
+	"--pid":                 true,
+	"--pids-limit":          true,
+	"--platform":            true,
+	"--restart":             true,
+	"--runtime":             true,
+	"--security-opt":        true,
+	"--shm-size":            true,
+	"--stop-signal":         true,
+	"--stop-timeout":        true,
+	"--storage-opt":         true,
+	"--sysctl":              true,
+	"--tmpfs":               true,
+	"--ulimit":              true,
+	"--userns":              true,
+	"--uts":                 true,
+	"--volume-driver":       true,
+	"--volumes-from":        true,
+}
+
+// dockerWorkloadCommands are the docker commands whose first argument
+// that is not an option is followed by the workload, e.g. the image of
+// docker run, the options of the other commands can follow their
+// arguments, e.g. docker build . -t image
+var dockerWorkloadCommands = map[string]bool{
+	"run":    true,
+	"create": true,
+	"exec":   true,
+}
+
+// dockerOption is an option given to a docker command
+type dockerOption struct {
+	name  string
+	value string
+}
+
+// parseDockerOptions returns the options of the docker command in args.
+// The options of run, create and exec end at the first argument that is
+// not an option, e.g. the image of docker run, the arguments after it
+// belong to the workload. The options of the other commands end at --
+func parseDockerOptions(command string, args []string) []dockerOption {
+	var options []dockerOption
+
+	valueOptions := dockerValueOptions[command]
+
+	for i := 0; i < len(args); i++ {
+		a := args[i]
+
+		if a == "--" {
+			break
+		}
+
+		if a == "-" || !strings.HasPrefix(a, "-") {
+			if dockerWorkloadCommands[command] {
+				break
+			}
+			continue
+		}
+
+		if strings.HasPrefix(a, "--") {
+			// --name=value or --name value
+			if parts := strings.SplitN(a, "=", 2); len(parts) == 2 {
+				options = append(options, dockerOption{parts[0], parts[1]})
+			} else if !valueOptions[a] || i+1 == len(args) {
+				options = append(options, dockerOption{name: a})
+			} else {
+				i++
+				options = append(options, dockerOption{a, args[i]})
+			}
+
+			continue
+		}
+
+		// a group of short options, e.g. -it, the first one taking
+		// a value takes the rest of the group or the next argument,
+		// e.g. -p80:80 or -p 80:80
+		for j := 1; j < len(a); j++ {
+			name := "-" + a[j:j+1]
+
+			if !valueOptions[name] {
+				options = append(options, dockerOption{name: name})
+				continue
+			}
+
+			value := strings.TrimPrefix(a[j+1:], "=")
+			if value == "" && i+1 < len(args) {
+				i++
+				value = args[i]
+			}
+
+			options = append(options, dockerOption{name, value})
+			break
+		}
+	}
+
+	return options
+}
+
+// dockerOptionValue returns the value of the option name
+func dockerOptionValue(command string, args []string, name string) string {
+	values := dockerOptionValues(command, args, name)
+	if len(values) == 0 {
+		return ""
+	}
+
+	return values[len(values)-1]
+}
+
+// dockerOptionValues returns the values of the options in names,
+// given as "--name value" or "--name=value", see parseDockerOptions
+func dockerOptionValues(command string, args []string, names ...string) []string {
+	var values []string
+
+	for _, o := range parseDockerOptions(command, args) {
+		for _, n := range names {
+			if o.name == n {
+				values = append(values, o.value)
+			}
+		}
+	}
+
+	return values
+}
+
+// dockerDetached returns true if the options of docker run args
+// contain --detach or a group of short options including -d, e.g. -td
+func dockerDetached(args []string) bool {
+	for _, o := range parseDockerOptions("run", args) {
+		switch o.name {
+		case "-d":
+			return true
+		case "--detach":
+			return o.value == "" || o.value == "true"
+		}
+	}
+
+	return false
+}
+
+func lastLine(s string) string {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
@@ -0,0 +1,117 @@
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tests
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreatedDockerResources(t *testing.T) {
+	assert := assert.New(t)
+
+	data := []struct {
+		command   string
+		args      []string
+		stdout    string
+		resources []dockerResource
+	}{
+		{"run", []string{"--name", "foo", "busybox"}, "hello", []dockerResource{{dockerContainerType, "foo"}}},
+		{"run", []string{"--name=foo", "busybox"}, "", []dockerResource{{dockerContainerType, "foo"}}},
+		{"run", []string{"-td", "busybox"}, "abc123\n", []dockerResource{{dockerContainerType, "abc123"}}},
+		{"run", []string{"-t", "busybox", "echo", "hello"}, "hello\n", nil},
+		{"run", []string{"-e", "FOO=bar", "-p", "8080:80", "--detach", "busybox"}, "abc123\n", []dockerResource{{dockerContainerType, "abc123"}}},
+		{"run", []string{"--rm", "-v", "/tmp:/tmp", "--name", "foo", "-it", "busybox"}, "", []dockerResource{{dockerContainerType, "foo"}}},
+		// the options of the workload are not docker options
+		{"run", []string{"busybox", "ls", "-d", "/"}, "/\n", nil},
+		{"run", []string{"-i", "busybox", "sh", "--name", "foo"}, "", nil},
+		{"run", []string{"-e", "--name=foo", "busybox"}, "", nil},
+		{"create", []string{"busybox"}, "abc123\n", []dockerResource{{dockerContainerType, "abc123"}}},
+		{"volume", []string{"create", "--name", "vol"}, "vol\n", []dockerResource{{dockerVolumeType, "vol"}}},
+		{"volume", []string{"ls"}, "vol\n", nil},
+		{"network", []string{"create", "-d", "bridge", "net"}, "f00\n", []dockerResource{{dockerNetworkType, "f00"}}},
+		{"build", []string{"-t", "img:1", "--tag=img:2", "."}, "", []dockerResource{{dockerImageType, "img:1"}, {dockerImageType, "img:2"}}},
+		{"build", []string{".", "-t", "img:3"}, "", []dockerResource{{dockerImageType, "img:3"}}},
+		{"build", []string{"."}, "Step 1/1\nSuccessfully built 0123abcd\n", []dockerResource{{dockerImageType, "0123abcd"}}},
+		{"commit", []string{"foo", "img"}, "sha256:0123\n", []dockerResource{{dockerImageType, "sha256:0123"}}},
+		{"tag", []string{"busybox", "mybusybox"}, "", []dockerResource{{dockerImageType, "mybusybox"}}},
+		{"pull", []string{"busybox"}, "", nil},
+	}
+
+	for _, d := range data {
+		assert.Equal(d.resources, createdDockerResources(d.command, d.args, d.stdout), "%s %v", d.command, d.args)
+	}
+}
+
+func TestDockerTrackerRecords(t *testing.T) {
+	assert := assert.New(t)
+
+	outer := TrackDockerResources()
+	inner := TrackDockerResources()
+
+	trackDockerCommand("run", []string{"--name", "foo", "busybox"}, &CommandResult{})
+	trackDockerCommand("run", []string{"--name", "bar", "busybox"}, &CommandResult{ExitCode: 1})
+
+	assert.Empty(outer.resources)
+	assert.Equal([]dockerResource{{dockerContainerType, "foo"}}, inner.resources)
+
+	// the objects are tracked once
+	inner.Track(dockerContainerType, "foo")
+	assert.Len(inner.resources, 1)
+
+	// nothing to remove
+	inner.resources = nil
+	assert.NoError(inner.Cleanup())
+
+	trackDockerCommand("volume", []string{"create", "vol"}, &CommandResult{Stdout: "vol\n"})
+	assert.Equal([]dockerResource{{dockerVolumeType, "vol"}}, outer.resources)
+
+	outer.resources = nil
+	assert.NoError(CleanupDockerTrackers())
+	assert.Empty(dockerTrackers.trackers)
+}
+
+func TestParseDockerOptions(t *testing.T) {
+	assert := assert.New(t)
+
+	options := parseDockerOptions("run", []string{"-itp80:80", "-e", "A=1", "--rm", "--cpus=2", "-w", "/tmp", "busybox", "-d"})
+	assert.Equal([]dockerOption{
+		{name: "-i"},
+		{name: "-t"},
+		{"-p", "80:80"},
+		{"-e", "A=1"},
+		{name: "--rm"},
+		{"--cpus", "2"},
+		{"-w", "/tmp"},
+	}, options)
+
+	assert.False(dockerDetached([]string{"--detach=false", "busybox"}))
+	assert.True(dockerDetached([]string{"-dit", "busybox"}))
+	assert.Empty(parseDockerOptions("run", []string{"--", "-d"}))
+
+	// unknown options do not take the image as value
+	assert.Equal([]dockerOption{{name: "--init"}, {name: "--foo"}},
+		parseDockerOptions("run", []string{"--init", "--foo", "busybox", "--name", "workload"}))
+	assert.False(dockerDetached([]string{"--foo", "busybox", "-d"}))
+
+	// the options of the commands without workload follow their arguments
+	assert.Equal([]dockerOption{{name: "--no-cache"}, {"-t", "image"}, {"--label", "a=b"}},
+		parseDockerOptions("build", []string{"--no-cache", ".", "-t", "image", "--label", "a=b", "--", "-t", "other"}))
+	assert.Equal([]dockerOption{{"--driver", "local"}, {"--label", "a"}},
+		parseDockerOptions("volume", []string{"create", "--driver", "local", "data", "--label", "a"}))
+	assert.Equal([]dockerOption{{"-m", "message"}, {name: "-p"}},
+		parseDockerOptions("commit", []string{"-m", "message", "container", "image", "-p"}))
+}
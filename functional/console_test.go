@@ -0,0 +1,129 @@
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package functional
+
+import (
+	"bytes"
+	"strings"
+	"time"
+
+	. "github.com/clearcontainers/tests"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// consoleTimeout is the time the console output has to show up
+const consoleTimeout = 10 * time.Second
+
+// consoleWorkload prints the terminal and its size, then waits for Ctrl-C
+var consoleWorkload = []string{"sh", "-c",
+	`tty; stty size; trap "echo interrupted; exit 3" INT; echo ready; while true; do sleep 1; done`}
+
+// readConsoleUntil reads the console until its output contains s
+func readConsoleUntil(console *Console, s string) string {
+	Expect(console.SetReadDeadline(time.Now().Add(consoleTimeout))).To(Succeed())
+
+	var out bytes.Buffer
+	buf := make([]byte, 1024)
+
+	for !strings.Contains(out.String(), s) {
+		n, err := console.Read(buf)
+		out.Write(buf[:n])
+		Expect(err).NotTo(HaveOccurred(), "console output: %s", out.String())
+	}
+
+	return out.String()
+}
+
+var _ = Describe("console", func() {
+	var (
+		container *Container
+		console   *Console
+		err       error
+	)
+
+	BeforeEach(func() {
+		container, err = NewContainer(consoleWorkload, true)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(container).NotTo(BeNil())
+	})
+
+	AfterEach(func() {
+		Expect(container.Teardown()).To(Succeed())
+		if console != nil {
+			Expect(console.Close()).To(Succeed())
+			console = nil
+		}
+	})
+
+	startContainer := func() {
+		_, stderr, exitCode := container.Create()
+		Expect(exitCode).To(Equal(0), stderr)
+
+		_, stderr, exitCode = container.Start()
+		Expect(exitCode).To(Equal(0), stderr)
+	}
+
+	Context("passed with --console", func() {
+		BeforeEach(func() {
+			console, err = container.AttachConsole()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(console.Resize(40, 120)).To(Succeed())
+		})
+
+		It("should be the terminal of the workload", func() {
+			startContainer()
+			out := readConsoleUntil(console, "ready")
+			Expect(out).To(ContainSubstring("/dev/"))
+			Expect(out).NotTo(ContainSubstring("not a tty"))
+		})
+
+		It("should have the window size of the master", func() {
+			startContainer()
+			Expect(readConsoleUntil(console, "ready")).To(ContainSubstring("40 120"))
+		})
+
+		It("should interrupt the workload with Ctrl-C", func() {
+			startContainer()
+			readConsoleUntil(console, "ready")
+			Expect(console.Interrupt()).To(Succeed())
+			readConsoleUntil(console, "interrupted")
+
+			_, err := container.WaitForState("stopped", stateTimeout)
+			Expect(err).NotTo(HaveOccurred())
+		})
+	})
+
+	Context("sent through --console-socket", func() {
+		It("should be the terminal of the workload", func() {
+			SkipUnlessRuntime(RequiresConsoleSocket)
+
+			socket, err := container.AttachConsoleSocket()
+			Expect(err).NotTo(HaveOccurred())
+			defer socket.Close()
+
+			_, stderr, exitCode := container.Create()
+			Expect(exitCode).To(Equal(0), stderr)
+
+			console, err = socket.Accept(consoleTimeout)
+			Expect(err).NotTo(HaveOccurred())
+
+			_, stderr, exitCode = container.Start()
+			Expect(exitCode).To(Equal(0), stderr)
+
+			Expect(readConsoleUntil(console, "ready")).NotTo(ContainSubstring("not a tty"))
+		})
+	})
+})
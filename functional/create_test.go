@@ -13,3 +13,51 @@
 // limitations under the License.
 
 package functional
+
+import (
+	. "github.com/clearcontainers/tests"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("create", func() {
+	var (
+		container *Container
+		err       error
+	)
+
+	BeforeEach(func() {
+		container, err = NewContainer([]string{"true"}, false)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(container).NotTo(BeNil())
+	})
+
+	AfterEach(func() {
+		Expect(container.Teardown()).To(Succeed())
+	})
+
+	Context("a container", func() {
+		It("should be listed and not run its workload", func() {
+			_, stderr, exitCode := container.Create()
+			Expect(exitCode).To(Equal(0))
+			Expect(stderr).To(BeEmpty())
+			Expect(container.Exist()).To(BeTrue())
+
+			state, err := container.State()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(state.Status).To(Equal("created"))
+			Expect(container).To(HaveLoggedNoErrors())
+		})
+	})
+
+	Context("a container twice", func() {
+		It("should fail", func() {
+			_, _, exitCode := container.Create()
+			Expect(exitCode).To(Equal(0))
+
+			_, stderr, exitCode := container.Create()
+			Expect(exitCode).NotTo(Equal(0))
+			Expect(stderr).NotTo(BeEmpty())
+		})
+	})
+})
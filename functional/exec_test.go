@@ -16,6 +16,7 @@ package functional
 
 import (
 	"fmt"
+	"path/filepath"
 	"time"
 
 	. "github.com/clearcontainers/tests"
@@ -111,3 +112,68 @@ var _ = Describe("exec", func() {
 		execDetachOutput(true),
 	)
 })
+
+var _ = Describe("exec", func() {
+	var (
+		container *Container
+		err       error
+	)
+
+	BeforeEach(func() {
+		container, err = NewContainer(sleepingContainerWorkload, true)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(container).NotTo(BeNil())
+		_, _, exitCode := container.Run()
+		Expect(exitCode).To(Equal(0))
+	})
+
+	AfterEach(func() {
+		Expect(container.Teardown()).To(Succeed())
+	})
+
+	Context("with a process description", func() {
+		It("should use its environment, working directory and user", func() {
+			spec := container.NewProcessSpec([]string{"sh", "-c", "echo $FOO; pwd; id -u"})
+			spec.Env = append(spec.Env, "FOO=bar")
+			spec.Cwd = "/tmp"
+			spec.User.UID = 1000
+
+			stdout, stderr, exitCode := container.Exec(Process{
+				ContainerID: container.ID,
+				Spec:        spec,
+			})
+			Expect(exitCode).To(Equal(0))
+			Expect(stderr).To(BeEmpty())
+			Expect(stdout).To(Equal("bar\n/tmp\n1000\n"))
+		})
+	})
+
+	Context("with short flags", func() {
+		It("should use the environment, working directory and user", func() {
+			stdout, stderr, exitCode := container.Exec(Process{
+				ContainerID: container.ID,
+				Workload:    []string{"sh", "-c", "echo $FOO; pwd; id -u"},
+				Env:         []string{"FOO=bar"},
+				Cwd:         "/tmp",
+				User:        "1000",
+			})
+			Expect(exitCode).To(Equal(0))
+			Expect(stderr).To(BeEmpty())
+			Expect(stdout).To(Equal("bar\n/tmp\n1000\n"))
+		})
+	})
+
+	Context("with a pid file", func() {
+		It("should write the process id", func() {
+			pidFile := filepath.Join(container.Bundle.Path, "exec-pid")
+
+			_, _, exitCode := container.Exec(Process{
+				ContainerID: container.ID,
+				Workload:    []string{"true"},
+				PidFile:     &pidFile,
+			})
+			Expect(exitCode).To(Equal(0))
+			Expect(pidFile).To(BeAnExistingFile())
+		})
+	})
+})
@@ -0,0 +1,69 @@
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package functional
+
+import (
+	"os"
+	"path/filepath"
+
+	. "github.com/clearcontainers/tests"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// cgroupRoot returns the hierarchy where the leaked cgroup is created,
+// the memory hierarchy in cgroup v1 or the unified one in cgroup v2
+func cgroupRoot() string {
+	root := "/sys/fs/cgroup"
+	if _, err := os.Stat(filepath.Join(root, "memory")); err == nil {
+		return filepath.Join(root, "memory")
+	}
+
+	return root
+}
+
+var _ = Describe("host state", func() {
+	var (
+		container *Container
+		err       error
+	)
+
+	BeforeEach(func() {
+		container, err = NewContainer([]string{"true"}, false)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(container).NotTo(BeNil())
+	})
+
+	AfterEach(func() {
+		Expect(container.Teardown()).To(Succeed())
+	})
+
+	Context("with a leaked cgroup", func() {
+		It("should report the cgroup of the container", func() {
+			before, err := SnapshotHostState()
+			Expect(err).NotTo(HaveOccurred())
+
+			// simulate a runtime that does not remove the cgroup
+			cgroup := filepath.Join(cgroupRoot(), container.Bundle.Config.Linux.CgroupsPath)
+			Expect(os.MkdirAll(cgroup, 0755)).To(Succeed())
+			defer os.Remove(cgroup)
+
+			err = CheckHostState(before)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("cgroup: "))
+			Expect(err.Error()).To(ContainSubstring(*container.ID))
+		})
+	})
+})
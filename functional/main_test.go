@@ -15,6 +15,7 @@
 package functional
 
 import (
+	. "github.com/clearcontainers/tests"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
@@ -26,7 +27,31 @@ const (
 	shouldNotFail = false
 )
 
+// leakDetector fails the specs that leave runtime processes running
+var leakDetector = NewProcessLeakDetector()
+
+// hostState is used to fail the specs that leave mounts, network
+// interfaces, cgroups or pod state directories behind
+var hostState *HostState
+
+var _ = BeforeEach(func() {
+	Expect(leakDetector.Snapshot()).To(Succeed())
+
+	var err error
+	hostState, err = SnapshotHostState()
+	Expect(err).ToNot(HaveOccurred())
+})
+
+var _ = AfterEach(func() {
+	Expect(leakDetector.Check()).To(Succeed())
+	Expect(CheckHostState(hostState)).To(Succeed())
+})
+
 func TestFunctional(t *testing.T) {
-	RegisterFailHandler(Fail)
-	RunSpecs(t, "Functional Suite")
+	if err := LoadSuiteConfig(); err != nil {
+		t.Fatal(err)
+	}
+
+	RegisterFailHandler(FailWithArtifacts)
+	RunSpecsWithDefaultAndCustomReporters(t, "Functional Suite", SuiteReporters())
 }
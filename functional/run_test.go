@@ -55,6 +55,7 @@ var _ = Describe("run", func() {
 			_, _, exitCode := container.Run()
 
 			Expect(expectedExitCode).To(Equal(exitCode))
+			Expect(container).To(HaveLoggedNoErrors())
 		},
 		withWorkload("true", 0),
 		withWorkload("false", 1),
@@ -93,6 +94,7 @@ var _ = Describe("run", func() {
 			} else {
 				Expect(exitCode).To(Equal(0))
 				Expect(stderr).To(BeEmpty())
+				Expect(container).To(HaveLoggedNoErrors())
 			}
 		},
 		withoutOption("--bundle", shouldFail),
@@ -13,3 +13,55 @@
 // limitations under the License.
 
 package functional
+
+import (
+	. "github.com/clearcontainers/tests"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("start", func() {
+	var (
+		container *Container
+		err       error
+	)
+
+	BeforeEach(func() {
+		container, err = NewContainer(sleepingContainerWorkload, true)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(container).NotTo(BeNil())
+	})
+
+	AfterEach(func() {
+		Expect(container.Teardown()).To(Succeed())
+	})
+
+	Context("a created container", func() {
+		It("should run its workload", func() {
+			_, _, exitCode := container.Create()
+			Expect(exitCode).To(Equal(0))
+
+			_, stderr, exitCode := container.Start()
+			Expect(exitCode).To(Equal(0))
+			Expect(stderr).To(BeEmpty())
+
+			_, err := container.WaitForState("running", stateTimeout)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(container).To(HaveLoggedNoErrors())
+		})
+	})
+
+	Context("a running container", func() {
+		It("should fail", func() {
+			_, _, exitCode := container.Create()
+			Expect(exitCode).To(Equal(0))
+
+			_, _, exitCode = container.Start()
+			Expect(exitCode).To(Equal(0))
+
+			_, stderr, exitCode := container.Start()
+			Expect(exitCode).NotTo(Equal(0))
+			Expect(stderr).NotTo(BeEmpty())
+		})
+	})
+})
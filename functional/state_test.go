@@ -13,3 +13,95 @@
 // limitations under the License.
 
 package functional
+
+import (
+	"time"
+
+	. "github.com/clearcontainers/tests"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// stateTimeout is the time a container has to reach a state
+const stateTimeout = 10 * time.Second
+
+var _ = Describe("state", func() {
+	var (
+		container *Container
+		err       error
+	)
+
+	BeforeEach(func() {
+		container, err = NewContainer(sleepingContainerWorkload, true)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(container).NotTo(BeNil())
+
+		_, _, exitCode := container.Run()
+		Expect(exitCode).To(Equal(0))
+	})
+
+	AfterEach(func() {
+		Expect(container.Teardown()).To(Succeed())
+	})
+
+	Context("of a running container", func() {
+		It("should show its ID, bundle and status", func() {
+			state, err := container.State()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(state.ID).To(Equal(*container.ID))
+			Expect(state.Bundle).To(Equal(container.Bundle.Path))
+			Expect(state.Status).To(Equal("running"))
+			Expect(state.Pid).To(BeNumerically(">", 0))
+		})
+	})
+
+	Context("of a paused container", func() {
+		It("should show it is paused until it is resumed", func() {
+			SkipUnlessRuntime(RequiresPause)
+
+			_, _, exitCode := container.Pause()
+			Expect(exitCode).To(Equal(0))
+
+			state, err := container.State()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(state.Status).To(Equal("paused"))
+
+			_, _, exitCode = container.Resume()
+			Expect(exitCode).To(Equal(0))
+
+			state, err = container.State()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(state.Status).To(Equal("running"))
+		})
+	})
+})
+
+var _ = Describe("state", func() {
+	var (
+		container *Container
+		err       error
+	)
+
+	BeforeEach(func() {
+		container, err = NewContainer([]string{"true"}, true)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(container).NotTo(BeNil())
+	})
+
+	AfterEach(func() {
+		Expect(container.Teardown()).To(Succeed())
+	})
+
+	Context("of a container whose workload finished", func() {
+		It("should show it is stopped", func() {
+			_, _, exitCode := container.Create()
+			Expect(exitCode).To(Equal(0))
+
+			_, _, exitCode = container.Start()
+			Expect(exitCode).To(Equal(0))
+
+			_, err := container.WaitForState("stopped", stateTimeout)
+			Expect(err).NotTo(HaveOccurred())
+		})
+	})
+})
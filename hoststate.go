@@ -0,0 +1,372 @@
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tests
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	mountinfoPath = "/proc/self/mountinfo"
+
+	// podsPath is the directory where virtcontainers keeps the pods state
+	podsPath = "/run/virtcontainers/pods"
+)
+
+// cgroupPath is the mount point of the cgroup hierarchies
+var cgroupPath = "/sys/fs/cgroup"
+
+// containerCgroupParent is the cgroup, relative to a hierarchy, of the
+// cgroups of the containers run directly with the runtime, see
+// NewContainer
+const containerCgroupParent = "cc-tests"
+
+// cgroupParents are the cgroups, relative to a hierarchy, where the
+// runtime creates the cgroups of the containers, docker for the
+// containers run by docker. Only the cgroups below them are checked,
+// the other cgroups of the host can change while the specs run
+var cgroupParents = []string{"docker", containerCgroupParent}
+
+// runtimeLinkPrefixes are the prefixes of the names of the network
+// interfaces created by the runtime, the other interfaces of the host
+// are not checked
+var runtimeLinkPrefixes = []string{"tap", "cc-"}
+
+// parallelLinksWarning tells once that the network interfaces are not
+// checked when running in parallel
+var parallelLinksWarning sync.Once
+
+// HostState is a snapshot of the host resources that the runtime
+// creates and must release when the containers are removed
+type HostState struct {
+	// Mounts are the mount points in the form "<mount point> <type> <source>"
+	Mounts []string
+
+	// Links are the names of the network interfaces created by the
+	// runtime, see runtimeLinkPrefixes
+	Links []string
+
+	// Cgroups are the cgroup directories below the cgroup parents of
+	// the runtime, relative to /sys/fs/cgroup, see cgroupParents
+	Cgroups []string
+
+	// Pods are the entries of the virtcontainers pods state directory
+	Pods []string
+}
+
+// SnapshotHostState returns the current HostState, only the resources
+// of the ginkgo node are kept when running in parallel
+func SnapshotHostState() (*HostState, error) {
+	var s HostState
+	var err error
+
+	if s.Mounts, err = readMounts(); err != nil {
+		return nil, err
+	}
+
+	if s.Links, err = readLinks(); err != nil {
+		return nil, err
+	}
+
+	if s.Cgroups, err = readCgroups(); err != nil {
+		return nil, err
+	}
+
+	if s.Pods, err = readDirNames(podsPath); err != nil {
+		return nil, err
+	}
+
+	return s.ownedByNode(), nil
+}
+
+// ownedByNode returns the resources of s that belong to the ginkgo
+// node. The network interfaces are too short to have the node marker,
+// they cannot be told apart and are not checked when running in parallel
+func (s *HostState) ownedByNode() *HostState {
+	if Parallel() {
+		parallelLinksWarning.Do(func() {
+			LogIfFail("Network interfaces are not checked for leaks when running in parallel\n")
+		})
+	}
+
+	filter := func(resources []string) []string {
+		var owned []string
+		for _, r := range resources {
+			if ownedByNode(r) {
+				owned = append(owned, r)
+			}
+		}
+
+		return owned
+	}
+
+	return &HostState{
+		Mounts:  filter(s.Mounts),
+		Links:   filter(s.Links),
+		Cgroups: filter(s.Cgroups),
+		Pods:    filter(s.Pods),
+	}
+}
+
+// Diff returns the resources of s that were not in before
+func (s *HostState) Diff(before *HostState) *HostState {
+	return &HostState{
+		Mounts:  subtract(s.Mounts, before.Mounts),
+		Links:   subtract(s.Links, before.Links),
+		Cgroups: subtract(s.Cgroups, before.Cgroups),
+		Pods:    subtract(s.Pods, before.Pods),
+	}
+}
+
+// Empty returns true if s has no resources
+func (s *HostState) Empty() bool {
+	return len(s.Mounts) == 0 && len(s.Links) == 0 && len(s.Cgroups) == 0 && len(s.Pods) == 0
+}
+
+func (s *HostState) String() string {
+	var lines []string
+
+	add := func(kind string, resources []string) {
+		for _, r := range resources {
+			lines = append(lines, fmt.Sprintf("%s: %s", kind, r))
+		}
+	}
+
+	add("mount", s.Mounts)
+	add("link", s.Links)
+	add("cgroup", s.Cgroups)
+	add("pod", s.Pods)
+
+	return strings.Join(lines, "\n")
+}
+
+// CheckHostState returns an error listing the resources leaked since
+// the snapshot before was taken, the runtime is given some time to
+// release them, e.g.
+//
+//	var before *HostState
+//
+//	BeforeEach(func() {
+//		var err error
+//		before, err = SnapshotHostState()
+//		Expect(err).ToNot(HaveOccurred())
+//	})
+//
+//	AfterEach(func() {
+//		Expect(CheckHostState(before)).To(Succeed())
+//	})
+func CheckHostState(before *HostState) error {
+	deadline := time.Now().Add(leakGracePeriod)
+
+	for {
+		after, err := SnapshotHostState()
+		if err != nil {
+			return err
+		}
+
+		leaked := after.Diff(before)
+		if leaked.Empty() {
+			return nil
+		}
+
+		if time.Now().After(deadline) {
+			return fmt.Errorf("host resources leaked:\n%s", leaked)
+		}
+
+		time.Sleep(stateInterval)
+	}
+}
+
+// subtract returns the elements of a that are not in b, taking
+// into account repeated elements (e.g. stacked mounts)
+func subtract(a, b []string) []string {
+	count := make(map[string]int)
+	for _, e := range b {
+		count[e]++
+	}
+
+	var result []string
+	for _, e := range a {
+		if count[e] > 0 {
+			count[e]--
+			continue
+		}
+
+		result = append(result, e)
+	}
+
+	return result
+}
+
+// readMounts parses the mount points of mountinfoPath, see proc(5)
+func readMounts() ([]string, error) {
+	f, err := os.Open(mountinfoPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var mounts []string
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// 36 35 98:0 /mnt1 /mnt2 rw,noatime master:1 - ext3 /dev/root rw
+		fields := strings.Fields(scanner.Text())
+
+		sep := -1
+		for i, f := range fields {
+			if f == "-" {
+				sep = i
+				break
+			}
+		}
+
+		if sep < 5 || len(fields) < sep+3 {
+			return nil, fmt.Errorf("invalid line in %s: %s", mountinfoPath, scanner.Text())
+		}
+
+		mounts = append(mounts, fmt.Sprintf("%s %s %s", fields[4], fields[sep+1], fields[sep+2]))
+	}
+
+	return mounts, scanner.Err()
+}
+
+// readLinks returns the names of the network interfaces of the runtime
+func readLinks() ([]string, error) {
+	interfaces, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, i := range interfaces {
+		names = append(names, i.Name)
+	}
+
+	return runtimeLinks(names), nil
+}
+
+// runtimeLinks returns the names that start with runtimeLinkPrefixes
+func runtimeLinks(names []string) []string {
+	var links []string
+	for _, name := range names {
+		for _, prefix := range runtimeLinkPrefixes {
+			if strings.HasPrefix(name, prefix) {
+				links = append(links, name)
+				break
+			}
+		}
+	}
+
+	return links
+}
+
+// readCgroups returns the cgroup directories below the cgroup parents
+// of the runtime in every hierarchy, e.g. cpu/docker/<id> in cgroup v1
+// or docker/<id> in cgroup v2
+func readCgroups() ([]string, error) {
+	entries, err := ioutil.ReadDir(cgroupPath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// the cgroup v2 unified hierarchy is cgroupPath itself, the
+	// symbolic links of v1 (e.g. cpu -> cpu,cpuacct) are skipped
+	roots := []string{cgroupPath}
+	for _, e := range entries {
+		if e.IsDir() {
+			roots = append(roots, filepath.Join(cgroupPath, e.Name()))
+		}
+	}
+
+	var cgroups []string
+
+	for _, root := range roots {
+		for _, parent := range cgroupParents {
+			found, err := readCgroupTree(filepath.Join(root, parent))
+			if err != nil {
+				return nil, err
+			}
+
+			cgroups = append(cgroups, found...)
+		}
+	}
+
+	return cgroups, nil
+}
+
+// readCgroupTree returns the cgroup directories below dir,
+// relative to cgroupPath
+func readCgroupTree(dir string) ([]string, error) {
+	var cgroups []string
+
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			// the cgroup was removed while walking
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+
+		if !info.IsDir() || path == dir {
+			return nil
+		}
+
+		rel, err := filepath.Rel(cgroupPath, path)
+		if err != nil {
+			return err
+		}
+
+		cgroups = append(cgroups, rel)
+
+		return nil
+	})
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	return cgroups, err
+}
+
+// readDirNames returns the names of the entries of dir,
+// a missing directory has no entries
+func readDirNames(dir string) ([]string, error) {
+	entries, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+
+	return names, nil
+}
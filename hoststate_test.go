@@ -0,0 +1,90 @@
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tests
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHostStateDiff(t *testing.T) {
+	assert := assert.New(t)
+
+	before := &HostState{
+		Mounts:  []string{"/ ext4 /dev/sda1", "/run/a tmpfs tmpfs", "/run/a tmpfs tmpfs"},
+		Links:   []string{"tap0"},
+		Cgroups: []string{"cpu/docker/a"},
+		Pods:    []string{"a"},
+	}
+
+	after := &HostState{
+		// a mount stacked once more, one of the stacked mounts removed
+		Mounts:  []string{"/ ext4 /dev/sda1", "/run/a tmpfs tmpfs", "/run/b tmpfs tmpfs"},
+		Links:   []string{"tap0", "tap1"},
+		Cgroups: []string{"cpu/docker/a", "cpu/docker/b"},
+		Pods:    []string{},
+	}
+
+	leaked := after.Diff(before)
+	assert.Equal([]string{"/run/b tmpfs tmpfs"}, leaked.Mounts)
+	assert.Equal([]string{"tap1"}, leaked.Links)
+	assert.Equal([]string{"cpu/docker/b"}, leaked.Cgroups)
+	assert.Empty(leaked.Pods)
+	assert.False(leaked.Empty())
+	assert.Equal("mount: /run/b tmpfs tmpfs\nlink: tap1\ncgroup: cpu/docker/b", leaked.String())
+
+	assert.True(before.Diff(before).Empty())
+	assert.Equal([]string{"a", "a"}, subtract([]string{"a", "a", "a"}, []string{"a"}))
+}
+
+func TestRuntimeLinks(t *testing.T) {
+	assert.Equal(t, []string{"tap0_kata", "cc-br0"},
+		runtimeLinks([]string{"lo", "eth0", "docker0", "tap0_kata", "veth1a2b3c", "cc-br0"}))
+}
+
+func TestReadCgroups(t *testing.T) {
+	assert := assert.New(t)
+
+	root, err := ioutil.TempDir(testDir, "cgroup")
+	assert.NoError(err)
+
+	defer func(path string) { cgroupPath = path }(cgroupPath)
+	cgroupPath = root
+
+	for _, dir := range []string{
+		// cgroup v1
+		"cpu,cpuacct/docker/a",
+		"cpu,cpuacct/user.slice",
+		"memory/docker/a/b",
+		"memory/cc-tests/d",
+		// cgroup v2
+		"docker/c",
+		"cc-tests/e",
+		"system.slice/cron.service",
+	} {
+		assert.NoError(os.MkdirAll(filepath.Join(root, dir), 0755))
+	}
+
+	assert.NoError(os.Symlink("cpu,cpuacct", filepath.Join(root, "cpu")))
+	assert.NoError(ioutil.WriteFile(filepath.Join(root, "cgroup.procs"), nil, 0644))
+
+	cgroups, err := readCgroups()
+	assert.NoError(err)
+	assert.Equal([]string{"docker/c", "cc-tests/e", "cpu,cpuacct/docker/a", "memory/docker/a", "memory/docker/a/b", "memory/cc-tests/d"}, cgroups)
+}
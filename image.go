@@ -0,0 +1,284 @@
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tests
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+)
+
+const (
+	ociLayoutFile = "oci-layout"
+
+	ociIndexFile = "index.json"
+
+	ociBlobsDir = "blobs"
+
+	// ociRefNameAnnotation is the annotation holding the reference
+	// of a manifest in the index of an image layout
+	ociRefNameAnnotation = "org.opencontainers.image.ref.name"
+
+	ociIndexMediaType = "application/vnd.oci.image.index.v1+json"
+
+	dockerManifestListMediaType = "application/vnd.docker.distribution.manifest.list.v2+json"
+)
+
+var blobDigestRegexp = regexp.MustCompile(`^[a-f0-9]{64}$`)
+
+// ociDescriptor describes a blob of an OCI image layout
+type ociDescriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Size        int64             `json:"size"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+	Platform    *struct {
+		Architecture string `json:"architecture"`
+		OS           string `json:"os"`
+	} `json:"platform,omitempty"`
+}
+
+// ociIndex is the index.json of an OCI image layout or an image index blob
+type ociIndex struct {
+	SchemaVersion int             `json:"schemaVersion"`
+	Manifests     []ociDescriptor `json:"manifests"`
+}
+
+// ociManifest is an OCI image manifest
+type ociManifest struct {
+	SchemaVersion int             `json:"schemaVersion"`
+	Config        ociDescriptor   `json:"config"`
+	Layers        []ociDescriptor `json:"layers"`
+}
+
+// NewBundleFromImageLayout creates a new bundle whose rootfs is extracted
+// from the image with reference ref stored in the OCI image layout directory
+// layoutPath, if ref is empty the layout must contain a single image
+func NewBundleFromImageLayout(workload []string, layoutPath, ref string) (*Bundle, error) {
+	return newBundle(workload, func(b *Bundle) error {
+		if !rootfsCacheEnabled {
+			rootfsDir, err := mkRootfsDir(b.Path)
+			if err != nil {
+				return err
+			}
+
+			return unpackImageLayout(rootfsDir, layoutPath, ref)
+		}
+
+		manifest, err := resolveImageManifest(layoutPath, ref)
+		if err != nil {
+			return err
+		}
+
+		return rootfsCache.populate(b, manifest.Digest, func(rootfsDir string) error {
+			return unpackImageLayout(rootfsDir, layoutPath, ref)
+		})
+	})
+}
+
+// unpackImageLayout extracts the layers of the image ref stored in the
+// OCI image layout directory layoutPath into dir
+func unpackImageLayout(dir, layoutPath, ref string) error {
+	manifest, err := readImageManifest(layoutPath, ref)
+	if err != nil {
+		return err
+	}
+
+	for _, layer := range manifest.Layers {
+		if err := unpackBlob(dir, layoutPath, layer); err != nil {
+			return fmt.Errorf("failed to unpack layer %s: %v", layer.Digest, err)
+		}
+	}
+
+	return nil
+}
+
+// readImageManifest returns the manifest of the image ref
+// stored in the OCI image layout directory layoutPath
+func readImageManifest(layoutPath, ref string) (*ociManifest, error) {
+	desc, err := resolveImageManifest(layoutPath, ref)
+	if err != nil {
+		return nil, err
+	}
+
+	var manifest ociManifest
+	if err := readBlobJSON(layoutPath, desc, &manifest); err != nil {
+		return nil, err
+	}
+
+	return &manifest, nil
+}
+
+// resolveImageManifest returns the descriptor of the manifest of the
+// image ref stored in the OCI image layout directory layoutPath
+func resolveImageManifest(layoutPath, ref string) (ociDescriptor, error) {
+	if _, err := os.Stat(filepath.Join(layoutPath, ociLayoutFile)); err != nil {
+		return ociDescriptor{}, fmt.Errorf("%s is not an OCI image layout: %v", layoutPath, err)
+	}
+
+	var index ociIndex
+	content, err := ioutil.ReadFile(filepath.Join(layoutPath, ociIndexFile))
+	if err != nil {
+		return ociDescriptor{}, err
+	}
+
+	if err := json.Unmarshal(content, &index); err != nil {
+		return ociDescriptor{}, err
+	}
+
+	desc, err := findManifest(index.Manifests, ref)
+	if err != nil {
+		return ociDescriptor{}, fmt.Errorf("%s: %v", layoutPath, err)
+	}
+
+	// an image index references a manifest per platform
+	for desc.MediaType == ociIndexMediaType || desc.MediaType == dockerManifestListMediaType {
+		var nested ociIndex
+		if err := readBlobJSON(layoutPath, desc, &nested); err != nil {
+			return ociDescriptor{}, err
+		}
+
+		desc, err = findPlatformManifest(nested.Manifests)
+		if err != nil {
+			return ociDescriptor{}, fmt.Errorf("%s: %v", layoutPath, err)
+		}
+	}
+
+	return desc, nil
+}
+
+// findManifest looks for the descriptor annotated with the reference ref
+func findManifest(manifests []ociDescriptor, ref string) (ociDescriptor, error) {
+	if ref == "" {
+		if len(manifests) != 1 {
+			return ociDescriptor{}, fmt.Errorf("a reference is needed to choose among %d images", len(manifests))
+		}
+
+		return manifests[0], nil
+	}
+
+	for _, m := range manifests {
+		if m.Annotations[ociRefNameAnnotation] == ref {
+			return m, nil
+		}
+	}
+
+	return ociDescriptor{}, fmt.Errorf("image %s not found", ref)
+}
+
+// findPlatformManifest looks for the manifest of the host platform
+func findPlatformManifest(manifests []ociDescriptor) (ociDescriptor, error) {
+	for _, m := range manifests {
+		if m.Platform == nil {
+			continue
+		}
+
+		if m.Platform.OS == runtime.GOOS && m.Platform.Architecture == runtime.GOARCH {
+			return m, nil
+		}
+	}
+
+	return ociDescriptor{}, fmt.Errorf("no image for %s/%s", runtime.GOOS, runtime.GOARCH)
+}
+
+func readBlobJSON(layoutPath string, desc ociDescriptor, v interface{}) error {
+	r, err := openBlob(layoutPath, desc)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	if err := json.NewDecoder(r).Decode(v); err != nil {
+		return err
+	}
+
+	// drain the blob to verify its digest
+	_, err = io.Copy(ioutil.Discard, r)
+	return err
+}
+
+func unpackBlob(dir, layoutPath string, desc ociDescriptor) error {
+	r, err := openBlob(layoutPath, desc)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	if err := unpackLayer(dir, r); err != nil {
+		return err
+	}
+
+	// tar readers stop at the end-of-archive marker,
+	// drain the blob to verify its digest
+	_, err = io.Copy(ioutil.Discard, r)
+	return err
+}
+
+// openBlob opens the blob described by desc, the returned reader
+// fails at EOF if the content does not match the descriptor digest
+func openBlob(layoutPath string, desc ociDescriptor) (io.ReadCloser, error) {
+	parts := strings.SplitN(desc.Digest, ":", 2)
+	if len(parts) != 2 || parts[0] != "sha256" || !blobDigestRegexp.MatchString(parts[1]) {
+		return nil, fmt.Errorf("unsupported digest %q", desc.Digest)
+	}
+
+	f, err := os.Open(filepath.Join(layoutPath, ociBlobsDir, parts[0], parts[1]))
+	if err != nil {
+		return nil, err
+	}
+
+	return &verifiedReader{
+		f:      f,
+		hash:   sha256.New(),
+		digest: parts[1],
+	}, nil
+}
+
+// verifiedReader reads a blob computing its sha256 digest
+type verifiedReader struct {
+	f      *os.File
+	hash   hashWriter
+	digest string
+}
+
+type hashWriter interface {
+	io.Writer
+	Sum(b []byte) []byte
+}
+
+func (r *verifiedReader) Read(b []byte) (int, error) {
+	n, err := r.f.Read(b)
+	_, _ = r.hash.Write(b[:n])
+
+	if err == io.EOF {
+		if sum := hex.EncodeToString(r.hash.Sum(nil)); sum != r.digest {
+			return n, fmt.Errorf("digest mismatch for %s: got sha256:%s", r.f.Name(), sum)
+		}
+	}
+
+	return n, err
+}
+
+func (r *verifiedReader) Close() error {
+	return r.f.Close()
+}
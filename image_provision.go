@@ -0,0 +1,264 @@
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tests
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// mirroredImageStars are the stars shown by the search of the local
+// registry for the images of the suite, they are shown as official
+// and popular images like in Docker Hub
+const mirroredImageStars = 1000
+
+// imageManifestPath is the path of the image manifest, see ProvisionImages
+var imageManifestPath string
+
+// localRegistryEnabled is true if the specs use a local registry
+// instead of Docker Hub, see StartSuiteRegistry
+var localRegistryEnabled bool
+
+func init() {
+	flag.StringVar(&imageManifestPath, suiteFlag("image-manifest"), "", "Path of the TOML manifest of the image archives loaded instead of pulling the images")
+	flag.BoolVar(&localRegistryEnabled, suiteFlag("local-registry"), false, "Run the pull, push and search specs against an in-process registry instead of Docker Hub")
+}
+
+// suiteRegistry is the local registry of the suite
+var suiteRegistry *Registry
+
+// imageManifest lists the archives written by docker save of the
+// images of the suites, the archives are relative to the manifest:
+//
+//	[[image]]
+//	name = "busybox"
+//	archive = "busybox.tar"
+//	# optional
+//	sha256 = "0f3c6b3a..."
+type imageManifest struct {
+	Images []imageManifestEntry `toml:"image"`
+}
+
+type imageManifestEntry struct {
+	Name    string
+	Archive string
+	SHA256  string `toml:"sha256"`
+}
+
+// ProvisionImages makes the images available to docker. With an image
+// manifest (-image-manifest) the images not present are loaded from their
+// archive and nothing is downloaded, else the images are pulled
+func ProvisionImages(images ...string) error {
+	if imageManifestPath == "" {
+		for _, image := range images {
+			if result := DockerPullResult(image); result.ExitCode != 0 {
+				return fmt.Errorf("failed to pull docker image %s: %s", image, result.Stderr)
+			}
+		}
+
+		return nil
+	}
+
+	manifest, err := readImageManifestFile(imageManifestPath)
+	if err != nil {
+		return err
+	}
+
+	for _, image := range images {
+		if _, err := dockerImageDigest(image); err == nil {
+			continue
+		}
+
+		if err := manifest.load(image, filepath.Dir(imageManifestPath)); err != nil {
+			return fmt.Errorf("%s: %v", imageManifestPath, err)
+		}
+	}
+
+	return nil
+}
+
+func readImageManifestFile(path string) (*imageManifest, error) {
+	var manifest imageManifest
+
+	md, err := toml.DecodeFile(path, &manifest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image manifest %s: %v", path, err)
+	}
+
+	if undecoded := md.Undecoded(); len(undecoded) > 0 {
+		return nil, fmt.Errorf("unknown settings in image manifest %s: %v", path, undecoded)
+	}
+
+	for _, entry := range manifest.Images {
+		if entry.Name == "" || entry.Archive == "" {
+			return nil, fmt.Errorf("%s: images need a name and an archive", path)
+		}
+	}
+
+	return &manifest, nil
+}
+
+// load loads the archive of the image, relative archives are in dir
+func (m *imageManifest) load(image, dir string) error {
+	var entry *imageManifestEntry
+	for i := range m.Images {
+		if m.Images[i].Name == image {
+			entry = &m.Images[i]
+			break
+		}
+	}
+
+	if entry == nil {
+		return fmt.Errorf("image %s not found", image)
+	}
+
+	archive := entry.Archive
+	if !filepath.IsAbs(archive) {
+		archive = filepath.Join(dir, archive)
+	}
+
+	if entry.SHA256 != "" {
+		if err := checkFileSHA256(archive, entry.SHA256); err != nil {
+			return err
+		}
+	}
+
+	if result := DockerLoadResult("--input", archive); result.ExitCode != 0 {
+		return fmt.Errorf("failed to load %s: %s", archive, result.Stderr)
+	}
+
+	if _, err := dockerImageDigest(image); err != nil {
+		return fmt.Errorf("image %s not found in %s", image, archive)
+	}
+
+	return nil
+}
+
+func checkFileSHA256(path, expected string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return err
+	}
+
+	if sum := hex.EncodeToString(h.Sum(nil)); sum != strings.TrimPrefix(expected, "sha256:") {
+		return fmt.Errorf("sha256 of %s is %s, expected %s", path, sum, expected)
+	}
+
+	return nil
+}
+
+// StartSuiteRegistry starts the local registry of the suite when the
+// -local-registry option is given and pushes the images to it, the
+// registry is nil otherwise. The specs find it with SuiteRegistry
+func StartSuiteRegistry(images ...string) (*Registry, error) {
+	if !localRegistryEnabled {
+		return nil, nil
+	}
+
+	r, err := StartRegistry()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, image := range images {
+		name := registryRepositoryName(image)
+		if err := pushImage(image, r.Repository(name)); err != nil {
+			r.Close()
+			return nil, err
+		}
+
+		r.SetSearchInfo(name, RegistrySearchInfo{
+			Description: "Mirror of " + image,
+			Stars:       mirroredImageStars,
+			Official:    true,
+		})
+	}
+
+	suiteRegistry = r
+
+	return r, nil
+}
+
+// pushImage pushes image to the repository, the image
+// is tagged with the repository only while pushing it
+func pushImage(image, repository string) error {
+	if result := DockerTagResult(image, repository); result.ExitCode != 0 {
+		return fmt.Errorf("failed to tag %s as %s: %s", image, repository, result.Stderr)
+	}
+
+	result := DockerPushResult(repository)
+
+	// removes the tag, not the image
+	DockerRmiResult(repository)
+
+	if result.ExitCode != 0 {
+		return fmt.Errorf("failed to push %s: %s", repository, result.Stderr)
+	}
+
+	return nil
+}
+
+// SuiteRegistry returns the local registry started by
+// StartSuiteRegistry, nil if the specs use Docker Hub
+func SuiteRegistry() *Registry {
+	return suiteRegistry
+}
+
+// RegistryRepository returns the reference of the image in the registry
+// used by the specs, the local registry of the suite or Docker Hub
+func RegistryRepository(image string) string {
+	if suiteRegistry == nil {
+		return image
+	}
+
+	return suiteRegistry.Repository(registryRepositoryName(image))
+}
+
+// registryRepositoryName returns the name of the repository
+// of the image without registry nor tag, e.g. busybox for
+// registry.local/busybox:1.26
+func registryRepositoryName(image string) string {
+	if i := strings.IndexByte(image, '@'); i >= 0 {
+		image = image[:i]
+	}
+
+	parts := strings.Split(image, "/")
+
+	// the first component is a registry if it looks like a host
+	if len(parts) > 1 && (strings.ContainsAny(parts[0], ".:") || parts[0] == "localhost") {
+		parts = parts[1:]
+	}
+
+	last := len(parts) - 1
+	if i := strings.IndexByte(parts[last], ':'); i >= 0 {
+		parts[last] = parts[last][:i]
+	}
+
+	return strings.Join(parts, "/")
+}
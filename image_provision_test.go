@@ -0,0 +1,85 @@
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tests
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistryRepositoryName(t *testing.T) {
+	assert := assert.New(t)
+
+	for image, name := range map[string]string{
+		"busybox":                           "busybox",
+		"busybox:1.26":                      "busybox",
+		"library/busybox@sha256:0123":       "library/busybox",
+		"registry.local/busybox:1.26":       "busybox",
+		"localhost:5000/cc/busybox":         "cc/busybox",
+		"localhost/busybox":                 "busybox",
+		"clearcontainers/busybox:latest":    "clearcontainers/busybox",
+		"registry.local:5000/a/b/c:1.0-rc1": "a/b/c",
+	} {
+		assert.Equal(name, registryRepositoryName(image), image)
+	}
+
+	// Docker Hub is used without a suite registry
+	assert.Nil(SuiteRegistry())
+	assert.Equal("busybox:1.26", RegistryRepository("busybox:1.26"))
+}
+
+func TestReadImageManifestFile(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir(testDir, "images")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	archive := filepath.Join(dir, "busybox.tar")
+	assert.NoError(ioutil.WriteFile(archive, []byte("archive"), 0644))
+
+	path := filepath.Join(dir, "images.toml")
+	assert.NoError(ioutil.WriteFile(path, []byte(`
+[[image]]
+name = "busybox"
+archive = "busybox.tar"
+sha256 = "sha256:0eb3e36bfb24dcd9bb1d1bece1531216b59539a8fde17ee80224af0653c92aa3"
+`), 0644))
+
+	manifest, err := readImageManifestFile(path)
+	assert.NoError(err)
+	assert.Len(manifest.Images, 1)
+	assert.Equal("busybox", manifest.Images[0].Name)
+
+	// the images not in the manifest cannot be loaded
+	assert.Error(manifest.load("alpine", dir))
+
+	// the digest can have the sha256: prefix
+	assert.NoError(checkFileSHA256(archive, manifest.Images[0].SHA256))
+	assert.NoError(checkFileSHA256(archive, "0eb3e36bfb24dcd9bb1d1bece1531216b59539a8fde17ee80224af0653c92aa3"))
+	assert.Error(checkFileSHA256(archive, "0000"))
+
+	assert.NoError(ioutil.WriteFile(path, []byte("[[image]]\nname = \"busybox\"\n"), 0644))
+	_, err = readImageManifestFile(path)
+	assert.Error(err)
+
+	assert.NoError(ioutil.WriteFile(path, []byte("[[image]]\nname = \"busybox\"\narchive = \"b.tar\"\ntag = \"1\"\n"), 0644))
+	_, err = readImageManifestFile(path)
+	assert.Error(err)
+}
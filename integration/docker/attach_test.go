@@ -49,4 +49,25 @@ var _ = Describe("docker attach", func() {
 			Expect(exitCode).To(Equal(containerExitCode))
 		})
 	})
+
+	Context("attach to an interactive container", func() {
+		It("should relay the terminal", func() {
+			interactiveID := randomDockerName()
+			_, _, exitCode = DockerRun("--name", interactiveID, "-dit", Image, "sh")
+			Expect(exitCode).To(Equal(0))
+			defer RemoveDockerContainer(interactiveID)
+
+			session, err := StartDockerSession("attach", interactiveID)
+			Expect(err).NotTo(HaveOccurred())
+			defer session.Close()
+
+			Expect(session.Send("echo $((6 * 7))\n")).To(Succeed())
+			Expect(session.ExpectString("42")).To(Succeed())
+
+			Expect(session.Send("exit 5\n")).To(Succeed())
+			exitCode, err = session.Wait()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(exitCode).To(Equal(5))
+		})
+	})
 })
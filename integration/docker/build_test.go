@@ -33,6 +33,12 @@ var _ = Describe("build", func() {
 		exitCode  int
 	)
 
+	// the image name "test" is shared by the ginkgo nodes,
+	// the spec takes the host alone, see HostLock
+	BeforeEach(func() {
+		Expect(HostLock.Lock()).To(Succeed())
+	})
+
 	BeforeEach(func() {
 		id = randomDockerName()
 	})
@@ -41,7 +41,7 @@ var _ = Describe("docker create", func() {
 			_, _, exitCode = DockerCreate("-t", "--name", id, Image)
 			Expect(exitCode).To(Equal(0))
 
-			stdout, _, exitCode = DockerPs("--filter", "status=created")
+			stdout, _, exitCode = DockerPs("--filter", "status=created", "--filter", "name="+id)
 			Expect(exitCode).To(Equal(0))
 			Expect(stdout).To(ContainSubstring(id))
 		})
@@ -31,29 +31,80 @@ func randomDockerName() string {
 	return RandID(30)
 }
 
+// runDockerCommand runs a docker command through DockerCommand,
+// the objects it creates are removed after the spec
 func runDockerCommand(expectedExitCode int, args ...string) string {
-	cmd := NewCommand(Docker, args...)
-	Expect(cmd).ToNot(BeNil())
-	stdout, _, exitCode := cmd.Run()
+	Expect(args).ToNot(BeEmpty())
+	stdout, _, exitCode := DockerCommand(args[0], args[1:]...)
 	Expect(exitCode).To(Equal(expectedExitCode))
 	return stdout
 }
 
+// dockerTracker removes the docker objects created by each spec
+var dockerTracker *DockerTracker
+
+var _ = BeforeEach(func() {
+	dockerTracker = TrackDockerResources()
+})
+
+var _ = AfterEach(func() {
+	Expect(dockerTracker.Cleanup()).To(Succeed())
+})
+
+// AfterSuite also runs when the suite is interrupted
+var _ = AfterSuite(func() {
+	Expect(CleanupDockerTrackers()).To(Succeed())
+})
+
+// leakDetector fails the specs that leave runtime processes running
+var leakDetector = NewProcessLeakDetector()
+
+// hostState is used to fail the specs that leave mounts, network
+// interfaces, cgroups or pod state directories behind
+var hostState *HostState
+
+var _ = BeforeEach(func() {
+	Expect(leakDetector.Snapshot()).To(Succeed())
+
+	var err error
+	hostState, err = SnapshotHostState()
+	Expect(err).ToNot(HaveOccurred())
+})
+
+var _ = AfterEach(func() {
+	Expect(leakDetector.Check()).To(Succeed())
+	Expect(CheckHostState(hostState)).To(Succeed())
+})
+
+// the specs hold HostLock shared, the specs that need the
+// host alone take it exclusively, see HostLock
+var _ = BeforeEach(func() {
+	Expect(HostLock.RLock()).To(Succeed())
+})
+
+var _ = AfterEach(func() {
+	Expect(HostLock.Unlock()).To(Succeed())
+})
+
 func TestIntegration(t *testing.T) {
-	// before start we have to download the docker images
-	images := []string{
-		Image,
-		AlpineImage,
-		PostgresImage,
+	if err := LoadSuiteConfig(); err != nil {
+		t.Fatal(err)
+	}
+
+	// before start we have to download or load the docker images
+	if err := ProvisionImages(Image, AlpineImage, PostgresImage); err != nil {
+		t.Fatal(err)
+	}
+
+	registry, err := StartSuiteRegistry(Image)
+	if err != nil {
+		t.Fatal(err)
 	}
 
-	for _, i := range images {
-		_, _, exitCode := DockerPull(i)
-		if exitCode != 0 {
-			t.Fatalf("failed to pull docker image: %s\n", i)
-		}
+	if registry != nil {
+		defer registry.Close()
 	}
 
-	RegisterFailHandler(Fail)
-	RunSpecs(t, "Integration Suite")
+	RegisterFailHandler(FailWithArtifacts)
+	RunSpecsWithDefaultAndCustomReporters(t, "Integration Suite", SuiteReporters())
 }
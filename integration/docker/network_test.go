@@ -26,6 +26,12 @@ var _ = Describe("network", func() {
 		networkName string = "my-bridge-network"
 	)
 
+	// the network name is shared by the ginkgo nodes,
+	// the spec takes the host alone, see HostLock
+	BeforeEach(func() {
+		Expect(HostLock.Lock()).To(Succeed())
+	})
+
 	AfterEach(func() {
 		_, _, exitCode := DockerNetwork("rm", networkName)
 		Expect(exitCode).To(Equal(0))
@@ -40,8 +46,10 @@ var _ = Describe("network", func() {
 				args = []string{"create", "-d", "bridge", networkName}
 				_, _, exitCode := DockerNetwork(args...)
 				Expect(exitCode).To(Equal(0))
-				_, _, exitCode = DockerNetwork("inspect", networkName)
-				Expect(exitCode).To(Equal(0))
+				network, err := InspectDockerNetwork(networkName)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(network.Name).To(Equal(networkName))
+				Expect(network.Driver).To(Equal("bridge"))
 			})
 		})
 	})
@@ -15,6 +15,8 @@
 package docker
 
 import (
+	"strconv"
+
 	. "github.com/clearcontainers/tests"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -22,19 +24,27 @@ import (
 
 var _ = Describe("port", func() {
 	var (
-		args []string
-		id   string
+		args     []string
+		id       string
+		port     int
+		hostPort string
 	)
 
 	BeforeEach(func() {
+		var err error
+		port, err = AllocatePort()
+		Expect(err).ToNot(HaveOccurred())
+		hostPort = strconv.Itoa(port)
+
 		id = randomDockerName()
-		_, _, exitCode := DockerRun("-td", "-p", "8080:8080", "--name", id, Image)
+		_, _, exitCode := DockerRun("-td", "-p", hostPort+":8080", "--name", id, Image)
 		Expect(exitCode).To(Equal(0))
 	})
 
 	AfterEach(func() {
 		Expect(RemoveDockerContainer(id)).To(BeTrue())
 		Expect(ExistDockerContainer(id)).NotTo(BeTrue())
+		ReleasePort(port)
 	})
 
 	Describe("port with docker", func() {
@@ -42,7 +52,15 @@ var _ = Describe("port", func() {
 			It("should return assigned port", func() {
 				args = []string{"port", id, "8080/tcp"}
 				stdout := runDockerCommand(0, args...)
-				Expect(stdout).To(ContainSubstring("8080"))
+				Expect(stdout).To(ContainSubstring(hostPort))
+			})
+
+			It("should bind the host port", func() {
+				container, err := InspectDockerContainer(id)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(container.HostConfig.PortBindings).To(HaveKey("8080/tcp"))
+				Expect(container.NetworkSettings.Ports["8080/tcp"]).ToNot(BeEmpty())
+				Expect(container.NetworkSettings.Ports["8080/tcp"][0].HostPort).To(Equal(hostPort))
 			})
 		})
 	})
@@ -0,0 +1,60 @@
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package docker
+
+import (
+	"strings"
+
+	. "github.com/clearcontainers/tests"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("push and pull", func() {
+	var repository string
+
+	BeforeEach(func() {
+		if SuiteRegistry() == nil {
+			Skip("pushing needs a local registry, see -local-registry")
+		}
+
+		// repository names are lower case
+		repository = SuiteRegistry().Repository("cc-tests/" + strings.ToLower(randomDockerName()))
+	})
+
+	AfterEach(func() {
+		DockerRmi(repository)
+	})
+
+	Context("push an image to a registry", func() {
+		It("should pull it back", func() {
+			_, _, exitCode := DockerTag(Image, repository)
+			Expect(exitCode).To(Equal(0))
+
+			_, _, exitCode = DockerPush(repository)
+			Expect(exitCode).To(Equal(0))
+
+			_, _, exitCode = DockerRmi(repository)
+			Expect(exitCode).To(Equal(0))
+
+			_, _, exitCode = DockerPull(repository)
+			Expect(exitCode).To(Equal(0))
+
+			stdout, _, exitCode := DockerImages("--format", "{{.Repository}}", repository)
+			Expect(exitCode).To(Equal(0))
+			Expect(stdout).To(ContainSubstring(repository))
+		})
+	})
+})
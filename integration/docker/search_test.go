@@ -27,7 +27,7 @@ var _ = Describe("docker search", func() {
 
 	Context("search an image", func() {
 		It("should filter the requests", func() {
-			args = []string{"--filter", "is-official=true", "--filter=stars=3", Image}
+			args = []string{"--filter", "is-official=true", "--filter=stars=3", RegistryRepository(Image)}
 			stdout, _, exitCode := DockerSearch(args...)
 			Expect(exitCode).To(Equal(0))
 			Expect(stdout).To(ContainSubstring(Image))
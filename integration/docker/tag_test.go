@@ -27,6 +27,12 @@ var _ = Describe("tag", func() {
 		tagName string
 	)
 
+	// the tag "container" is shared by the ginkgo nodes,
+	// the spec takes the host alone, see HostLock
+	BeforeEach(func() {
+		Expect(HostLock.Lock()).To(Succeed())
+	})
+
 	BeforeEach(func() {
 		id = randomDockerName()
 		_, _, exitCode := DockerRun("-td", "--name", id, Image, "sh")
@@ -15,6 +15,8 @@
 package docker
 
 import (
+	"time"
+
 	. "github.com/clearcontainers/tests"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -59,4 +61,73 @@ var _ = Describe("terminal", func() {
 			})
 		})
 	})
+
+	Describe("interactive terminal with docker", func() {
+		var session *Session
+
+		AfterEach(func() {
+			if session != nil {
+				Expect(session.Close()).To(Succeed())
+				session = nil
+			}
+		})
+
+		startShell := func(args ...string) {
+			var err error
+			args = append([]string{"--name", id, "-it", Image}, args...)
+			session, err = StartDockerSession("run", args...)
+			Expect(err).NotTo(HaveOccurred())
+		}
+
+		Context("typing commands in a shell", func() {
+			It("should display their output and exit code", func() {
+				startShell("sh")
+				Expect(session.Send("echo $((6 * 7))\n")).To(Succeed())
+				Expect(session.ExpectString("42")).To(Succeed())
+
+				Expect(session.Send("exit 7\n")).To(Succeed())
+				exitCode, err := session.Wait()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(exitCode).To(Equal(7))
+			})
+		})
+
+		Context("resizing the terminal", func() {
+			It("should change the window size of the container", func() {
+				startShell("sh")
+				Expect(session.Send("echo ready\n")).To(Succeed())
+				// the output line, not the echo of the command line
+				_, err := session.ExpectRegexp(`\nready\r?\n`)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(session.Console().Resize(33, 111)).To(Succeed())
+
+				// docker propagates the size asynchronously
+				Eventually(func() (string, error) {
+					if err := session.Send("stty size\n"); err != nil {
+						return "", err
+					}
+					match, err := session.ExpectRegexp(`\n(\d+ \d+)`)
+					if err != nil {
+						return "", err
+					}
+					return match[1], nil
+				}, 10*time.Second, time.Second).Should(Equal("33 111"))
+			})
+		})
+
+		Context("typing Ctrl-C", func() {
+			It("should interrupt the workload", func() {
+				startShell("sh", "-c", `trap "echo interrupted; exit 3" INT; echo ready; while true; do sleep 1; done`)
+				Expect(session.ExpectString("ready")).To(Succeed())
+
+				Expect(session.Interrupt()).To(Succeed())
+				Expect(session.ExpectString("interrupted")).To(Succeed())
+
+				exitCode, err := session.Wait()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(exitCode).To(Equal(3))
+			})
+		})
+	})
 })
@@ -36,12 +36,20 @@ var _ = Describe("docker volume", func() {
 		stdout        string
 	)
 
+	// the volume name is shared by the ginkgo nodes,
+	// the spec takes the host alone, see HostLock
+	BeforeEach(func() {
+		Expect(HostLock.Lock()).To(Succeed())
+	})
+
 	Context("create volume", func() {
 		It("should display the volume's name", func() {
 			_, _, exitCode = DockerVolume("create", "--name", volumeName)
 			Expect(exitCode).To(Equal(0))
-			_, _, exitCode = DockerVolume("inspect", volumeName)
-			Expect(exitCode).To(Equal(0))
+			volume, err := InspectDockerVolume(volumeName)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(volume.Name).To(Equal(volumeName))
+			Expect(volume.Driver).To(Equal("local"))
 			_, _, exitCode = DockerVolume("rm", volumeName)
 			Expect(exitCode).To(Equal(0))
 			stdout, _, exitCode = DockerVolume("ls")
@@ -61,6 +69,13 @@ var _ = Describe("docker volume", func() {
 			Expect(exitCode).To(Equal(0))
 			Expect(stdout).To(ContainSubstring(fileTest))
 
+			container, err := InspectDockerContainer(id2)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(container.Mounts).To(HaveLen(1))
+			Expect(container.Mounts[0].Type).To(Equal("volume"))
+			Expect(container.Mounts[0].Name).To(Equal(volumeName))
+			Expect(container.Mounts[0].Destination).To(Equal(path.Clean(containerPath)))
+
 			Expect(RemoveDockerContainer(id)).To(BeTrue())
 			Expect(ExistDockerContainer(id)).NotTo(BeTrue())
 			Expect(RemoveDockerContainer(id2)).To(BeTrue())
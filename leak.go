@@ -0,0 +1,183 @@
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tests
+
+import (
+	"flag"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// defaultLeakProcesses are the names of the processes
+	// looked for by the leak detectors
+	defaultLeakProcesses = "cc-shim,cc-proxy,qemu,cc-runtime"
+
+	// leakGracePeriod is the time given to the processes
+	// to finish before reporting them as leaked
+	leakGracePeriod = 5 * time.Second
+)
+
+// leakProcesses is the comma separated list of process names
+// looked for by the leak detectors
+var leakProcesses string
+
+func init() {
+	flag.StringVar(&leakProcesses, suiteFlag("leak-processes"), defaultLeakProcesses, "Comma separated names of the processes that must not be leaked by the tests")
+}
+
+// ProcessLeakDetector finds the runtime related processes started
+// and not finished between a Snapshot and a Check, e.g.
+//
+//	var detector = NewProcessLeakDetector()
+//
+//	BeforeEach(func() {
+//		Expect(detector.Snapshot()).To(Succeed())
+//	})
+//
+//	AfterEach(func() {
+//		Expect(detector.Check()).To(Succeed())
+//	})
+type ProcessLeakDetector struct {
+	// Names of the processes to look for, a process matches if
+	// the base name of its executable starts with any of them,
+	// if empty the names of the -leak-processes option are used
+	Names []string
+
+	// GracePeriod is the time Check waits for the processes to finish
+	GracePeriod time.Duration
+
+	snapshot map[int]ProcessInfo
+}
+
+// NewProcessLeakDetector returns a new ProcessLeakDetector looking for
+// the processes in names, if no names are given the ones specified by
+// the -leak-processes option are used
+func NewProcessLeakDetector(names ...string) *ProcessLeakDetector {
+	return &ProcessLeakDetector{
+		Names:       names,
+		GracePeriod: leakGracePeriod,
+	}
+}
+
+// Snapshot records the processes running now
+func (d *ProcessLeakDetector) Snapshot() error {
+	processes, err := d.processes()
+	if err != nil {
+		return err
+	}
+
+	d.snapshot = processes
+
+	return nil
+}
+
+// Leaked returns the processes running now that were not running
+// when Snapshot was called
+func (d *ProcessLeakDetector) Leaked() ([]ProcessInfo, error) {
+	if d.snapshot == nil {
+		return nil, fmt.Errorf("no process snapshot, Snapshot should be called first")
+	}
+
+	processes, err := d.processes()
+	if err != nil {
+		return nil, err
+	}
+
+	var leaked []ProcessInfo
+	for pid, p := range processes {
+		// a reused PID has a different command line
+		if old, ok := d.snapshot[pid]; !ok || old.Cmdline != p.Cmdline {
+			leaked = append(leaked, p)
+		}
+	}
+
+	sort.Slice(leaked, func(i, j int) bool {
+		return leaked[i].PID < leaked[j].PID
+	})
+
+	return leaked, nil
+}
+
+// Check returns an error listing the processes leaked since Snapshot
+// was called, processes are given GracePeriod to finish
+func (d *ProcessLeakDetector) Check() error {
+	deadline := time.Now().Add(d.GracePeriod)
+
+	for {
+		leaked, err := d.Leaked()
+		if err != nil {
+			return err
+		}
+
+		if len(leaked) == 0 {
+			return nil
+		}
+
+		if time.Now().After(deadline) {
+			var lines []string
+			for _, p := range leaked {
+				lines = append(lines, fmt.Sprintf("%d: %s", p.PID, p.Cmdline))
+			}
+
+			return fmt.Errorf("%d processes leaked:\n%s", len(leaked), strings.Join(lines, "\n"))
+		}
+
+		time.Sleep(stateInterval)
+	}
+}
+
+// processes returns the running processes whose name is in d.Names,
+// only the processes of the node are returned when running in parallel
+func (d *ProcessLeakDetector) processes() (map[int]ProcessInfo, error) {
+	processes, err := listProcesses()
+	if err != nil {
+		return nil, err
+	}
+
+	matched := make(map[int]ProcessInfo)
+	for _, p := range processes {
+		if d.matches(p) && ownedByNode(p.Cmdline) {
+			matched[p.PID] = p
+		}
+	}
+
+	return matched, nil
+}
+
+func (d *ProcessLeakDetector) matches(p ProcessInfo) bool {
+	name := filepath.Base(strings.SplitN(p.Cmdline, " ", 2)[0])
+
+	names := d.Names
+	if len(names) == 0 {
+		// the options are parsed after the detectors are created
+		names = strings.Split(leakProcesses, ",")
+	}
+
+	for _, n := range names {
+		if n = strings.TrimSpace(n); n == "" {
+			continue
+		}
+
+		if strings.HasPrefix(name, n) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,110 @@
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tests
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newLeakTestCommand returns a sleep command whose executable has
+// a unique name, no other process of the host matches it
+func newLeakTestCommand(t *testing.T, seconds string) (*exec.Cmd, string) {
+	sleep, err := exec.LookPath("sleep")
+	if err != nil {
+		t.Skip("sleep not found")
+	}
+
+	name := "leak" + RandID(8)
+	path := filepath.Join(testDir, name)
+	if err := os.Symlink(sleep, path); err != nil {
+		t.Fatal(err)
+	}
+
+	return exec.Command(path, seconds), name
+}
+
+func TestProcessLeakDetector(t *testing.T) {
+	assert := assert.New(t)
+
+	cmd, name := newLeakTestCommand(t, "30")
+	detector := NewProcessLeakDetector(name)
+	detector.GracePeriod = 0
+
+	assert.Error(detector.Check(), "Check before Snapshot")
+
+	assert.NoError(detector.Snapshot())
+	assert.NoError(detector.Check())
+
+	assert.NoError(cmd.Start())
+
+	err := detector.Check()
+	if assert.Error(err) {
+		assert.Contains(err.Error(), fmt.Sprintf("%d: %s", cmd.Process.Pid, cmd.Path))
+	}
+
+	assert.NoError(cmd.Process.Kill())
+	cmd.Wait()
+
+	assert.NoError(detector.Check())
+}
+
+func TestProcessLeakDetectorGracePeriod(t *testing.T) {
+	assert := assert.New(t)
+
+	cmd, name := newLeakTestCommand(t, "0.5")
+	detector := NewProcessLeakDetector(name)
+	detector.GracePeriod = 5 * time.Second
+
+	assert.NoError(detector.Snapshot())
+	assert.NoError(cmd.Start())
+
+	// the process is reaped while Check waits for it
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+
+	start := time.Now()
+	assert.NoError(detector.Check())
+	assert.True(time.Since(start) < detector.GracePeriod)
+	assert.NoError(<-done)
+}
+
+func TestProcessLeakDetectorOption(t *testing.T) {
+	assert := assert.New(t)
+
+	defer func(names string) { leakProcesses = names }(leakProcesses)
+
+	// the option is parsed after the detector is created
+	detector := NewProcessLeakDetector()
+	detector.GracePeriod = 0
+
+	cmd, name := newLeakTestCommand(t, "30")
+	leakProcesses = "cc-unknown, " + name
+
+	assert.NoError(detector.Snapshot())
+	assert.NoError(cmd.Start())
+	defer cmd.Wait()
+	defer cmd.Process.Kill()
+
+	leaked, err := detector.Leaked()
+	assert.NoError(err)
+	assert.Equal([]ProcessInfo{{PID: cmd.Process.Pid, Cmdline: cmd.Path + " 30"}}, leaked)
+}
@@ -0,0 +1,114 @@
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tests
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"golang.org/x/sys/unix"
+)
+
+// locksDir is the directory of the lock files, shared by the ginkgo nodes
+var locksDir = filepath.Join(tmpDir, "cc-tests-locks")
+
+// HostLock is held shared by the integration specs while they run, the
+// specs that need the host alone (e.g. the ones creating images, volumes
+// or networks with fixed names) take it exclusively:
+//
+//	BeforeEach(func() {
+//		Expect(HostLock.Lock()).To(Succeed())
+//	})
+//
+// The suite releases it after each spec.
+var HostLock = NewFileLock("host")
+
+// FileLock is a readers-writer lock shared by the processes of the host,
+// e.g. the ginkgo nodes, it is a flock(2) on a file of locksDir.
+// A process holds it once, locking it again converts the lock.
+type FileLock struct {
+	name string
+
+	// mutex protects f
+	mutex sync.Mutex
+	f     *os.File
+}
+
+// NewFileLock returns the lock called name
+func NewFileLock(name string) *FileLock {
+	return &FileLock{
+		name: name,
+	}
+}
+
+// Lock waits until no other process holds the lock and takes it exclusively
+func (l *FileLock) Lock() error {
+	return l.flock(unix.LOCK_EX)
+}
+
+// RLock waits until no other process holds the lock
+// exclusively and takes it shared
+func (l *FileLock) RLock() error {
+	return l.flock(unix.LOCK_SH)
+}
+
+// Unlock releases the lock, releasing a lock not held does nothing
+func (l *FileLock) Unlock() error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.f == nil {
+		return nil
+	}
+
+	// closing the file releases the lock
+	err := l.f.Close()
+	l.f = nil
+
+	return err
+}
+
+func (l *FileLock) flock(how int) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.f == nil {
+		if err := os.MkdirAll(locksDir, 0755); err != nil {
+			return err
+		}
+
+		f, err := os.OpenFile(filepath.Join(locksDir, l.name+".lock"), os.O_RDWR|os.O_CREATE, 0644)
+		if err != nil {
+			return err
+		}
+
+		l.f = f
+	}
+
+	for {
+		err := unix.Flock(int(l.f.Fd()), how)
+		if err == unix.EINTR {
+			continue
+		}
+
+		if err != nil {
+			return fmt.Errorf("failed to lock %s: %v", l.name, err)
+		}
+
+		return nil
+	}
+}
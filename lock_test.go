@@ -0,0 +1,69 @@
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileLock(t *testing.T) {
+	assert := assert.New(t)
+
+	name := "test-" + RandID(10)
+	defer os.Remove(filepath.Join(locksDir, name+".lock"))
+
+	// the locks of different FileLocks conflict, even in the same process
+	l1 := NewFileLock(name)
+	l2 := NewFileLock(name)
+
+	assert.NoError(l1.RLock())
+	assert.NoError(l2.RLock())
+
+	locked := make(chan error)
+	go func() {
+		locked <- l1.Lock()
+	}()
+
+	select {
+	case <-locked:
+		t.Fatal("the lock was taken exclusively while shared")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	assert.NoError(l2.Unlock())
+	assert.NoError(<-locked)
+
+	go func() {
+		locked <- l2.RLock()
+	}()
+
+	select {
+	case <-locked:
+		t.Fatal("the lock was shared while taken exclusively")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	assert.NoError(l1.Unlock())
+	assert.NoError(<-locked)
+	assert.NoError(l2.Unlock())
+
+	// releasing a lock not held does nothing
+	assert.NoError(l2.Unlock())
+}
@@ -0,0 +1,112 @@
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tests
+
+import (
+	"archive/tar"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// testDir keeps the fake runtime, its state and the test rootfs
+var testDir string
+
+// testRootfs is a tarball with an almost empty rootfs, the
+// fake runtime runs the container processes in the host
+var testRootfs string
+
+// TestMain builds cmd/fakeruntime and uses it as runtime,
+// the tests do not need a VM based runtime nor docker
+func TestMain(m *testing.M) {
+	var err error
+	testDir, err = ioutil.TempDir("", "cc-tests")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	code, err := runTests(m)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
+
+	os.RemoveAll(testDir)
+	os.Exit(code)
+}
+
+func runTests(m *testing.M) (int, error) {
+	Runtime = filepath.Join(testDir, "fakeruntime")
+	build := exec.Command("go", "build", "-o", Runtime, "./cmd/fakeruntime")
+	if out, err := build.CombinedOutput(); err != nil {
+		return 1, fmt.Errorf("failed to build fakeruntime: %v\n%s", err, out)
+	}
+
+	if err := os.Setenv("FAKERUNTIME_ROOT", filepath.Join(testDir, "state")); err != nil {
+		return 1, err
+	}
+
+	testRootfs = filepath.Join(testDir, "rootfs.tar")
+	if err := writeTestRootfs(testRootfs); err != nil {
+		return 1, err
+	}
+
+	return m.Run(), nil
+}
+
+func writeTestRootfs(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := tar.NewWriter(f)
+	for _, dir := range []string{"bin/", "tmp/"} {
+		hdr := &tar.Header{
+			Name:     dir,
+			Mode:     0755,
+			Typeflag: tar.TypeDir,
+		}
+
+		if err := w.WriteHeader(hdr); err != nil {
+			return err
+		}
+	}
+
+	return w.Close()
+}
+
+// newTestContainer returns a container running workload with the fake runtime
+func newTestContainer(t *testing.T, workload ...string) *Container {
+	b, err := NewBundleFromTar(workload, testRootfs)
+	if err != nil {
+		t.Fatalf("failed to create bundle: %v", err)
+	}
+
+	pidFile := filepath.Join(b.Path, "pid")
+	logFile := filepath.Join(b.Path, "log")
+	id := RandID(20)
+
+	return &Container{
+		Bundle:  b,
+		PidFile: &pidFile,
+		LogFile: &logFile,
+		ID:      &id,
+	}
+}
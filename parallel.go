@@ -0,0 +1,94 @@
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tests
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/onsi/ginkgo/config"
+)
+
+// The suites can run their specs in several processes with ginkgo -p,
+// the ginkgo nodes share the host:
+//  - RandID starts with the node marker (e.g. node2-) so that the names
+//    of the containers, and the host resources named after them, are
+//    unique and can be told apart
+//  - the bundles and the temporary files of each node are kept in its
+//    own directory, see NodeDir
+//  - AllocatePort gives the free host ports of each node
+//  - FileLock serializes the specs that need the host alone
+// The leak detectors only report the processes and host resources
+// of their node, i.e. the ones with the node marker or directory.
+
+// ParallelNode returns the ginkgo node running the specs, from 1
+func ParallelNode() int {
+	if config.GinkgoConfig.ParallelNode < 1 {
+		return 1
+	}
+
+	return config.GinkgoConfig.ParallelNode
+}
+
+// Parallel returns true if the specs run in several ginkgo nodes
+func Parallel() bool {
+	return config.GinkgoConfig.ParallelTotal > 1
+}
+
+// nodeMarker returns the prefix of the IDs of the node,
+// empty if the specs do not run in parallel
+func nodeMarker() string {
+	if !Parallel() {
+		return ""
+	}
+
+	return fmt.Sprintf("node%d-", ParallelNode())
+}
+
+// NodeDir returns the directory where the node keeps its bundles and
+// temporary files, the directory is created if it does not exist
+func NodeDir() (string, error) {
+	if !Parallel() {
+		return tmpDir, nil
+	}
+
+	dir := nodeDirPath()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+
+	return dir, nil
+}
+
+func nodeDirPath() string {
+	if !Parallel() {
+		return tmpDir
+	}
+
+	return filepath.Join(tmpDir, fmt.Sprintf("cc-tests-node%d", ParallelNode()))
+}
+
+// ownedByNode returns true if the resource described by s belongs to
+// the node, i.e. it names the node marker or the node directory.
+// All the resources belong to the node when not running in parallel
+func ownedByNode(s string) bool {
+	if !Parallel() {
+		return true
+	}
+
+	return strings.Contains(s, nodeMarker()) || strings.Contains(s, nodeDirPath()+"/")
+}
@@ -0,0 +1,119 @@
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tests
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/onsi/ginkgo/config"
+	"github.com/stretchr/testify/assert"
+)
+
+// withParallelNode runs f as the node of total ginkgo nodes
+func withParallelNode(node, total int, f func()) {
+	saved := config.GinkgoConfig
+	defer func() {
+		config.GinkgoConfig = saved
+	}()
+
+	config.GinkgoConfig.ParallelNode = node
+	config.GinkgoConfig.ParallelTotal = total
+
+	f()
+}
+
+func TestParallelNames(t *testing.T) {
+	assert := assert.New(t)
+
+	id := RandID(20)
+	assert.Len(id, 20)
+	assert.False(strings.HasPrefix(id, "node"))
+	assert.True(ownedByNode("/run/virtcontainers/pods/" + id))
+
+	withParallelNode(2, 4, func() {
+		assert.True(Parallel())
+		assert.Equal(2, ParallelNode())
+
+		id := RandID(20)
+		assert.Len(id, len("node2-")+20)
+		assert.True(strings.HasPrefix(id, "node2-"))
+
+		// the marker does not take the random characters
+		assert.Len(RandID(4), len("node2-")+4)
+
+		assert.True(ownedByNode("cc-shim -c " + id + " -t token"))
+		assert.True(ownedByNode("/tmp/cc-tests-node2/bundle123/rootfs overlay overlay"))
+		assert.False(ownedByNode("cc-shim -c node12-abc -t token"))
+		assert.False(ownedByNode("/tmp/cc-tests-node12/bundle123/rootfs overlay overlay"))
+		assert.False(ownedByNode("tap0"))
+	})
+}
+
+func TestNodeDir(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := NodeDir()
+	assert.NoError(err)
+	assert.Equal(tmpDir, dir)
+
+	withParallelNode(3, 4, func() {
+		dir, err := NodeDir()
+		assert.NoError(err)
+		assert.Equal(filepath.Join(tmpDir, "cc-tests-node3"), dir)
+		defer os.RemoveAll(dir)
+
+		b, err := NewBundleFromTar([]string{"true"}, testRootfs)
+		assert.NoError(err)
+		assert.Equal(dir, filepath.Dir(b.Path))
+		assert.NoError(b.Remove())
+	})
+}
+
+func TestAllocatePort(t *testing.T) {
+	assert := assert.New(t)
+
+	var ports []int
+	withParallelNode(2, 4, func() {
+		for i := 0; i < 2; i++ {
+			port, err := AllocatePort()
+			assert.NoError(err)
+			assert.True(port >= portRangeStart+portsPerNode && port < portRangeStart+2*portsPerNode, "%d", port)
+			ports = append(ports, port)
+		}
+	})
+	assert.NotEqual(ports[0], ports[1])
+
+	ReleasePort(ports[1])
+
+	// ports in use are skipped
+	l, err := net.Listen("tcp", ":"+strconv.Itoa(ports[1]))
+	if err == nil {
+		defer l.Close()
+
+		withParallelNode(2, 4, func() {
+			port, err := AllocatePort()
+			assert.NoError(err)
+			assert.NotEqual(ports[1], port)
+			ReleasePort(port)
+		})
+	}
+
+	ReleasePort(ports[0])
+}
@@ -0,0 +1,84 @@
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tests
+
+import (
+	"fmt"
+	"net"
+	"sync"
+)
+
+const (
+	// portRangeStart is the first host port given by AllocatePort
+	portRangeStart = 20000
+
+	// portRangeEnd is the end of the host ports given by AllocatePort
+	portRangeEnd = 65000
+
+	// portsPerNode is the number of host ports of each ginkgo node
+	portsPerNode = 500
+)
+
+// allocatedPorts are the ports given by AllocatePort and not released
+var allocatedPorts = struct {
+	sync.Mutex
+	ports map[int]bool
+}{
+	ports: make(map[int]bool),
+}
+
+// AllocatePort returns a free host TCP port, e.g. for docker run -p,
+// each ginkgo node takes its ports from a different range so that the
+// nodes do not get the same port. The port should be released with
+// ReleasePort once the container using it is removed
+func AllocatePort() (int, error) {
+	allocatedPorts.Lock()
+	defer allocatedPorts.Unlock()
+
+	nodes := (portRangeEnd - portRangeStart) / portsPerNode
+	first := portRangeStart + ((ParallelNode()-1)%nodes)*portsPerNode
+
+	for port := first; port < first+portsPerNode; port++ {
+		if allocatedPorts.ports[port] || !portFree(port) {
+			continue
+		}
+
+		allocatedPorts.ports[port] = true
+
+		return port, nil
+	}
+
+	return 0, fmt.Errorf("no free host port in %d-%d", first, first+portsPerNode-1)
+}
+
+// ReleasePort makes the port given by AllocatePort available again
+func ReleasePort(port int) {
+	allocatedPorts.Lock()
+	defer allocatedPorts.Unlock()
+
+	delete(allocatedPorts.ports, port)
+}
+
+// portFree returns true if nothing listens on the TCP port of the host
+func portFree(port int) bool {
+	l, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+	if err != nil {
+		return false
+	}
+
+	l.Close()
+
+	return true
+}
@@ -0,0 +1,69 @@
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tests
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const procPath = "/proc"
+
+// ProcessInfo describes a process running in the host
+type ProcessInfo struct {
+	// PID is the process ID
+	PID int
+
+	// Cmdline is the command line of the process,
+	// its arguments are separated by spaces
+	Cmdline string
+}
+
+// listProcesses returns the processes running in the host,
+// kernel threads are not returned since they have no command line
+func listProcesses() ([]ProcessInfo, error) {
+	entries, err := ioutil.ReadDir(procPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var processes []ProcessInfo
+
+	for _, e := range entries {
+		// only the top-level numeric entries are processes,
+		// their tasks are not needed
+		pid, err := strconv.Atoi(e.Name())
+		if err != nil || !e.IsDir() {
+			continue
+		}
+
+		content, err := ioutil.ReadFile(filepath.Join(procPath, e.Name(), "cmdline"))
+		if err != nil || len(content) == 0 {
+			// the process has finished or it is a kernel thread
+			continue
+		}
+
+		cmdline := string(bytes.TrimRight(content, "\x00"))
+		processes = append(processes, ProcessInfo{
+			PID:     pid,
+			Cmdline: strings.Replace(cmdline, "\x00", " ", -1),
+		})
+	}
+
+	return processes, nil
+}
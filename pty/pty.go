@@ -0,0 +1,100 @@
+// Copyright (c) 2014,2015,2016 Docker, Inc.
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package pty allocates the pseudo terminals of the consoles of
+// the test suites and of localCI
+package pty
+
+import (
+	"fmt"
+	"os"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+const ptmxPath = "/dev/ptmx"
+
+// Open allocates a new pseudo terminal pair, it returns the master
+// and the path of the slave, e.g. /dev/pts/3
+func Open() (*os.File, string, error) {
+	master, err := os.OpenFile(ptmxPath, unix.O_RDWR|unix.O_NOCTTY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if err := SaneTerminal(master); err != nil {
+		master.Close()
+		return nil, "", err
+	}
+
+	slavePath, err := Ptsname(master)
+	if err != nil {
+		master.Close()
+		return nil, "", err
+	}
+
+	if err := Unlockpt(master); err != nil {
+		master.Close()
+		return nil, "", err
+	}
+
+	return master, slavePath, nil
+}
+
+// Ioctl runs the ioctl request on f
+func Ioctl(f *os.File, request, data uintptr) error {
+	if _, _, errno := unix.Syscall(unix.SYS_IOCTL, f.Fd(), request, data); errno != 0 {
+		return errno
+	}
+
+	return nil
+}
+
+// Unlockpt unlocks the slave of the pseudo terminal master f,
+// it must be called before opening the slave
+func Unlockpt(f *os.File) error {
+	var u int32
+	return Ioctl(f, unix.TIOCSPTLCK, uintptr(unsafe.Pointer(&u)))
+}
+
+// Ptsname returns the path of the slave of the pseudo terminal master f
+func Ptsname(f *os.File) (string, error) {
+	var n int32
+	if err := Ioctl(f, unix.TIOCGPTN, uintptr(unsafe.Pointer(&n))); err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("/dev/pts/%d", n), nil
+}
+
+// SaneTerminal disables the translation of \n into \r\n (onlcr) that
+// Linux unix98 ptys do by default, the output read from the master
+// is the output of the process
+func SaneTerminal(terminal *os.File) error {
+	var termios unix.Termios
+
+	if err := Ioctl(terminal, unix.TCGETS, uintptr(unsafe.Pointer(&termios))); err != nil {
+		return fmt.Errorf("ioctl(tty, tcgets): %v", err)
+	}
+
+	termios.Oflag &^= unix.ONLCR
+
+	if err := Ioctl(terminal, unix.TCSETS, uintptr(unsafe.Pointer(&termios))); err != nil {
+		return fmt.Errorf("ioctl(tty, tcsets): %v", err)
+	}
+
+	return nil
+}
@@ -16,6 +16,7 @@ package tests
 
 import (
 	"math/rand"
+	"sync"
 	"time"
 )
 
@@ -23,12 +24,25 @@ const letters = "0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
 
 const lettersMask = 63
 
-var randSrc = rand.NewSource(time.Now().UnixNano())
+// randSrc is locked, rand sources are not safe for concurrent use
+var randSrc = struct {
+	sync.Mutex
+	rand.Source
+}{
+	Source: rand.NewSource(time.Now().UnixNano()),
+}
 
-// RandID returns a random string
+// RandID returns a random string of n characters, when the specs run in
+// parallel it is prefixed with the marker of the ginkgo node, e.g. node2-
 func RandID(n int) string {
-	b := make([]byte, n)
-	for i := 0; i < n; {
+	marker := nodeMarker()
+	b := make([]byte, len(marker)+n)
+	i := copy(b, marker)
+
+	randSrc.Lock()
+	defer randSrc.Unlock()
+
+	for i < len(b) {
 		if j := int(randSrc.Int63() & lettersMask); j < len(letters) {
 			b[i] = letters[j]
 			i++
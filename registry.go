@@ -0,0 +1,584 @@
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tests
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// registryPathRegexp matches the paths of the registry API v2,
+// the repository names can have slashes
+var registryPathRegexp = regexp.MustCompile(`^/v2/(.+)/(blobs/uploads/?(.*)|blobs/(sha256:[0-9a-f]+)|manifests/([^/]+)|tags/list)$`)
+
+// Registry is a local docker registry serving the registry API v2 and
+// the search of the API v1 on a local port, the specs pull, push and
+// search images without network, e.g.
+//
+//	r, err := StartRegistry()
+//	Expect(err).ToNot(HaveOccurred())
+//	defer r.Close()
+//
+//	_, _, exitCode := DockerTag(Image, r.Repository("busybox"))
+//	Expect(exitCode).To(Equal(0))
+//	_, _, exitCode = DockerPush(r.Repository("busybox"))
+//	Expect(exitCode).To(Equal(0))
+//
+// The registry listens on 127.0.0.1, docker talks to it without
+// TLS since 127.0.0.0/8 is an insecure registry by default.
+// The blobs are streamed to files in a temporary directory of the
+// ginkgo node, the manifests are kept in memory.
+type Registry struct {
+	listener net.Listener
+	server   *http.Server
+
+	// dir is the directory of the blob files, removed by Close
+	dir string
+
+	// mutex protects the fields below
+	mutex sync.Mutex
+
+	// blobs are the files of the blobs of all the repositories by digest
+	blobs map[string]string
+
+	// uploads are the blob uploads in progress by ID
+	uploads map[string]*registryUpload
+
+	// repositories are the manifests of the repositories
+	// by repository name, reference and digest
+	repositories map[string]map[string]registryManifest
+
+	// searchInfo is what the search shows of each repository
+	searchInfo map[string]RegistrySearchInfo
+
+	lastUpload int
+}
+
+// RegistrySearchInfo is what docker search shows of a repository
+type RegistrySearchInfo struct {
+	Description string
+	Stars       int
+	Official    bool
+	Automated   bool
+}
+
+// registryUpload is a blob upload in progress, the content is written
+// to a temporary file as it is received
+type registryUpload struct {
+	// mutex serializes the requests of the upload
+	mutex sync.Mutex
+
+	file *os.File
+	hash hash.Hash
+	size int64
+}
+
+type registryManifest struct {
+	mediaType string
+	digest    string
+	content   []byte
+}
+
+type registryError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// StartRegistry starts a new empty registry
+func StartRegistry() (*Registry, error) {
+	nodeDir, err := NodeDir()
+	if err != nil {
+		return nil, err
+	}
+
+	dir, err := ioutil.TempDir(nodeDir, "registry")
+	if err != nil {
+		return nil, err
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+
+	r := &Registry{
+		listener:     listener,
+		dir:          dir,
+		blobs:        make(map[string]string),
+		uploads:      make(map[string]*registryUpload),
+		repositories: make(map[string]map[string]registryManifest),
+		searchInfo:   make(map[string]RegistrySearchInfo),
+	}
+
+	r.server = &http.Server{Handler: r}
+
+	go r.server.Serve(listener)
+
+	return r, nil
+}
+
+// Host returns the host of the registry, e.g. 127.0.0.1:41234
+func (r *Registry) Host() string {
+	return r.listener.Addr().String()
+}
+
+// Repository returns the reference of the repository name in the
+// registry, e.g. Repository("busybox") is 127.0.0.1:41234/busybox
+func (r *Registry) Repository(name string) string {
+	return r.Host() + "/" + name
+}
+
+// SetSearchInfo sets what docker search shows of the repository name
+func (r *Registry) SetSearchInfo(name string, info RegistrySearchInfo) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.searchInfo[name] = info
+}
+
+// Repositories returns the names of the repositories with manifests
+func (r *Registry) Repositories() []string {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	var names []string
+	for name := range r.repositories {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// Close stops the registry, its content is lost
+func (r *Registry) Close() error {
+	err := r.server.Close()
+
+	r.mutex.Lock()
+	for id, upload := range r.uploads {
+		upload.file.Close()
+		delete(r.uploads, id)
+	}
+	r.mutex.Unlock()
+
+	if rmErr := os.RemoveAll(r.dir); err == nil {
+		err = rmErr
+	}
+
+	return err
+}
+
+// ServeHTTP serves the requests of the registry API
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Docker-Distribution-API-Version", "registry/2.0")
+
+	switch {
+	case req.URL.Path == "/v2/" || req.URL.Path == "/v2":
+		r.writeJSON(w, http.StatusOK, struct{}{})
+		return
+	case req.URL.Path == "/v2/_catalog":
+		r.writeJSON(w, http.StatusOK, map[string][]string{"repositories": r.Repositories()})
+		return
+	case req.URL.Path == "/v1/_ping":
+		w.Header().Set("X-Docker-Registry-Version", "0.6.0")
+		r.writeJSON(w, http.StatusOK, true)
+		return
+	case req.URL.Path == "/v1/search":
+		r.search(w, req)
+		return
+	}
+
+	m := registryPathRegexp.FindStringSubmatch(req.URL.Path)
+	if m == nil {
+		r.writeError(w, http.StatusNotFound, "UNSUPPORTED", "unsupported request "+req.URL.Path)
+		return
+	}
+
+	name := m[1]
+
+	switch {
+	case strings.HasPrefix(m[2], "blobs/uploads"):
+		r.upload(w, req, name, m[3])
+	case m[4] != "":
+		r.blob(w, req, m[4])
+	case m[5] != "":
+		r.manifest(w, req, name, m[5])
+	default:
+		r.tags(w, req, name)
+	}
+}
+
+func (r *Registry) blob(w http.ResponseWriter, req *http.Request, digest string) {
+	r.mutex.Lock()
+	path, ok := r.blobs[digest]
+	r.mutex.Unlock()
+
+	if !ok {
+		r.writeError(w, http.StatusNotFound, "BLOB_UNKNOWN", "blob unknown to registry")
+		return
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		r.writeError(w, http.StatusInternalServerError, "UNKNOWN", err.Error())
+		return
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		r.writeError(w, http.StatusInternalServerError, "UNKNOWN", err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	r.writeContent(w, req, digest, f, info.Size())
+}
+
+// upload handles the blob uploads, docker starts them with a POST, sends
+// the content with PATCH requests and completes them with a PUT
+func (r *Registry) upload(w http.ResponseWriter, req *http.Request, name, id string) {
+	switch req.Method {
+	case http.MethodPost:
+		// the blob can be mounted from another repository
+		if mount := req.URL.Query().Get("mount"); mount != "" {
+			r.mutex.Lock()
+			_, ok := r.blobs[mount]
+			r.mutex.Unlock()
+
+			if ok {
+				r.writeCreated(w, fmt.Sprintf("/v2/%s/blobs/%s", name, mount), mount)
+				return
+			}
+		}
+
+		upload, err := r.newUpload()
+		if err != nil {
+			r.writeError(w, http.StatusInternalServerError, "UNKNOWN", err.Error())
+			return
+		}
+
+		if err := upload.write(req.Body); err != nil {
+			upload.remove()
+			r.writeError(w, http.StatusBadRequest, "BLOB_UPLOAD_INVALID", err.Error())
+			return
+		}
+
+		// monolithic upload
+		if digest := req.URL.Query().Get("digest"); digest != "" {
+			r.storeBlob(w, name, digest, upload)
+			return
+		}
+
+		r.mutex.Lock()
+		r.lastUpload++
+		id = strconv.Itoa(r.lastUpload)
+		r.uploads[id] = upload
+		r.mutex.Unlock()
+
+		r.writeUploadStatus(w, name, id, upload.size)
+	case http.MethodPatch:
+		r.mutex.Lock()
+		upload, ok := r.uploads[id]
+		r.mutex.Unlock()
+
+		if !ok {
+			r.writeError(w, http.StatusNotFound, "BLOB_UPLOAD_UNKNOWN", "blob upload unknown to registry")
+			return
+		}
+
+		if err := upload.write(req.Body); err != nil {
+			r.writeError(w, http.StatusBadRequest, "BLOB_UPLOAD_INVALID", err.Error())
+			return
+		}
+
+		r.writeUploadStatus(w, name, id, upload.size)
+	case http.MethodPut:
+		r.mutex.Lock()
+		upload, ok := r.uploads[id]
+		delete(r.uploads, id)
+		r.mutex.Unlock()
+
+		if !ok {
+			r.writeError(w, http.StatusNotFound, "BLOB_UPLOAD_UNKNOWN", "blob upload unknown to registry")
+			return
+		}
+
+		if err := upload.write(req.Body); err != nil {
+			upload.remove()
+			r.writeError(w, http.StatusBadRequest, "BLOB_UPLOAD_INVALID", err.Error())
+			return
+		}
+
+		r.storeBlob(w, name, req.URL.Query().Get("digest"), upload)
+	case http.MethodDelete:
+		r.mutex.Lock()
+		upload, ok := r.uploads[id]
+		delete(r.uploads, id)
+		r.mutex.Unlock()
+
+		if ok {
+			upload.remove()
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		r.writeError(w, http.StatusMethodNotAllowed, "UNSUPPORTED", req.Method+" not supported")
+	}
+}
+
+// newUpload starts a blob upload in a new temporary file
+func (r *Registry) newUpload() (*registryUpload, error) {
+	file, err := ioutil.TempFile(r.dir, "upload")
+	if err != nil {
+		return nil, err
+	}
+
+	return &registryUpload{
+		file: file,
+		hash: sha256.New(),
+	}, nil
+}
+
+// write appends content to the upload
+func (u *registryUpload) write(content io.Reader) error {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+
+	n, err := io.Copy(io.MultiWriter(u.file, u.hash), content)
+	u.size += n
+
+	return err
+}
+
+// digest returns the digest of the content uploaded
+func (u *registryUpload) digest() string {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+
+	return "sha256:" + hex.EncodeToString(u.hash.Sum(nil))
+}
+
+// remove discards the upload
+func (u *registryUpload) remove() {
+	u.file.Close()
+	os.Remove(u.file.Name())
+}
+
+// storeBlob stores the completed upload as the blob digest
+func (r *Registry) storeBlob(w http.ResponseWriter, name, digest string, upload *registryUpload) {
+	if actual := upload.digest(); digest != actual {
+		upload.remove()
+		r.writeError(w, http.StatusBadRequest, "DIGEST_INVALID",
+			fmt.Sprintf("digest %s does not match the content %s", digest, actual))
+		return
+	}
+
+	// the digest matches the content, it is a valid file name
+	path := filepath.Join(r.dir, strings.TrimPrefix(digest, "sha256:"))
+
+	err := upload.file.Close()
+	if err == nil {
+		err = os.Rename(upload.file.Name(), path)
+	}
+	if err != nil {
+		upload.remove()
+		r.writeError(w, http.StatusInternalServerError, "UNKNOWN", err.Error())
+		return
+	}
+
+	r.mutex.Lock()
+	r.blobs[digest] = path
+	r.mutex.Unlock()
+
+	r.writeCreated(w, fmt.Sprintf("/v2/%s/blobs/%s", name, digest), digest)
+}
+
+func (r *Registry) manifest(w http.ResponseWriter, req *http.Request, name, reference string) {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead:
+		r.mutex.Lock()
+		manifest, ok := r.repositories[name][reference]
+		r.mutex.Unlock()
+
+		if !ok {
+			r.writeError(w, http.StatusNotFound, "MANIFEST_UNKNOWN", "manifest unknown")
+			return
+		}
+
+		w.Header().Set("Content-Type", manifest.mediaType)
+		r.writeContent(w, req, manifest.digest, bytes.NewReader(manifest.content), int64(len(manifest.content)))
+	case http.MethodPut:
+		content, err := ioutil.ReadAll(req.Body)
+		if err != nil {
+			r.writeError(w, http.StatusBadRequest, "MANIFEST_INVALID", err.Error())
+			return
+		}
+
+		manifest := registryManifest{
+			mediaType: req.Header.Get("Content-Type"),
+			digest:    registryDigest(content),
+			content:   content,
+		}
+
+		r.mutex.Lock()
+		defer r.mutex.Unlock()
+
+		if r.repositories[name] == nil {
+			r.repositories[name] = make(map[string]registryManifest)
+		}
+		r.repositories[name][reference] = manifest
+		r.repositories[name][manifest.digest] = manifest
+
+		r.writeCreated(w, fmt.Sprintf("/v2/%s/manifests/%s", name, manifest.digest), manifest.digest)
+	default:
+		r.writeError(w, http.StatusMethodNotAllowed, "UNSUPPORTED", req.Method+" not supported")
+	}
+}
+
+func (r *Registry) tags(w http.ResponseWriter, req *http.Request, name string) {
+	r.mutex.Lock()
+	manifests, ok := r.repositories[name]
+	tags := []string{}
+	for reference := range manifests {
+		if !strings.HasPrefix(reference, "sha256:") {
+			tags = append(tags, reference)
+		}
+	}
+	r.mutex.Unlock()
+
+	if !ok {
+		r.writeError(w, http.StatusNotFound, "NAME_UNKNOWN", "repository name not known to registry")
+		return
+	}
+
+	sort.Strings(tags)
+
+	r.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"name": name,
+		"tags": tags,
+	})
+}
+
+// search serves the search of the API v1, the repositories
+// whose name contains the term q are found
+func (r *Registry) search(w http.ResponseWriter, req *http.Request) {
+	type result struct {
+		Name        string `json:"name"`
+		Description string `json:"description"`
+		StarCount   int    `json:"star_count"`
+		IsOfficial  bool   `json:"is_official"`
+		IsAutomated bool   `json:"is_automated"`
+	}
+
+	term := req.URL.Query().Get("q")
+	results := []result{}
+
+	for _, name := range r.Repositories() {
+		if !strings.Contains(name, term) {
+			continue
+		}
+
+		r.mutex.Lock()
+		info := r.searchInfo[name]
+		r.mutex.Unlock()
+
+		results = append(results, result{
+			Name:        name,
+			Description: info.Description,
+			StarCount:   info.Stars,
+			IsOfficial:  info.Official,
+			IsAutomated: info.Automated,
+		})
+	}
+
+	r.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"query":       term,
+		"num_results": len(results),
+		"results":     results,
+	})
+}
+
+func (r *Registry) writeContent(w http.ResponseWriter, req *http.Request, digest string, content io.Reader, size int64) {
+	w.Header().Set("Docker-Content-Digest", digest)
+	w.Header().Set("Content-Length", strconv.FormatInt(size, 10))
+	w.WriteHeader(http.StatusOK)
+
+	if req.Method != http.MethodHead {
+		io.Copy(w, content)
+	}
+}
+
+func (r *Registry) writeUploadStatus(w http.ResponseWriter, name, id string, size int64) {
+	w.Header().Set("Location", fmt.Sprintf("/v2/%s/blobs/uploads/%s", name, id))
+	w.Header().Set("Docker-Upload-UUID", id)
+	// the range is inclusive, 0-0 when nothing was uploaded yet
+	end := size - 1
+	if end < 0 {
+		end = 0
+	}
+
+	w.Header().Set("Range", fmt.Sprintf("0-%d", end))
+	w.Header().Set("Content-Length", "0")
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (r *Registry) writeCreated(w http.ResponseWriter, location, digest string) {
+	w.Header().Set("Location", location)
+	w.Header().Set("Docker-Content-Digest", digest)
+	w.Header().Set("Content-Length", "0")
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (r *Registry) writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	content, err := json.Marshal(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(content)
+}
+
+func (r *Registry) writeError(w http.ResponseWriter, status int, code, message string) {
+	r.writeJSON(w, status, map[string][]registryError{
+		"errors": {{Code: code, Message: message}},
+	})
+}
+
+// registryDigest returns the digest of content, e.g. sha256:e3b0c442...
+func registryDigest(content []byte) string {
+	sum := sha256.Sum256(content)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
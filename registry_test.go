@@ -0,0 +1,188 @@
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testManifestMediaType = "application/vnd.docker.distribution.manifest.v2+json"
+
+// registryRequest sends a request to the registry and returns the
+// response, whose body is read in content
+func registryRequest(t *testing.T, method, url, contentType string, body []byte) (*http.Response, []byte) {
+	req, err := http.NewRequest(method, url, bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	content, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return resp, content
+}
+
+func TestRegistry(t *testing.T) {
+	assert := assert.New(t)
+
+	r, err := StartRegistry()
+	assert.NoError(err)
+	defer r.Close()
+
+	base := "http://" + r.Host()
+	assert.Equal(r.Host()+"/cc/busybox", r.Repository("cc/busybox"))
+
+	resp, _ := registryRequest(t, "GET", base+"/v2/", "", nil)
+	assert.Equal(http.StatusOK, resp.StatusCode)
+	assert.Equal("registry/2.0", resp.Header.Get("Docker-Distribution-API-Version"))
+
+	layer := []byte("layer content")
+	digest := registryDigest(layer)
+
+	resp, _ = registryRequest(t, "HEAD", base+"/v2/cc/busybox/blobs/"+digest, "", nil)
+	assert.Equal(http.StatusNotFound, resp.StatusCode)
+
+	// chunked upload
+	resp, _ = registryRequest(t, "POST", base+"/v2/cc/busybox/blobs/uploads/", "", nil)
+	assert.Equal(http.StatusAccepted, resp.StatusCode)
+	assert.Equal("0-0", resp.Header.Get("Range"))
+	location := resp.Header.Get("Location")
+
+	resp, _ = registryRequest(t, "PATCH", base+location, "", layer[:5])
+	assert.Equal(http.StatusAccepted, resp.StatusCode)
+	assert.Equal("0-4", resp.Header.Get("Range"))
+
+	resp, _ = registryRequest(t, "PUT", base+location+"?digest=sha256:0000", "", layer[5:])
+	assert.Equal(http.StatusBadRequest, resp.StatusCode)
+
+	resp, _ = registryRequest(t, "POST", base+"/v2/cc/busybox/blobs/uploads/", "", nil)
+	location = resp.Header.Get("Location")
+	registryRequest(t, "PATCH", base+location, "", layer[:5])
+	resp, _ = registryRequest(t, "PUT", base+location+"?digest="+digest, "", layer[5:])
+	assert.Equal(http.StatusCreated, resp.StatusCode)
+	assert.Equal(digest, resp.Header.Get("Docker-Content-Digest"))
+
+	resp, content := registryRequest(t, "GET", base+"/v2/cc/busybox/blobs/"+digest, "", nil)
+	assert.Equal(http.StatusOK, resp.StatusCode)
+	assert.Equal(layer, content)
+
+	// cross repository mount
+	resp, _ = registryRequest(t, "POST", base+"/v2/other/blobs/uploads/?mount="+digest+"&from=cc/busybox", "", nil)
+	assert.Equal(http.StatusCreated, resp.StatusCode)
+
+	manifest := []byte(`{"schemaVersion":2,"layers":[{"digest":"` + digest + `"}]}`)
+	resp, _ = registryRequest(t, "PUT", base+"/v2/cc/busybox/manifests/latest", testManifestMediaType, manifest)
+	assert.Equal(http.StatusCreated, resp.StatusCode)
+	manifestDigest := resp.Header.Get("Docker-Content-Digest")
+	assert.Equal(registryDigest(manifest), manifestDigest)
+
+	for _, ref := range []string{"latest", manifestDigest} {
+		resp, content = registryRequest(t, "GET", base+"/v2/cc/busybox/manifests/"+ref, "", nil)
+		assert.Equal(http.StatusOK, resp.StatusCode, ref)
+		assert.Equal(testManifestMediaType, resp.Header.Get("Content-Type"))
+		assert.Equal(manifest, content)
+	}
+
+	resp, _ = registryRequest(t, "GET", base+"/v2/cc/busybox/manifests/missing", "", nil)
+	assert.Equal(http.StatusNotFound, resp.StatusCode)
+
+	var tags struct {
+		Name string
+		Tags []string
+	}
+	_, content = registryRequest(t, "GET", base+"/v2/cc/busybox/tags/list", "", nil)
+	assert.NoError(json.Unmarshal(content, &tags))
+	assert.Equal("cc/busybox", tags.Name)
+	assert.Equal([]string{"latest"}, tags.Tags)
+
+	assert.Equal([]string{"cc/busybox"}, r.Repositories())
+
+	r.SetSearchInfo("cc/busybox", RegistrySearchInfo{Description: "busybox", Stars: 3, Official: true})
+
+	var search struct {
+		NumResults int `json:"num_results"`
+		Results    []struct {
+			Name       string `json:"name"`
+			StarCount  int    `json:"star_count"`
+			IsOfficial bool   `json:"is_official"`
+		}
+	}
+	_, content = registryRequest(t, "GET", base+"/v1/search?q=busy&n=25", "", nil)
+	assert.NoError(json.Unmarshal(content, &search))
+	assert.Equal(1, search.NumResults)
+	assert.Equal("cc/busybox", search.Results[0].Name)
+	assert.Equal(3, search.Results[0].StarCount)
+	assert.True(search.Results[0].IsOfficial)
+
+	_, content = registryRequest(t, "GET", base+"/v1/search?q=alpine", "", nil)
+	assert.NoError(json.Unmarshal(content, &search))
+	assert.Equal(0, search.NumResults)
+}
+
+func TestRegistryBlobFiles(t *testing.T) {
+	assert := assert.New(t)
+
+	r, err := StartRegistry()
+	assert.NoError(err)
+
+	nodeDir, err := NodeDir()
+	assert.NoError(err)
+	assert.Equal(nodeDir, filepath.Dir(r.dir))
+
+	base := "http://" + r.Host()
+	layer := []byte("monolithic layer")
+	digest := registryDigest(layer)
+
+	// monolithic upload
+	resp, _ := registryRequest(t, "POST", base+"/v2/cc/busybox/blobs/uploads/?digest="+digest, "", layer)
+	assert.Equal(http.StatusCreated, resp.StatusCode)
+
+	resp, _ = registryRequest(t, "POST", base+"/v2/cc/busybox/blobs/uploads/?digest=sha256:0000", "", layer)
+	assert.Equal(http.StatusBadRequest, resp.StatusCode)
+
+	resp, content := registryRequest(t, "GET", base+"/v2/cc/busybox/blobs/"+digest, "", nil)
+	assert.Equal(http.StatusOK, resp.StatusCode)
+	assert.Equal(layer, content)
+
+	// the blob is kept in a file, the failed upload is removed
+	names, err := readDirNames(r.dir)
+	assert.NoError(err)
+	assert.Equal([]string{strings.TrimPrefix(digest, "sha256:")}, names)
+
+	assert.NoError(r.Close())
+	_, err = os.Stat(r.dir)
+	assert.True(os.IsNotExist(err))
+}
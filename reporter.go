@@ -0,0 +1,322 @@
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tests
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/config"
+	"github.com/onsi/ginkgo/types"
+)
+
+// ReportDir is the directory where the suites write their
+// JUnit and JSON reports, no report is written if it is empty
+var ReportDir string
+
+func init() {
+	flag.StringVar(&ReportDir, suiteFlag("report-dir"), "", "Directory where the suites write their JUnit and JSON reports")
+}
+
+// specStates are the names of the states of the specs in the reports
+var specStates = map[types.SpecState]string{
+	types.SpecStatePending:  "pending",
+	types.SpecStateSkipped:  "skipped",
+	types.SpecStatePassed:   "passed",
+	types.SpecStateFailed:   "failed",
+	types.SpecStatePanicked: "panicked",
+	types.SpecStateTimedOut: "timedout",
+}
+
+// SuiteReport is the JSON report of a suite
+type SuiteReport struct {
+	// Suite is the description of the suite, e.g. Functional Suite
+	Suite string `json:"suite"`
+
+	// Node is the ginkgo node that ran the specs, from 1
+	Node int `json:"node"`
+
+	Succeeded bool          `json:"succeeded"`
+	Start     time.Time     `json:"start"`
+	Duration  time.Duration `json:"duration"`
+
+	Tests    int `json:"tests"`
+	Failures int `json:"failures"`
+	Skipped  int `json:"skipped"`
+
+	// Specs are the specs in the order they ran, BeforeSuite
+	// and AfterSuite are reported as specs
+	Specs []SpecReport `json:"specs"`
+}
+
+// SpecReport is the JSON report of a spec
+type SpecReport struct {
+	Name     string        `json:"name"`
+	Location string        `json:"location"`
+	State    string        `json:"state"`
+	Duration time.Duration `json:"duration"`
+
+	// Failure is the failure of the failed specs
+	Failure *SpecFailure `json:"failure,omitempty"`
+
+	// Output is the output of the failed specs written to GinkgoWriter
+	Output string `json:"output,omitempty"`
+
+	// Commands is the transcript of the commands run by the spec
+	Commands []CommandRecord `json:"commands,omitempty"`
+}
+
+// SpecFailure describes the failure of a spec
+type SpecFailure struct {
+	Message  string `json:"message"`
+	Location string `json:"location"`
+	Panic    string `json:"panic,omitempty"`
+}
+
+// SpecReporter is a ginkgo reporter writing the results of the
+// specs and the transcript of their commands as JUnit XML and JSON
+type SpecReporter struct {
+	dir    string
+	report SuiteReport
+}
+
+// NewSpecReporter returns a reporter writing its reports in dir
+func NewSpecReporter(dir string) *SpecReporter {
+	return &SpecReporter{
+		dir: dir,
+	}
+}
+
+// SuiteReporters returns the reporters of the suites, suites run with
+//
+//	RunSpecsWithDefaultAndCustomReporters(t, "Functional Suite", SuiteReporters())
+func SuiteReporters() []ginkgo.Reporter {
+	if ReportDir == "" {
+		return nil
+	}
+
+	return []ginkgo.Reporter{NewSpecReporter(ReportDir)}
+}
+
+// SpecSuiteWillBegin starts recording the commands
+func (r *SpecReporter) SpecSuiteWillBegin(conf config.GinkgoConfigType, summary *types.SuiteSummary) {
+	r.report = SuiteReport{
+		Suite: summary.SuiteDescription,
+		Node:  conf.ParallelNode,
+		Start: time.Now(),
+		Specs: []SpecReport{},
+	}
+
+	enableTranscript(true)
+}
+
+// BeforeSuiteDidRun reports the BeforeSuite
+func (r *SpecReporter) BeforeSuiteDidRun(summary *types.SetupSummary) {
+	r.addSetup("BeforeSuite", summary)
+}
+
+// SpecWillRun drops the commands run between specs
+func (r *SpecReporter) SpecWillRun(summary *types.SpecSummary) {
+	takeTranscript()
+}
+
+// SpecDidComplete reports the spec
+func (r *SpecReporter) SpecDidComplete(summary *types.SpecSummary) {
+	spec := SpecReport{
+		Name:     specName(summary.ComponentTexts),
+		State:    specStates[summary.State],
+		Duration: summary.RunTime,
+		Commands: takeTranscript(),
+	}
+
+	if n := len(summary.ComponentCodeLocations); n > 0 {
+		spec.Location = summary.ComponentCodeLocations[n-1].String()
+	}
+
+	if summary.HasFailureState() {
+		spec.Failure = newSpecFailure(summary.Failure)
+		spec.Output = summary.CapturedOutput
+	}
+
+	r.report.Specs = append(r.report.Specs, spec)
+}
+
+// AfterSuiteDidRun reports the AfterSuite
+func (r *SpecReporter) AfterSuiteDidRun(summary *types.SetupSummary) {
+	r.addSetup("AfterSuite", summary)
+}
+
+// SpecSuiteDidEnd stops recording the commands and writes the reports
+func (r *SpecReporter) SpecSuiteDidEnd(summary *types.SuiteSummary) {
+	enableTranscript(false)
+
+	r.report.Succeeded = summary.SuiteSucceeded
+	r.report.Duration = summary.RunTime
+
+	for _, spec := range r.report.Specs {
+		switch spec.State {
+		case "pending", "skipped":
+			r.report.Skipped++
+		case "failed", "panicked", "timedout":
+			r.report.Failures++
+		}
+	}
+	r.report.Tests = len(r.report.Specs)
+
+	// reporters cannot fail the suite, ginkgo's reporters print their errors
+	if err := r.write(); err != nil {
+		fmt.Printf("Failed to write the reports of %s: %v\n", r.report.Suite, err)
+	}
+}
+
+func (r *SpecReporter) addSetup(name string, summary *types.SetupSummary) {
+	setup := SpecReport{
+		Name:     name,
+		Location: summary.CodeLocation.String(),
+		State:    specStates[summary.State],
+		Duration: summary.RunTime,
+		Commands: takeTranscript(),
+	}
+
+	if summary.State.IsFailure() {
+		setup.Failure = newSpecFailure(summary.Failure)
+		setup.Output = summary.CapturedOutput
+	}
+
+	r.report.Specs = append(r.report.Specs, setup)
+}
+
+func newSpecFailure(failure types.SpecFailure) *SpecFailure {
+	return &SpecFailure{
+		Message:  failure.Message,
+		Location: failure.Location.String(),
+		Panic:    failure.ForwardedPanic,
+	}
+}
+
+// specName returns the name of the spec, the first
+// component text is the description of the suite
+func specName(texts []string) string {
+	if len(texts) > 1 {
+		texts = texts[1:]
+	}
+
+	return strings.Join(texts, " ")
+}
+
+// reportName returns the name of the report of the node with
+// extension ext, e.g. functional-suite-1.json
+func (r *SpecReporter) reportName(ext string) string {
+	name := strings.ToLower(strings.Trim(artifactsNameRegexp.ReplaceAllString(r.report.Suite, "-"), "-"))
+
+	return fmt.Sprintf("%s-%d%s", name, r.report.Node, ext)
+}
+
+func (r *SpecReporter) write() error {
+	content, err := json.MarshalIndent(r.report, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := writeArtifact(r.dir, r.reportName(".json"), content); err != nil {
+		return err
+	}
+
+	content, err = xml.MarshalIndent(r.junitReport(), "", "  ")
+	if err != nil {
+		return err
+	}
+
+	content = append([]byte(xml.Header), content...)
+
+	return writeArtifact(r.dir, r.reportName(".xml"), content)
+}
+
+type junitTestSuite struct {
+	XMLName   xml.Name        `xml:"testsuite"`
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Skipped   int             `xml:"skipped,attr"`
+	Time      float64         `xml:"time,attr"`
+	Timestamp string          `xml:"timestamp,attr"`
+	TestCases []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      float64       `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Skipped   *struct{}     `xml:"skipped,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitFailure struct {
+	Type    string `xml:"type,attr"`
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// junitReport returns the JUnit report of the suite, the transcript
+// of the commands of each spec is its system-out
+func (r *SpecReporter) junitReport() junitTestSuite {
+	suite := junitTestSuite{
+		Name:      r.report.Suite,
+		Tests:     r.report.Tests,
+		Failures:  r.report.Failures,
+		Skipped:   r.report.Skipped,
+		Time:      r.report.Duration.Seconds(),
+		Timestamp: r.report.Start.Format("2006-01-02T15:04:05"),
+		TestCases: []junitTestCase{},
+	}
+
+	for _, spec := range r.report.Specs {
+		tc := junitTestCase{
+			Name:      spec.Name,
+			ClassName: r.report.Suite,
+			Time:      spec.Duration.Seconds(),
+		}
+
+		switch {
+		case spec.Failure != nil:
+			tc.Failure = &junitFailure{
+				Type:    spec.State,
+				Message: spec.Failure.Message,
+				Text:    fmt.Sprintf("%s\n%s\n%s", spec.Failure.Location, spec.Failure.Message, spec.Failure.Panic),
+			}
+		case spec.State == "pending" || spec.State == "skipped":
+			tc.Skipped = &struct{}{}
+		}
+
+		var out []string
+		for _, c := range spec.Commands {
+			out = append(out, c.String())
+		}
+		if spec.Output != "" {
+			out = append(out, spec.Output)
+		}
+		tc.SystemOut = strings.Join(out, "\n")
+
+		suite.TestCases = append(suite.TestCases, tc)
+	}
+
+	return suite
+}
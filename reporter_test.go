@@ -0,0 +1,174 @@
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tests
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/onsi/ginkgo/config"
+	"github.com/onsi/ginkgo/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCommandTranscript(t *testing.T) {
+	assert := assert.New(t)
+
+	// commands are not recorded without a reporter
+	NewCommand("true").Run()
+	assert.Empty(takeTranscript())
+
+	enableTranscript(true)
+	defer enableTranscript(false)
+
+	// the variables set after the reporter started are recorded
+	assert.NoError(os.Setenv("CC_TESTS_TRANSCRIPT", "1"))
+	defer os.Unsetenv("CC_TESTS_TRANSCRIPT")
+
+	cmd := NewCommand("sh", "-c", "echo $FOO; head -c 5000 /dev/zero >&2; exit 3")
+	cmd.Env = []string{"FOO=bar"}
+	cmd.Run()
+
+	NewCommand("/does/not/exist").Run()
+
+	records := takeTranscript()
+	assert.Len(records, 2)
+	assert.Empty(takeTranscript())
+
+	r := records[0]
+	assert.Equal([]string{"sh", "-c", "echo $FOO; head -c 5000 /dev/zero >&2; exit 3"}, r.Args)
+	assert.Len(r.Env, 2)
+	assert.Contains(r.Env, "CC_TESTS_TRANSCRIPT=1")
+	assert.Contains(r.Env, "FOO=bar")
+	assert.Equal(3, r.ExitCode)
+	assert.Equal("bar\n", r.Stdout)
+	assert.False(r.StdoutTruncated)
+	assert.Len(r.Stderr, transcriptOutputLimit)
+	assert.True(r.StderrTruncated)
+	assert.False(r.Start.IsZero())
+	assert.Contains(r.String(), "Stderr (truncated):")
+
+	assert.Equal(-1, records[1].ExitCode)
+	assert.NotEmpty(records[1].Error)
+}
+
+func TestTruncateOutput(t *testing.T) {
+	assert := assert.New(t)
+
+	output, truncated := truncateOutput("short")
+	assert.Equal("short", output)
+	assert.False(truncated)
+
+	// the 3 bytes of € straddle the limit
+	output, truncated = truncateOutput(strings.Repeat("a", transcriptOutputLimit-1) + "€")
+	assert.True(truncated)
+	assert.Equal(strings.Repeat("a", transcriptOutputLimit-1), output)
+	assert.True(utf8.ValidString(output))
+}
+
+func TestSpecReporter(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir(testDir, "reports")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	location := types.CodeLocation{FileName: "create_test.go", LineNumber: 42}
+
+	r := NewSpecReporter(dir)
+	r.SpecSuiteWillBegin(config.GinkgoConfigType{ParallelNode: 2},
+		&types.SuiteSummary{SuiteDescription: "Functional Suite"})
+
+	passed := &types.SpecSummary{
+		ComponentTexts:         []string{"[Top Level]", "create", "with a valid bundle"},
+		ComponentCodeLocations: []types.CodeLocation{{}, {}, location},
+		State:                  types.SpecStatePassed,
+		RunTime:                time.Second,
+	}
+	r.SpecWillRun(passed)
+	NewCommand("echo", "created").Run()
+	r.SpecDidComplete(passed)
+
+	failed := &types.SpecSummary{
+		ComponentTexts: []string{"[Top Level]", "start", "twice"},
+		State:          types.SpecStateFailed,
+		Failure: types.SpecFailure{
+			Message:  "Expected 0 to equal 1",
+			Location: location,
+		},
+		CapturedOutput: "debug output",
+	}
+	r.SpecWillRun(failed)
+	r.SpecDidComplete(failed)
+
+	skipped := &types.SpecSummary{
+		ComponentTexts: []string{"[Top Level]", "kill"},
+		State:          types.SpecStateSkipped,
+	}
+	r.SpecWillRun(skipped)
+	r.SpecDidComplete(skipped)
+
+	r.SpecSuiteDidEnd(&types.SuiteSummary{SuiteDescription: "Functional Suite", RunTime: 2 * time.Second})
+
+	// the commands run after the suite are not recorded
+	NewCommand("true").Run()
+	assert.Empty(takeTranscript())
+
+	content, err := ioutil.ReadFile(filepath.Join(dir, "functional-suite-2.json"))
+	assert.NoError(err)
+
+	var report SuiteReport
+	assert.NoError(json.Unmarshal(content, &report))
+	assert.Equal("Functional Suite", report.Suite)
+	assert.Equal(2, report.Node)
+	assert.Equal(3, report.Tests)
+	assert.Equal(1, report.Failures)
+	assert.Equal(1, report.Skipped)
+	assert.Len(report.Specs, 3)
+
+	spec := report.Specs[0]
+	assert.Equal("create with a valid bundle", spec.Name)
+	assert.Equal("create_test.go:42", spec.Location)
+	assert.Equal("passed", spec.State)
+	assert.Equal(time.Second, spec.Duration)
+	assert.Nil(spec.Failure)
+	assert.Len(spec.Commands, 1)
+	assert.Equal("created\n", spec.Commands[0].Stdout)
+
+	spec = report.Specs[1]
+	assert.Equal("failed", spec.State)
+	assert.Equal("Expected 0 to equal 1", spec.Failure.Message)
+	assert.Equal("debug output", spec.Output)
+	assert.Empty(spec.Commands)
+
+	content, err = ioutil.ReadFile(filepath.Join(dir, "functional-suite-2.xml"))
+	assert.NoError(err)
+
+	var suite junitTestSuite
+	assert.NoError(xml.Unmarshal(content, &suite))
+	assert.Equal(3, suite.Tests)
+	assert.Equal(1, suite.Failures)
+	assert.Len(suite.TestCases, 3)
+	assert.True(strings.HasPrefix(suite.TestCases[0].SystemOut, "$ echo created\n"))
+	assert.Equal("failed", suite.TestCases[1].Failure.Type)
+	assert.NotNil(suite.TestCases[2].Skipped)
+}
@@ -0,0 +1,425 @@
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tests
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// whiteoutPrefix marks a file removed from a lower layer
+	whiteoutPrefix = ".wh."
+
+	// whiteoutOpaque marks a directory whose lower layer content is hidden
+	whiteoutOpaque = whiteoutPrefix + whiteoutPrefix + ".opq"
+
+	// maxSymlinks is the number of symbolic links followed resolving
+	// a path before giving up, the limit of the kernel
+	maxSymlinks = 40
+)
+
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// NewBundleFromTar creates a new bundle whose rootfs is
+// extracted from a tarball, the tarball can be gzip compressed
+func NewBundleFromTar(workload []string, tarPath string) (*Bundle, error) {
+	return newBundle(workload, func(b *Bundle) error {
+		rootfsDir, err := mkRootfsDir(b.Path)
+		if err != nil {
+			return err
+		}
+
+		return unpackLayerFile(rootfsDir, tarPath)
+	})
+}
+
+// mkRootfsDir creates the rootfs directory in the specific bundlePath
+func mkRootfsDir(bundlePath string) (string, error) {
+	if bundlePath == "" {
+		return "", fmt.Errorf("bundle path should not be empty")
+	}
+
+	rootfsDir := filepath.Join(bundlePath, "rootfs")
+	if err := os.MkdirAll(rootfsDir, 0755); err != nil {
+		return "", err
+	}
+
+	return rootfsDir, nil
+}
+
+// unpackLayerFile extracts the tarball in path into dir
+func unpackLayerFile(dir, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return unpackLayer(dir, f)
+}
+
+// unpackLayer extracts a layer tar stream, optionally gzip compressed,
+// into dir applying the whiteouts found in the layer
+func unpackLayer(dir string, r io.Reader) error {
+	br := bufio.NewReader(r)
+
+	magic, err := br.Peek(len(gzipMagic))
+	if err == nil && bytes.Equal(magic, gzipMagic) {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+
+		return unpackTar(dir, gz)
+	}
+
+	return unpackTar(dir, br)
+}
+
+func unpackTar(dir string, r io.Reader) error {
+	// paths created by this layer, opaque whiteouts
+	// must only hide the content of lower layers
+	unpacked := make(map[string]bool)
+
+	// directory times are restored at the end, since
+	// creating entries inside them changes their mtime
+	var dirs []*tar.Header
+
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		path, err := layerPath(dir, hdr.Name)
+		if err != nil {
+			return err
+		}
+
+		base := filepath.Base(path)
+		parent := filepath.Dir(path)
+
+		if base == whiteoutOpaque {
+			if err := removeLowerEntries(parent, unpacked); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if strings.HasPrefix(base, whiteoutPrefix) {
+			target := filepath.Join(parent, strings.TrimPrefix(base, whiteoutPrefix))
+			if err := os.RemoveAll(target); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := os.MkdirAll(parent, 0755); err != nil {
+			return err
+		}
+
+		if err := unpackEntry(dir, path, hdr, tr); err != nil {
+			return fmt.Errorf("failed to unpack %s: %v", hdr.Name, err)
+		}
+
+		unpacked[path] = true
+
+		if hdr.Typeflag == tar.TypeDir {
+			dirs = append(dirs, hdr)
+		}
+	}
+
+	for _, hdr := range dirs {
+		// later entries can have replaced the directory or its parents
+		path, err := layerPath(dir, hdr.Name)
+		if err != nil {
+			return err
+		}
+
+		if fi, err := os.Lstat(path); err != nil || !fi.IsDir() {
+			continue
+		}
+
+		if err := setTimes(path, hdr); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// layerPath returns the path of a layer entry inside dir. The symbolic
+// links of the parents of the entry are resolved as if dir was the root
+// directory, e.g. with etc -> / the entry etc/passwd is dir/passwd, the
+// entries cannot be written out of dir. The entry itself is not resolved.
+func layerPath(dir, name string) (string, error) {
+	name = filepath.Clean("/" + name)
+	if name == "/" {
+		return dir, nil
+	}
+
+	parent, err := resolveInRoot(dir, filepath.Dir(name))
+	if err != nil {
+		return "", fmt.Errorf("invalid layer entry %s: %v", name, err)
+	}
+
+	return filepath.Join(parent, filepath.Base(name)), nil
+}
+
+// resolveInRoot returns the path of path inside root once its symbolic
+// links are resolved as if root was the root directory
+func resolveInRoot(root, path string) (string, error) {
+	// current is the path resolved so far, relative to root
+	current := "/"
+	remaining := path
+	links := 0
+
+	for remaining != "" {
+		part := remaining
+		remaining = ""
+		if i := strings.IndexByte(part, '/'); i >= 0 {
+			part, remaining = part[:i], part[i+1:]
+		}
+
+		switch part {
+		case "", ".":
+			continue
+		case "..":
+			// the parent of the root is the root
+			current = filepath.Dir(current)
+			continue
+		}
+
+		next := filepath.Join(current, part)
+
+		fi, err := os.Lstat(filepath.Join(root, next))
+		if os.IsNotExist(err) || (err == nil && fi.Mode()&os.ModeSymlink == 0) {
+			current = next
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+
+		links++
+		if links > maxSymlinks {
+			return "", fmt.Errorf("too many symbolic links in %s", path)
+		}
+
+		target, err := os.Readlink(filepath.Join(root, next))
+		if err != nil {
+			return "", err
+		}
+
+		if filepath.IsAbs(target) {
+			current = "/"
+		}
+
+		remaining = target + "/" + remaining
+	}
+
+	return filepath.Join(root, current), nil
+}
+
+// removeLowerEntries removes the content of dir that was not created
+// by the layer being unpacked
+func removeLowerEntries(dir string, unpacked map[string]bool) error {
+	entries, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+		path := filepath.Join(dir, e.Name())
+		if unpacked[path] {
+			continue
+		}
+
+		if err := os.RemoveAll(path); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func unpackEntry(dir, path string, hdr *tar.Header, r io.Reader) error {
+	info := hdr.FileInfo()
+
+	// an entry replaces whatever a lower layer had in the same
+	// path, unless both are directories
+	if fi, err := os.Lstat(path); err == nil {
+		if !(fi.IsDir() && hdr.Typeflag == tar.TypeDir) {
+			if err := os.RemoveAll(path); err != nil {
+				return err
+			}
+		}
+	}
+
+	switch hdr.Typeflag {
+	case tar.TypeDir:
+		if err := os.Mkdir(path, 0755); err != nil && !os.IsExist(err) {
+			return err
+		}
+
+	case tar.TypeReg, tar.TypeRegA:
+		// O_EXCL does not follow a symbolic link created meanwhile
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+		if err != nil {
+			return err
+		}
+
+		_, err = io.Copy(f, r)
+		f.Close()
+		if err != nil {
+			return err
+		}
+
+	case tar.TypeSymlink:
+		return unpackSymlink(path, hdr)
+
+	case tar.TypeLink:
+		target, err := layerPath(dir, hdr.Linkname)
+		if err != nil {
+			return err
+		}
+
+		// hard links share the attributes of their target
+		return os.Link(target, path)
+
+	case tar.TypeChar, tar.TypeBlock, tar.TypeFifo:
+		mode := uint32(info.Mode().Perm())
+		switch hdr.Typeflag {
+		case tar.TypeChar:
+			mode |= unix.S_IFCHR
+		case tar.TypeBlock:
+			mode |= unix.S_IFBLK
+		case tar.TypeFifo:
+			mode |= unix.S_IFIFO
+		}
+
+		dev := mkdev(uint64(hdr.Devmajor), uint64(hdr.Devminor))
+		err := unix.Mknod(path, mode, int(dev))
+		if err != nil {
+			// only root can create device nodes
+			if os.Geteuid() != 0 {
+				LogIfFail("skipping device node %s: %v\n", hdr.Name, err)
+				return nil
+			}
+			return err
+		}
+
+	case tar.TypeXGlobalHeader:
+		return nil
+
+	default:
+		return fmt.Errorf("unsupported type %q", hdr.Typeflag)
+	}
+
+	if err := os.Lchown(path, hdr.Uid, hdr.Gid); err != nil && os.Geteuid() == 0 {
+		return err
+	}
+
+	// chown clears the setuid and setgid bits,
+	// hence the mode has to be set afterwards
+	if err := os.Chmod(path, info.Mode()); err != nil {
+		return err
+	}
+
+	if err := setXattrs(path, hdr); err != nil {
+		return err
+	}
+
+	if hdr.Typeflag != tar.TypeDir {
+		return setTimes(path, hdr)
+	}
+
+	return nil
+}
+
+func unpackSymlink(path string, hdr *tar.Header) error {
+	if err := os.Symlink(hdr.Linkname, path); err != nil {
+		return err
+	}
+
+	if err := os.Lchown(path, hdr.Uid, hdr.Gid); err != nil && os.Geteuid() == 0 {
+		return err
+	}
+
+	return setTimes(path, hdr)
+}
+
+// setTimes sets the access and modification times of path,
+// symbolic links are not followed
+func setTimes(path string, hdr *tar.Header) error {
+	ts := []unix.Timespec{
+		unix.NsecToTimespec(accessTime(hdr).UnixNano()),
+		unix.NsecToTimespec(hdr.ModTime.UnixNano()),
+	}
+
+	return unix.UtimesNanoAt(unix.AT_FDCWD, path, ts, unix.AT_SYMLINK_NOFOLLOW)
+}
+
+// setXattrs sets the extended attributes of hdr
+func setXattrs(path string, hdr *tar.Header) error {
+	for attr, value := range hdr.Xattrs {
+		if err := unix.Setxattr(path, attr, []byte(value), 0); err != nil {
+			// trusted and security namespaces need privileges and
+			// not every filesystem supports extended attributes
+			if err == unix.EPERM || err == unix.ENOTSUP {
+				LogIfFail("skipping xattr %s of %s: %v\n", attr, hdr.Name, err)
+				continue
+			}
+			return err
+		}
+	}
+
+	return nil
+}
+
+func accessTime(hdr *tar.Header) time.Time {
+	if hdr.AccessTime.IsZero() {
+		return hdr.ModTime
+	}
+
+	return hdr.AccessTime
+}
+
+// mkdev returns the device number for major and minor using the glibc
+// makedev layout.
+func mkdev(major, minor uint64) uint64 {
+	return (minor & 0xff) | ((major & 0xfff) << 8) |
+		((minor &^ 0xff) << 12) | ((major &^ 0xfff) << 32)
+}
@@ -0,0 +1,295 @@
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tests
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// rootfsUpperDir is the overlay upper directory inside the bundle
+	rootfsUpperDir = "rootfs-upper"
+
+	// rootfsWorkDir is the overlay work directory inside the bundle
+	rootfsWorkDir = "rootfs-work"
+)
+
+// rootfsCacheEnabled is true if the bundles share the rootfs
+// extracted from an image instead of extracting a new one
+var rootfsCacheEnabled bool
+
+func init() {
+	flag.BoolVar(&rootfsCacheEnabled, suiteFlag("rootfs-cache"), true, "Share the rootfs extracted from an image among the bundles")
+}
+
+// rootfsCache is the cache shared by all the bundles of the suite
+var rootfsCache = &rootfsCacheDir{
+	path: filepath.Join(tmpDir, "cc-tests-rootfs"),
+}
+
+// rootfsCacheDir keeps a read-only rootfs per image digest, bundles get
+// an overlay mount on top of it, or a copy of it if overlay mounts are
+// not possible (e.g. not running as root).
+type rootfsCacheDir struct {
+	sync.Mutex
+
+	// path to the cache directory
+	path string
+}
+
+// populate creates the rootfs of the bundle b from the cache entry key,
+// if the entry does not exist yet extract is called to fill it
+func (c *rootfsCacheDir) populate(b *Bundle, key string, extract func(rootfsDir string) error) error {
+	lower, err := c.get(key, extract)
+	if err != nil {
+		return err
+	}
+
+	rootfsDir, err := mkRootfsDir(b.Path)
+	if err != nil {
+		return err
+	}
+
+	if err := mountOverlayRootfs(b.Path, lower, rootfsDir); err == nil {
+		b.rootfsMounted = true
+		return nil
+	}
+
+	return copyTree(lower, rootfsDir)
+}
+
+// get returns the path of the rootfs cached for key
+func (c *rootfsCacheDir) get(key string, extract func(rootfsDir string) error) (string, error) {
+	if key == "" {
+		return "", fmt.Errorf("rootfs cache key should not be empty")
+	}
+
+	entry := filepath.Join(c.path, strings.Replace(key, ":", "-", -1))
+
+	c.Lock()
+	defer c.Unlock()
+
+	if _, err := os.Stat(entry); err == nil {
+		return entry, nil
+	}
+
+	if err := os.MkdirAll(c.path, 0755); err != nil {
+		return "", err
+	}
+
+	// extract in a temporary directory and rename it,
+	// other test processes could be filling the same entry
+	tmp, err := ioutil.TempDir(c.path, "tmp")
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.Chmod(tmp, 0755); err != nil {
+		os.RemoveAll(tmp)
+		return "", err
+	}
+
+	if err := extract(tmp); err != nil {
+		os.RemoveAll(tmp)
+		return "", err
+	}
+
+	if err := os.Rename(tmp, entry); err != nil {
+		os.RemoveAll(tmp)
+		if _, statErr := os.Stat(entry); statErr != nil {
+			return "", err
+		}
+	}
+
+	return entry, nil
+}
+
+// PurgeRootfsCache removes all the rootfs extracted in the cache
+func PurgeRootfsCache() error {
+	rootfsCache.Lock()
+	defer rootfsCache.Unlock()
+
+	return os.RemoveAll(rootfsCache.path)
+}
+
+// mountOverlayRootfs mounts an overlay on rootfsDir using lower as
+// lower directory, the upper and work directories live in the bundle
+func mountOverlayRootfs(bundlePath, lower, rootfsDir string) error {
+	upper := filepath.Join(bundlePath, rootfsUpperDir)
+	work := filepath.Join(bundlePath, rootfsWorkDir)
+
+	for _, d := range []string{upper, work} {
+		if err := os.MkdirAll(d, 0755); err != nil {
+			return err
+		}
+	}
+
+	data := fmt.Sprintf("lowerdir=%s,upperdir=%s,workdir=%s", lower, upper, work)
+
+	return unix.Mount("overlay", rootfsDir, "overlay", 0, data)
+}
+
+// unmountRootfs unmounts the overlay rootfs of the bundle
+func unmountRootfs(b *Bundle) error {
+	err := unix.Unmount(filepath.Join(b.Path, "rootfs"), unix.MNT_DETACH)
+	if err != nil && err != unix.EINVAL && err != unix.ENOENT {
+		return err
+	}
+
+	b.rootfsMounted = false
+
+	return nil
+}
+
+// copyTree copies the rootfs src in dst. The regular files are copied
+// rather than hard linked, the workloads can modify them in place
+// without modifying the cached rootfs
+func copyTree(src, dst string) error {
+	// directory modes are set at the end, a read-only
+	// directory would not allow creating its entries
+	modes := make(map[string]os.FileMode)
+
+	err := filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+
+		target := filepath.Join(dst, rel)
+
+		switch {
+		case info.IsDir():
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+
+			modes[target] = info.Mode()
+
+		case info.Mode().IsRegular():
+			return copyFile(path, target, info)
+
+		case info.Mode()&os.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+
+			if err := os.Symlink(link, target); err != nil {
+				return err
+			}
+
+		default:
+			// device nodes, fifos and sockets have no content, they are
+			// recreated rather than linked so that chown does not
+			// modify the cache
+			return copySpecial(path, target, info)
+		}
+
+		return copyOwner(target, info)
+	})
+	if err != nil {
+		return err
+	}
+
+	for path, mode := range modes {
+		if err := os.Chmod(path, mode); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// copyFile copies the regular file src with mode and times to dst
+func copyFile(src, dst string, info os.FileInfo) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(out, in)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	// the owner is set first, chown clears the setuid and setgid bits
+	if err := copyOwner(dst, info); err != nil {
+		return err
+	}
+
+	if err := os.Chmod(dst, info.Mode()); err != nil {
+		return err
+	}
+
+	return os.Chtimes(dst, info.ModTime(), info.ModTime())
+}
+
+// copySpecial recreates the device node, fifo or socket src with mode
+// and owner at dst
+func copySpecial(src, dst string, info os.FileInfo) error {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return fmt.Errorf("cannot stat %s", src)
+	}
+
+	err := unix.Mknod(dst, st.Mode, int(st.Rdev))
+	if err != nil {
+		// only root can create device nodes
+		if os.Geteuid() != 0 && info.Mode()&os.ModeDevice != 0 {
+			LogIfFail("skipping device node %s: %v\n", src, err)
+			return nil
+		}
+		return err
+	}
+
+	if err := copyOwner(dst, info); err != nil {
+		return err
+	}
+
+	return os.Chmod(dst, info.Mode())
+}
+
+// copyOwner gives path the owner of info, only root can change it
+func copyOwner(path string, info os.FileInfo) error {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok || os.Geteuid() != 0 {
+		return nil
+	}
+
+	return os.Lchown(path, int(st.Uid), int(st.Gid))
+}
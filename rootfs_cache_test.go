@@ -0,0 +1,74 @@
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tests
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/sys/unix"
+)
+
+func TestCopyTree(t *testing.T) {
+	assert := assert.New(t)
+
+	src, err := ioutil.TempDir(testDir, "cache")
+	assert.NoError(err)
+
+	assert.NoError(os.MkdirAll(filepath.Join(src, "etc"), 0755))
+	assert.NoError(ioutil.WriteFile(filepath.Join(src, "etc/hostname"), []byte("cached\n"), 0644))
+	assert.NoError(os.Symlink("etc/hostname", filepath.Join(src, "hostname")))
+	assert.NoError(unix.Mkfifo(filepath.Join(src, "etc/initctl"), 0600))
+	assert.NoError(os.Chmod(filepath.Join(src, "etc"), 0555))
+	defer os.Chmod(filepath.Join(src, "etc"), 0755)
+
+	dst, err := ioutil.TempDir(testDir, "rootfs")
+	assert.NoError(err)
+
+	assert.NoError(copyTree(src, dst))
+
+	fi, err := os.Stat(filepath.Join(dst, "etc"))
+	assert.NoError(err)
+	assert.Equal(os.FileMode(0555), fi.Mode().Perm())
+
+	link, err := os.Readlink(filepath.Join(dst, "hostname"))
+	assert.NoError(err)
+	assert.Equal("etc/hostname", link)
+
+	// special files are recreated rather than linked
+	srcFifo, err := os.Lstat(filepath.Join(src, "etc/initctl"))
+	assert.NoError(err)
+	dstFifo, err := os.Lstat(filepath.Join(dst, "etc/initctl"))
+	assert.NoError(err)
+	assert.True(dstFifo.Mode()&os.ModeNamedPipe != 0)
+	assert.Equal(os.FileMode(0600), dstFifo.Mode().Perm())
+	assert.False(os.SameFile(srcFifo, dstFifo))
+
+	// modifying a file in place does not modify the cache
+	f, err := os.OpenFile(filepath.Join(dst, "etc/hostname"), os.O_WRONLY|os.O_TRUNC, 0)
+	assert.NoError(err)
+	_, err = f.WriteString("bundle\n")
+	assert.NoError(err)
+	assert.NoError(f.Close())
+
+	data, err := ioutil.ReadFile(filepath.Join(src, "etc/hostname"))
+	assert.NoError(err)
+	assert.Equal("cached\n", string(data))
+
+	assert.NoError(os.Chmod(filepath.Join(dst, "etc"), 0755))
+}
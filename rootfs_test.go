@@ -0,0 +1,127 @@
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tests
+
+import (
+	"archive/tar"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnpackLayerSymlinkedParents(t *testing.T) {
+	assert := assert.New(t)
+
+	outside, err := ioutil.TempDir(testDir, "outside")
+	assert.NoError(err)
+
+	rootfs, err := ioutil.TempDir(testDir, "rootfs")
+	assert.NoError(err)
+
+	var layer bytes.Buffer
+	w := tar.NewWriter(&layer)
+
+	entries := []struct {
+		name     string
+		linkname string
+		content  string
+	}{
+		{name: "abs", linkname: outside},
+		{name: "abs/passwd", content: "abs"},
+		{name: "rel", linkname: "../../../../../.." + outside},
+		{name: "rel/shadow", content: "rel"},
+		{name: "dir/", linkname: ""},
+		{name: "dir/up", linkname: "../.."},
+		{name: "dir/up/group", content: "up"},
+	}
+
+	for _, e := range entries {
+		hdr := &tar.Header{
+			Name:     e.name,
+			Mode:     0644,
+			Typeflag: tar.TypeReg,
+			Size:     int64(len(e.content)),
+		}
+
+		switch {
+		case e.linkname != "":
+			hdr.Typeflag = tar.TypeSymlink
+			hdr.Linkname = e.linkname
+		case e.content == "":
+			hdr.Mode = 0755
+			hdr.Typeflag = tar.TypeDir
+		}
+
+		assert.NoError(w.WriteHeader(hdr))
+		_, err := w.Write([]byte(e.content))
+		assert.NoError(err)
+	}
+	assert.NoError(w.Close())
+
+	assert.NoError(unpackLayer(rootfs, &layer))
+
+	// nothing is written out of the rootfs
+	files, err := ioutil.ReadDir(outside)
+	assert.NoError(err)
+	assert.Empty(files)
+
+	// the links are resolved inside the rootfs
+	for path, content := range map[string]string{
+		filepath.Join(outside, "passwd"): "abs",
+		filepath.Join(outside, "shadow"): "rel",
+		"group":                          "up",
+	} {
+		data, err := ioutil.ReadFile(filepath.Join(rootfs, path))
+		assert.NoError(err, path)
+		assert.Equal(content, string(data), path)
+	}
+
+	_, err = os.Lstat(filepath.Join(testDir, "group"))
+	assert.True(os.IsNotExist(err))
+}
+
+func TestMkdev(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal(uint64(0x0103), mkdev(1, 3))
+	assert.Equal(uint64(0x0880), mkdev(8, 128))
+	assert.Equal(uint64(0x100056723489), mkdev(0x1234, 0x56789))
+}
+
+func TestOpenBlobDigest(t *testing.T) {
+	assert := assert.New(t)
+
+	layout, err := ioutil.TempDir(testDir, "layout")
+	assert.NoError(err)
+
+	digests := []string{
+		"sha512:" + strings.Repeat("a", 64),
+		"sha256:../../../etc/passwd",
+		"sha256:" + strings.Repeat("A", 64),
+		"sha256:" + strings.Repeat("a", 63),
+		"sha256",
+	}
+
+	for _, d := range digests {
+		_, err := openBlob(layout, ociDescriptor{Digest: d})
+		assert.Error(err, d)
+		assert.Contains(err.Error(), "unsupported digest", d)
+	}
+}
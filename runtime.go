@@ -0,0 +1,215 @@
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tests
+
+import (
+	"flag"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/onsi/ginkgo"
+)
+
+// runtimeType is the name of the runtime in the runtimes registry,
+// if empty the base name of the Runtime path is used
+var runtimeType string
+
+// runtimeGlobalOptions are given to the runtime before the command,
+// e.g. --root
+var runtimeGlobalOptions []string
+
+func init() {
+	flag.StringVar(&runtimeType, suiteFlag("runtime-type"), "", "Name of the runtime capabilities (cc-runtime, runc, oci), by default the base name of the runtime path")
+	flag.Var(listValue{&runtimeGlobalOptions}, suiteFlag("runtime-option"), "Comma separated global options given to every runtime command")
+}
+
+// RuntimeCapabilities describes the features of an OCI runtime
+type RuntimeCapabilities struct {
+	// VMBased is true if the containers run inside a virtual machine
+	VMBased bool
+
+	// Pause is true if the runtime implements pause and resume
+	Pause bool
+
+	// ConsoleSocket is true if the runtime can send the console
+	// of the container through the --console-socket unix socket
+	ConsoleSocket bool
+
+	// Hypervisor is the name of the hypervisor of a VM based runtime
+	Hypervisor string
+}
+
+// OCIRuntime is the OCI runtime the tests run against
+type OCIRuntime interface {
+	// Name of the runtime, e.g. cc-runtime
+	Name() string
+
+	// Path of the runtime binary
+	Path() string
+
+	// Capabilities of the runtime
+	Capabilities() RuntimeCapabilities
+}
+
+// ociRuntime is an OCI runtime described by its capabilities
+type ociRuntime struct {
+	name         string
+	path         string
+	capabilities RuntimeCapabilities
+}
+
+func (r *ociRuntime) Name() string {
+	return r.name
+}
+
+func (r *ociRuntime) Path() string {
+	return r.path
+}
+
+func (r *ociRuntime) Capabilities() RuntimeCapabilities {
+	return r.capabilities
+}
+
+// genericRuntime is the name of the capabilities used
+// for the runtimes that are not registered
+const genericRuntime = "oci"
+
+var runtimes = struct {
+	sync.Mutex
+	capabilities map[string]RuntimeCapabilities
+}{
+	capabilities: map[string]RuntimeCapabilities{
+		"cc-runtime": {
+			VMBased:       true,
+			Pause:         true,
+			ConsoleSocket: true,
+			Hypervisor:    "qemu",
+		},
+		"runc": {
+			Pause:         true,
+			ConsoleSocket: true,
+		},
+		// cmd/fakeruntime runs the container process in the host
+		"fakeruntime": {
+			Pause: true,
+		},
+		// only the operations required by the OCI runtime command line
+		genericRuntime: {
+			ConsoleSocket: true,
+		},
+	},
+}
+
+// RegisterRuntime declares the capabilities of the runtime name,
+// existing capabilities of the same runtime are replaced
+func RegisterRuntime(name string, capabilities RuntimeCapabilities) {
+	runtimes.Lock()
+	defer runtimes.Unlock()
+
+	runtimes.capabilities[name] = capabilities
+}
+
+// CurrentRuntime returns the runtime specified by the -runtime option,
+// its capabilities are looked up by the -runtime-type option or by the
+// base name of its path, unknown runtimes get generic OCI capabilities
+func CurrentRuntime() OCIRuntime {
+	name := runtimeType
+	if name == "" {
+		name = filepath.Base(Runtime)
+	}
+
+	runtimes.Lock()
+	defer runtimes.Unlock()
+
+	capabilities, ok := runtimes.capabilities[name]
+	if !ok {
+		capabilities = runtimes.capabilities[genericRuntime]
+	}
+
+	return &ociRuntime{
+		name:         name,
+		path:         Runtime,
+		capabilities: capabilities,
+	}
+}
+
+// NewRuntimeCommand returns a new Command that runs the runtime
+// with the global options of the suite followed by args
+func NewRuntimeCommand(args ...string) *Command {
+	a := append([]string{}, runtimeGlobalOptions...)
+	return NewCommand(Runtime, append(a, args...)...)
+}
+
+// RuntimeRequirement is a capability needed by a spec
+type RuntimeRequirement struct {
+	// Description of the requirement shown when the spec is skipped
+	Description string
+
+	// Met returns true if the capabilities fulfil the requirement
+	Met func(c RuntimeCapabilities) bool
+}
+
+var (
+	// RequiresVM is met by VM based runtimes
+	RequiresVM = RuntimeRequirement{
+		Description: "VM based runtime",
+		Met:         func(c RuntimeCapabilities) bool { return c.VMBased },
+	}
+
+	// RequiresPause is met by runtimes implementing pause and resume
+	RequiresPause = RuntimeRequirement{
+		Description: "pause support",
+		Met:         func(c RuntimeCapabilities) bool { return c.Pause },
+	}
+
+	// RequiresConsoleSocket is met by runtimes supporting --console-socket
+	RequiresConsoleSocket = RuntimeRequirement{
+		Description: "console socket support",
+		Met:         func(c RuntimeCapabilities) bool { return c.ConsoleSocket },
+	}
+)
+
+// RequiresHypervisor is met by VM based runtimes using the hypervisor name
+func RequiresHypervisor(name string) RuntimeRequirement {
+	return RuntimeRequirement{
+		Description: fmt.Sprintf("%s hypervisor", name),
+		Met:         func(c RuntimeCapabilities) bool { return c.VMBased && c.Hypervisor == name },
+	}
+}
+
+// SkipUnlessRuntime skips the current spec if the runtime does not
+// meet all the requirements, it must be called from a spec or a
+// BeforeEach, e.g.
+//
+//	BeforeEach(func() {
+//		SkipUnlessRuntime(RequiresPause)
+//	})
+func SkipUnlessRuntime(requirements ...RuntimeRequirement) {
+	runtime := CurrentRuntime()
+
+	var missing []string
+	for _, r := range requirements {
+		if !r.Met(runtime.Capabilities()) {
+			missing = append(missing, r.Description)
+		}
+	}
+
+	if len(missing) > 0 {
+		ginkgo.Skip(fmt.Sprintf("runtime %s does not meet the requirements: %s",
+			runtime.Name(), strings.Join(missing, ", ")))
+	}
+}
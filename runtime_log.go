@@ -0,0 +1,298 @@
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tests
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Levels of the runtime log entries, from the most to the least severe
+const (
+	PanicLevel   = "panic"
+	FatalLevel   = "fatal"
+	ErrorLevel   = "error"
+	WarningLevel = "warning"
+	InfoLevel    = "info"
+	DebugLevel   = "debug"
+	TraceLevel   = "trace"
+)
+
+// logLevels gives the severity of the levels, lower is more severe
+var logLevels = map[string]int{
+	PanicLevel:   0,
+	FatalLevel:   1,
+	ErrorLevel:   2,
+	WarningLevel: 3,
+	InfoLevel:    4,
+	DebugLevel:   5,
+	TraceLevel:   6,
+}
+
+// LogEntry is an entry of the log written by the runtime with --log,
+// in the text or JSON format of logrus
+type LogEntry struct {
+	// Line is the line number of the entry in the log
+	Line int
+
+	// Time is the time of the entry, zero if it has no time
+	Time time.Time
+
+	// Level is the level of the entry, e.g. ErrorLevel,
+	// the unknown levels are kept as they are in the log
+	Level string
+
+	// Message is the msg field
+	Message string
+
+	// Source is the component that logged the entry, e.g. runtime
+	Source string
+
+	// Fields are the fields of the entry other than
+	// time, level, msg and source
+	Fields map[string]string
+}
+
+// String returns the entry in the logrus text format
+func (e LogEntry) String() string {
+	s := fmt.Sprintf("level=%s msg=%q", e.Level, e.Message)
+
+	if !e.Time.IsZero() {
+		s = fmt.Sprintf("time=%q %s", e.Time.Format(time.RFC3339Nano), s)
+	}
+
+	if e.Source != "" {
+		s += " source=" + e.Source
+	}
+
+	var keys []string
+	for k := range e.Fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		s += fmt.Sprintf(" %s=%q", k, e.Fields[k])
+	}
+
+	return s
+}
+
+// AtLeast returns true if the entry is at level or more severe,
+// entries with an unknown level are not
+func (e LogEntry) AtLeast(level string) bool {
+	severity, ok := logLevels[e.Level]
+	if !ok {
+		return false
+	}
+
+	return severity <= logLevels[normalizeLogLevel(level)]
+}
+
+// ParseLogFile parses the runtime log in path
+func ParseLogFile(path string) ([]LogEntry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	entries, err := ParseLog(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	return entries, nil
+}
+
+// ParseLog parses a runtime log, each line is an entry in the
+// logrus text format (key=value) or JSON format, empty lines are skipped
+func ParseLog(r io.Reader) ([]LogEntry, error) {
+	var entries []LogEntry
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		entry, err := ParseLogLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", n, err)
+		}
+
+		entry.Line = n
+		entries = append(entries, entry)
+	}
+
+	return entries, scanner.Err()
+}
+
+// ParseLogLine parses an entry in the logrus text or JSON format
+func ParseLogLine(line string) (LogEntry, error) {
+	var fields map[string]string
+	var err error
+
+	if strings.HasPrefix(line, "{") {
+		fields, err = parseJSONLogFields(line)
+	} else {
+		fields, err = parseTextLogFields(line)
+	}
+	if err != nil {
+		return LogEntry{}, err
+	}
+
+	entry := LogEntry{
+		Level:   normalizeLogLevel(fields["level"]),
+		Message: fields["msg"],
+		Source:  fields["source"],
+		Fields:  fields,
+	}
+
+	if t, ok := fields["time"]; ok {
+		entry.Time, err = time.Parse(time.RFC3339Nano, t)
+		if err != nil {
+			return LogEntry{}, fmt.Errorf("invalid time %q: %v", t, err)
+		}
+	}
+
+	for _, k := range []string{"time", "level", "msg", "source"} {
+		delete(fields, k)
+	}
+
+	return entry, nil
+}
+
+// normalizeLogLevel returns the name of the level used by
+// LogEntry, logrus writes both warn and warning, the unknown
+// levels are returned unchanged
+func normalizeLogLevel(level string) string {
+	normalized := strings.ToLower(level)
+	if normalized == "warn" {
+		return WarningLevel
+	}
+
+	if _, ok := logLevels[normalized]; ok {
+		return normalized
+	}
+
+	return level
+}
+
+func parseJSONLogFields(line string) (map[string]string, error) {
+	var values map[string]interface{}
+	if err := json.Unmarshal([]byte(line), &values); err != nil {
+		return nil, err
+	}
+
+	fields := make(map[string]string)
+	for k, v := range values {
+		switch v := v.(type) {
+		case string:
+			fields[k] = v
+		default:
+			// numbers, booleans and objects keep their JSON form
+			b, err := json.Marshal(v)
+			if err != nil {
+				return nil, err
+			}
+			fields[k] = string(b)
+		}
+	}
+
+	return fields, nil
+}
+
+// parseTextLogFields parses space separated key=value pairs,
+// values are bare words or double quoted Go strings
+func parseTextLogFields(line string) (map[string]string, error) {
+	fields := make(map[string]string)
+
+	for line = strings.TrimLeft(line, " "); line != ""; line = strings.TrimLeft(line, " ") {
+		eq := strings.IndexByte(line, '=')
+		if eq <= 0 || strings.ContainsAny(line[:eq], " \"") {
+			return nil, fmt.Errorf("expected key=value at %q", line)
+		}
+
+		key := line[:eq]
+		line = line[eq+1:]
+
+		var value string
+		if strings.HasPrefix(line, "\"") {
+			end := quotedLen(line)
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated value of %s", key)
+			}
+
+			var err error
+			value, err = strconv.Unquote(line[:end])
+			if err != nil {
+				return nil, fmt.Errorf("invalid value of %s: %v", key, err)
+			}
+			line = line[end:]
+		} else {
+			end := strings.IndexByte(line, ' ')
+			if end < 0 {
+				end = len(line)
+			}
+			value = line[:end]
+			line = line[end:]
+		}
+
+		fields[key] = value
+	}
+
+	return fields, nil
+}
+
+// quotedLen returns the length of the double quoted
+// string at the start of s, -1 if it is not terminated
+func quotedLen(s string) int {
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			return i + 1
+		}
+	}
+
+	return -1
+}
+
+// RuntimeLog returns the entries of the log of the container, the
+// container must have a LogFile, a log not written yet has no entries
+func (c *Container) RuntimeLog() ([]LogEntry, error) {
+	if c.LogFile == nil {
+		return nil, fmt.Errorf("container has no log file")
+	}
+
+	entries, err := ParseLogFile(*c.LogFile)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	return entries, err
+}
@@ -0,0 +1,148 @@
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tests
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/onsi/gomega/types"
+)
+
+// The matchers below check the log written by the runtime with --log,
+// the actual value is a *Container, the path of a log or []LogEntry, e.g.
+//
+//	Expect(container).To(HaveLoggedNoErrors())
+//	Expect(container).To(HaveLoggedMessage(WarningLevel, "ignored"))
+
+// HaveLoggedNoErrors succeeds if the log has no entries
+// at error level or more severe
+func HaveLoggedNoErrors() types.GomegaMatcher {
+	return &loggedNoErrorsMatcher{}
+}
+
+// HaveLoggedMessage succeeds if the log has an entry at level
+// whose message matches the regular expression pattern
+func HaveLoggedMessage(level, pattern string) types.GomegaMatcher {
+	return &loggedMessageMatcher{
+		level:   normalizeLogLevel(level),
+		pattern: pattern,
+	}
+}
+
+// logEntries returns the log entries of actual
+func logEntries(actual interface{}) ([]LogEntry, error) {
+	switch a := actual.(type) {
+	case *Container:
+		return a.RuntimeLog()
+	case string:
+		return ParseLogFile(a)
+	case []LogEntry:
+		return a, nil
+	default:
+		return nil, fmt.Errorf("expected a *Container, a log path or []LogEntry, got %T", actual)
+	}
+}
+
+func formatLogEntries(entries []LogEntry) string {
+	if len(entries) == 0 {
+		return "\n\t<no entries>"
+	}
+
+	var s []string
+	for _, e := range entries {
+		s = append(s, fmt.Sprintf("\n\t%d: %s", e.Line, e))
+	}
+
+	return strings.Join(s, "")
+}
+
+type loggedNoErrorsMatcher struct {
+	errors []LogEntry
+}
+
+func (m *loggedNoErrorsMatcher) Match(actual interface{}) (bool, error) {
+	entries, err := logEntries(actual)
+	if err != nil {
+		return false, err
+	}
+
+	m.errors = nil
+	for _, e := range entries {
+		if e.AtLeast(ErrorLevel) {
+			m.errors = append(m.errors, e)
+		}
+	}
+
+	return len(m.errors) == 0, nil
+}
+
+func (m *loggedNoErrorsMatcher) FailureMessage(actual interface{}) string {
+	return "Expected the runtime to log no errors, it logged:" + formatLogEntries(m.errors)
+}
+
+func (m *loggedNoErrorsMatcher) NegatedFailureMessage(actual interface{}) string {
+	return "Expected the runtime to log errors, it did not"
+}
+
+type loggedMessageMatcher struct {
+	level   string
+	pattern string
+
+	// entries are the entries at level
+	entries []LogEntry
+}
+
+func (m *loggedMessageMatcher) Match(actual interface{}) (bool, error) {
+	re, err := regexp.Compile(m.pattern)
+	if err != nil {
+		return false, err
+	}
+
+	if _, ok := logLevels[m.level]; !ok {
+		return false, fmt.Errorf("invalid level %q", m.level)
+	}
+
+	entries, err := logEntries(actual)
+	if err != nil {
+		return false, err
+	}
+
+	m.entries = nil
+	found := false
+	for _, e := range entries {
+		if e.Level != m.level {
+			continue
+		}
+
+		m.entries = append(m.entries, e)
+		if re.MatchString(e.Message) {
+			found = true
+		}
+	}
+
+	return found, nil
+}
+
+func (m *loggedMessageMatcher) FailureMessage(actual interface{}) string {
+	return fmt.Sprintf("Expected the runtime to log a message matching %q at %s level, entries at %s level:%s",
+		m.pattern, m.level, m.level, formatLogEntries(m.entries))
+}
+
+func (m *loggedMessageMatcher) NegatedFailureMessage(actual interface{}) string {
+	return fmt.Sprintf("Expected the runtime not to log a message matching %q at %s level, entries at %s level:%s",
+		m.pattern, m.level, m.level, formatLogEntries(m.entries))
+}
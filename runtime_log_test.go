@@ -0,0 +1,161 @@
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tests
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/onsi/gomega/types"
+	"github.com/stretchr/testify/assert"
+)
+
+const testTextLog = `time="2017-08-01T10:00:00.5Z" level=info msg="starting container" name=cc-runtime pid=42 source=runtime
+time="2017-08-01T10:00:01Z" level=warn msg="hypervisor \"qemu\" is slow" source=virtcontainers subsystem=qemu
+
+time="2017-08-01T10:00:02Z" level=error msg="failed to mount /dev/shm" arg="a b" source=runtime
+`
+
+const testJSONLog = `{"time":"2017-08-01T10:00:00Z","level":"debug","msg":"state","source":"runtime","pid":42}
+{"time":"2017-08-01T10:00:01Z","level":"error","msg":"container not found","source":"runtime","ok":false}
+`
+
+func TestParseTextLog(t *testing.T) {
+	assert := assert.New(t)
+
+	entries, err := ParseLog(strings.NewReader(testTextLog))
+	assert.NoError(err)
+	if !assert.Len(entries, 3) {
+		return
+	}
+
+	assert.Equal(1, entries[0].Line)
+	assert.Equal(InfoLevel, entries[0].Level)
+	assert.Equal("starting container", entries[0].Message)
+	assert.Equal("runtime", entries[0].Source)
+	assert.Equal(map[string]string{"name": "cc-runtime", "pid": "42"}, entries[0].Fields)
+	assert.Equal(500000000, entries[0].Time.Nanosecond())
+
+	assert.Equal(WarningLevel, entries[1].Level)
+	assert.Equal(`hypervisor "qemu" is slow`, entries[1].Message)
+	assert.Equal("qemu", entries[1].Fields["subsystem"])
+
+	assert.Equal(4, entries[2].Line)
+	assert.Equal("a b", entries[2].Fields["arg"])
+	assert.True(entries[2].AtLeast(ErrorLevel))
+	assert.True(entries[2].AtLeast(WarningLevel))
+	assert.False(entries[1].AtLeast(ErrorLevel))
+}
+
+func TestParseJSONLog(t *testing.T) {
+	assert := assert.New(t)
+
+	entries, err := ParseLog(strings.NewReader(testJSONLog))
+	assert.NoError(err)
+	if !assert.Len(entries, 2) {
+		return
+	}
+
+	assert.Equal(DebugLevel, entries[0].Level)
+	assert.Equal("42", entries[0].Fields["pid"])
+	assert.Equal("container not found", entries[1].Message)
+	assert.Equal("false", entries[1].Fields["ok"])
+}
+
+func TestParseLogErrors(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, line := range []string{
+		`level=error msg="unterminated`,
+		`level=info msg`,
+		`time=yesterday level=info msg=x`,
+		`{"level": "info"`,
+	} {
+		_, err := ParseLogLine(line)
+		assert.Error(err, line)
+	}
+}
+
+func TestParseLogUnknownLevel(t *testing.T) {
+	assert := assert.New(t)
+
+	entries, err := ParseLog(strings.NewReader(`level=trace msg="entering create"
+level=Notice msg="container created"
+level=WARN msg=slow
+`))
+	assert.NoError(err)
+	if !assert.Len(entries, 3) {
+		return
+	}
+
+	assert.Equal(TraceLevel, entries[0].Level)
+	assert.False(entries[0].AtLeast(DebugLevel))
+
+	// unknown levels are kept and match no level
+	assert.Equal("Notice", entries[1].Level)
+	assert.Equal("container created", entries[1].Message)
+	assert.False(entries[1].AtLeast(DebugLevel))
+	assert.False(entries[1].AtLeast(TraceLevel))
+
+	assert.Equal(WarningLevel, entries[2].Level)
+}
+
+func TestLogMatchers(t *testing.T) {
+	assert := assert.New(t)
+
+	entries, err := ParseLog(strings.NewReader(testTextLog))
+	assert.NoError(err)
+
+	assertMatch(assert, HaveLoggedNoErrors(), entries, false)
+	assertMatch(assert, HaveLoggedNoErrors(), entries[:2], true)
+	assertMatch(assert, HaveLoggedMessage("warn", "qemu.*slow"), entries, true)
+	assertMatch(assert, HaveLoggedMessage(ErrorLevel, "slow"), entries, false)
+
+	_, err = HaveLoggedNoErrors().Match(42)
+	assert.Error(err)
+}
+
+func assertMatch(assert *assert.Assertions, matcher types.GomegaMatcher, actual interface{}, expected bool) {
+	ok, err := matcher.Match(actual)
+	assert.NoError(err)
+
+	if expected {
+		assert.True(ok, matcher.FailureMessage(actual))
+	} else {
+		assert.False(ok, matcher.NegatedFailureMessage(actual))
+	}
+}
+
+func TestContainerRuntimeLog(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, format := range []string{"text", "json"} {
+		c := newTestContainer(t, "sleep", "30")
+		c.LogFormat = &format
+
+		_, stderr, exitCode := c.Create()
+		assert.Equal(0, exitCode, stderr)
+		assertMatch(assert, HaveLoggedNoErrors(), c, true)
+		assertMatch(assert, HaveLoggedMessage(DebugLevel, "create"), c, true)
+
+		// the container already exists
+		_, _, exitCode = c.Create()
+		assert.NotEqual(0, exitCode)
+		assertMatch(assert, HaveLoggedNoErrors(), c, false)
+		assertMatch(assert, HaveLoggedMessage(ErrorLevel, *c.ID), c, true)
+
+		assert.NoError(c.Teardown())
+	}
+}
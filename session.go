@@ -0,0 +1,340 @@
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tests
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// Session is a command running on a pseudo terminal, specs type in
+// the terminal and expect its output, e.g.
+//
+//	s, err := StartDockerSession("run", "-it", "--name", id, Image, "sh")
+//	Expect(err).NotTo(HaveOccurred())
+//	defer s.Close()
+//
+//	Expect(s.Send("echo $((6 * 7))\n")).To(Succeed())
+//	Expect(s.ExpectString("42")).To(Succeed())
+//
+// The output is matched in order, each Expect* call only looks at the
+// output that follows the previous match. Once the session is closed,
+// the command is added to the transcript of the spec with the output
+// of the terminal as stdout.
+type Session struct {
+	// Timeout is the time limit of Expect* and Wait
+	Timeout time.Duration
+
+	cmd     *exec.Cmd
+	console *Console
+	start   time.Time
+
+	// notify receives a value when there is new output
+	notify chan struct{}
+
+	// readDone is closed when the output ends
+	readDone chan struct{}
+
+	// done is closed when the command exits
+	done     chan struct{}
+	exitCode int
+	signal   syscall.Signal
+	duration time.Duration
+
+	// closed, if not nil, is called by Close with the result of the command
+	closed func(result *CommandResult)
+
+	// mutex protects the fields below
+	mutex sync.Mutex
+
+	// unmatched is the output that follows the last match
+	unmatched bytes.Buffer
+
+	// transcript is the whole output of the terminal,
+	// the input shows up in it when the terminal echoes
+	transcript bytes.Buffer
+
+	// readErr is the error that ended the output, EIO
+	// once the command and its children have exited
+	readErr error
+}
+
+// StartSession runs the command path on a new pseudo terminal,
+// the terminal is its controlling terminal
+func StartSession(path string, args ...string) (*Session, error) {
+	console, err := NewConsole()
+	if err != nil {
+		return nil, err
+	}
+
+	slave, err := os.OpenFile(console.SlavePath(), os.O_RDWR|unix.O_NOCTTY, 0)
+	if err != nil {
+		console.Close()
+		return nil, err
+	}
+	defer slave.Close()
+
+	s := &Session{
+		Timeout:  Timeout,
+		cmd:      exec.Command(path, args...),
+		console:  console,
+		notify:   make(chan struct{}, 1),
+		readDone: make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+
+	s.cmd.Stdin, s.cmd.Stdout, s.cmd.Stderr = slave, slave, slave
+	s.cmd.SysProcAttr = &syscall.SysProcAttr{
+		Setsid:  true,
+		Setctty: true,
+	}
+
+	LogIfFail("Starting session '%s'\n", strings.Join(s.cmd.Args, " "))
+
+	s.start = time.Now()
+	if err := s.cmd.Start(); err != nil {
+		console.Close()
+		recordResult(s.cmd.Args, commandEnv(s.cmd), &CommandResult{ExitCode: -1, Start: s.start}, err)
+		return nil, err
+	}
+
+	registerSession(s)
+
+	go s.read()
+	go s.wait()
+
+	return s, nil
+}
+
+// StartDockerSession runs the docker command on a new pseudo terminal,
+// e.g. StartDockerSession("attach", id)
+func StartDockerSession(command string, args ...string) (*Session, error) {
+	s, err := StartSession(Docker, append([]string{command}, args...)...)
+	if err != nil {
+		return nil, err
+	}
+
+	// containers exist as soon as an interactive run starts, whatever
+	// the exit code of the session, the names given are tracked now and
+	// the objects printed by the command once it exits
+	trackDockerResources(createdDockerResources(command, args, ""))
+	s.closed = func(result *CommandResult) {
+		trackDockerCommand(command, args, result)
+	}
+
+	return s, nil
+}
+
+func (s *Session) read() {
+	defer close(s.readDone)
+
+	buf := make([]byte, 4096)
+
+	for {
+		n, err := s.console.Read(buf)
+
+		s.mutex.Lock()
+		s.unmatched.Write(buf[:n])
+		s.transcript.Write(buf[:n])
+		if err != nil {
+			s.readErr = err
+		}
+		s.mutex.Unlock()
+
+		select {
+		case s.notify <- struct{}{}:
+		default:
+		}
+
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (s *Session) wait() {
+	_ = s.cmd.Wait()
+
+	s.duration = time.Since(s.start)
+
+	status := s.cmd.ProcessState.Sys().(syscall.WaitStatus)
+	s.exitCode = status.ExitStatus()
+	if status.Signaled() {
+		s.signal = status.Signal()
+	}
+
+	close(s.done)
+}
+
+// Console returns the console of the session, e.g. to resize it
+func (s *Session) Console() *Console {
+	return s.console
+}
+
+// Send types text in the terminal, e.g. "exit\n"
+func (s *Session) Send(text string) error {
+	_, err := s.console.Write([]byte(text))
+	return err
+}
+
+// Interrupt types Ctrl-C in the terminal
+func (s *Session) Interrupt() error {
+	return s.console.Interrupt()
+}
+
+// ExpectString waits until the output contains text
+func (s *Session) ExpectString(text string) error {
+	return s.expect(fmt.Sprintf("%q", text), func(out string) (int, bool) {
+		i := strings.Index(out, text)
+		if i < 0 {
+			return 0, false
+		}
+
+		return i + len(text), true
+	})
+}
+
+// ExpectRegexp waits until the output matches the regular expression
+// expr and returns the match followed by its submatches
+func (s *Session) ExpectRegexp(expr string) ([]string, error) {
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	var match []string
+	err = s.expect(fmt.Sprintf("/%s/", expr), func(out string) (int, bool) {
+		loc := re.FindStringSubmatchIndex(out)
+		if loc == nil {
+			return 0, false
+		}
+
+		for i := 0; i < len(loc); i += 2 {
+			if loc[i] < 0 {
+				match = append(match, "")
+				continue
+			}
+			match = append(match, out[loc[i]:loc[i+1]])
+		}
+
+		return loc[1], true
+	})
+
+	return match, err
+}
+
+// expect waits until match finds what is described by desc in the
+// unmatched output, the output up to the end of the match is consumed
+func (s *Session) expect(desc string, match func(out string) (end int, ok bool)) error {
+	timeout := time.After(s.Timeout)
+
+	for {
+		s.mutex.Lock()
+		out := s.unmatched.String()
+		end, ok := match(out)
+		if ok {
+			s.unmatched.Next(end)
+		}
+		readErr := s.readErr
+		s.mutex.Unlock()
+
+		if ok {
+			return nil
+		}
+
+		if readErr != nil {
+			return fmt.Errorf("%s not found before the end of the output (%v), output: %q", desc, readErr, out)
+		}
+
+		select {
+		case <-s.notify:
+		case <-timeout:
+			return fmt.Errorf("timed out after %s waiting for %s, output: %q", s.Timeout, desc, out)
+		}
+	}
+}
+
+// Transcript returns the whole output of the terminal
+func (s *Session) Transcript() string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.transcript.String()
+}
+
+// Wait waits for the command to exit and returns its exit code,
+// -1 if it was terminated by a signal
+func (s *Session) Wait() (int, error) {
+	select {
+	case <-s.done:
+		return s.exitCode, nil
+	case <-time.After(s.Timeout):
+		return -1, fmt.Errorf("timed out after %s waiting for '%s' to exit", s.Timeout, strings.Join(s.cmd.Args, " "))
+	}
+}
+
+// Close kills the command if it is still running, records it
+// in the transcript of the spec and closes the terminal
+func (s *Session) Close() error {
+	select {
+	case <-s.done:
+	default:
+		// a negative pid means the whole process group
+		_ = syscall.Kill(-s.cmd.Process.Pid, syscall.SIGKILL)
+		<-s.done
+	}
+
+	// the output ends once the processes sharing the terminal exit,
+	// the ones that outlive the command do not block Close
+	select {
+	case <-s.readDone:
+	case <-time.After(waitDelay):
+	}
+
+	unregisterSession(s)
+	result := s.result()
+
+	LogIfFail("Session '%s'\nExit Code: %d\nTranscript: %s\n",
+		strings.Join(s.cmd.Args, " "), result.ExitCode, result.Stdout)
+
+	recordResult(s.cmd.Args, commandEnv(s.cmd), result, nil)
+
+	if s.closed != nil {
+		s.closed(result)
+	}
+
+	return s.console.Close()
+}
+
+// result returns the result of the exited command,
+// its stdout is the output of the terminal
+func (s *Session) result() *CommandResult {
+	return &CommandResult{
+		Stdout:   s.Transcript(),
+		ExitCode: s.exitCode,
+		Signal:   s.signal,
+		Start:    s.start,
+		Duration: s.duration,
+	}
+}
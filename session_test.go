@@ -0,0 +1,162 @@
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tests
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSession(t *testing.T) {
+	assert := assert.New(t)
+
+	s, err := StartSession("sh")
+	if !assert.NoError(err) {
+		return
+	}
+	defer s.Close()
+
+	s.Timeout = testConsoleTimeout
+
+	assert.NoError(s.Send("tty\n"))
+	match, err := s.ExpectRegexp(`(/dev/pts/(\d+))\n`)
+	assert.NoError(err)
+	if assert.Len(match, 3) {
+		assert.Equal(s.Console().SlavePath(), match[1])
+	}
+
+	// the echo of the input does not contain the result
+	assert.NoError(s.Send("echo $((6 * 7))\n"))
+	assert.NoError(s.ExpectString("42"))
+
+	assert.NoError(s.Send("exit 3\n"))
+	exitCode, err := s.Wait()
+	assert.NoError(err)
+	assert.Equal(3, exitCode)
+
+	assert.Contains(s.Transcript(), "echo $((6 * 7))")
+}
+
+func TestSessionExpectOrder(t *testing.T) {
+	assert := assert.New(t)
+
+	s, err := StartSession("sh", "-c", "echo one; echo two; sleep 30")
+	if !assert.NoError(err) {
+		return
+	}
+	defer s.Close()
+
+	s.Timeout = testConsoleTimeout
+	assert.NoError(s.ExpectString("two"))
+
+	// "one" was before the last match
+	s.Timeout = 200 * time.Millisecond
+	err = s.ExpectString("one")
+	if assert.Error(err) {
+		assert.True(strings.HasPrefix(err.Error(), "timed out"), err.Error())
+	}
+}
+
+func TestSessionEndOfOutput(t *testing.T) {
+	assert := assert.New(t)
+
+	s, err := StartSession("sh", "-c", "echo bye")
+	if !assert.NoError(err) {
+		return
+	}
+	defer s.Close()
+
+	s.Timeout = testConsoleTimeout
+
+	start := time.Now()
+	assert.Error(s.ExpectString("never"))
+	assert.True(time.Since(start) < testConsoleTimeout)
+
+	exitCode, err := s.Wait()
+	assert.NoError(err)
+	assert.Equal(0, exitCode)
+}
+
+func TestSessionInterrupt(t *testing.T) {
+	assert := assert.New(t)
+
+	s, err := StartSession("sh", "-c", `trap "echo interrupted; exit 5" INT; echo ready; while :; do sleep 1; done`)
+	if !assert.NoError(err) {
+		return
+	}
+	defer s.Close()
+
+	s.Timeout = testConsoleTimeout
+
+	assert.NoError(s.ExpectString("ready"))
+	assert.NoError(s.Interrupt())
+	assert.NoError(s.ExpectString("interrupted"))
+
+	exitCode, err := s.Wait()
+	assert.NoError(err)
+	assert.Equal(5, exitCode)
+}
+
+func TestSessionTranscript(t *testing.T) {
+	assert := assert.New(t)
+
+	enableTranscript(true)
+	defer enableTranscript(false)
+
+	s, err := StartSession("sh", "-c", "echo ready; read line; echo got $line; exit 4")
+	if !assert.NoError(err) {
+		return
+	}
+
+	s.Timeout = testConsoleTimeout
+	assert.NoError(s.ExpectString("ready"))
+
+	// the output of the live sessions is collected with the artifacts
+	dir, err := ioutil.TempDir(testDir, "artifacts")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	assert.NoError(collectSessionArtifacts(dir, ""))
+	content, err := ioutil.ReadFile(filepath.Join(dir, "sessions.txt"))
+	assert.NoError(err)
+	assert.Contains(string(content), "$ sh -c echo ready;")
+	assert.Contains(string(content), "ready\n")
+
+	assert.NoError(s.Send("hello\n"))
+	exitCode, err := s.Wait()
+	assert.NoError(err)
+	assert.Equal(4, exitCode)
+	assert.NoError(s.Close())
+
+	records := takeTranscript()
+	if assert.Len(records, 1) {
+		assert.Equal([]string{"sh", "-c", "echo ready; read line; echo got $line; exit 4"}, records[0].Args)
+		assert.Equal(4, records[0].ExitCode)
+		assert.Contains(records[0].Stdout, "got hello\n")
+		assert.False(records[0].Start.IsZero())
+	}
+
+	// closed sessions are not collected
+	assert.NoError(os.Remove(filepath.Join(dir, "sessions.txt")))
+	assert.NoError(collectSessionArtifacts(dir, ""))
+	_, err = os.Stat(filepath.Join(dir, "sessions.txt"))
+	assert.True(os.IsNotExist(err))
+}
@@ -0,0 +1,392 @@
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tests
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/BurntSushi/toml"
+)
+
+// The settings of the suites come from, in increasing order of precedence:
+// a TOML file (-suite-config), environment variables and command line flags.
+// Every flag of the suites has an environment variable named after it, e.g.
+// -docker-backend is CC_TESTS_DOCKER_BACKEND, list flags take comma separated
+// values. The flags of ginkgo and go test are not set by the environment.
+// An example of suite configuration file:
+//
+//	artifacts_dir = "/var/tmp/cc-tests"
+//	report_dir = "/var/tmp/cc-tests/reports"
+//
+//	[runtime]
+//	path = "/usr/local/bin/cc-runtime"
+//	global_options = ["--root", "/run/cc-tests"]
+//
+//	[docker]
+//	path = "/usr/bin/docker"
+//	local_registry = true
+//
+//	[images]
+//	busybox = "registry.local/busybox:1.26"
+//	manifest = "/var/lib/cc-tests/images.toml"
+//
+//	[timeouts]
+//	default = 15
+//
+//	[timeouts.operations]
+//	docker-pull = "20m"
+//
+//	[[hypervisor]]
+//	name = "my-qemu"
+//	pattern = '.*/my-qemu .*{{ID}}.*'
+
+const (
+	// suiteConfigFlag is the flag of the suite configuration file
+	suiteConfigFlag = "suite-config"
+
+	// suiteConfigEnvPrefix is the prefix of the environment
+	// variables that set the flags
+	suiteConfigEnvPrefix = "CC_TESTS_"
+)
+
+// suiteConfigPath is the path of the suite configuration file
+var suiteConfigPath string
+
+// ArtifactsDir is the directory where the suites keep the files
+// that help to debug them, e.g. logs of the failed specs
+var ArtifactsDir = filepath.Join(tmpDir, "cc-tests-artifacts")
+
+// suiteFlags are the names of the flags of the suites, the only
+// flags set by the environment and the suite configuration file
+var suiteFlags = make(map[string]bool)
+
+func init() {
+	flag.StringVar(&suiteConfigPath, suiteFlag(suiteConfigFlag), "", "Path of the TOML file with the settings of the suite")
+	flag.StringVar(&ArtifactsDir, suiteFlag("artifacts-dir"), ArtifactsDir, "Directory where the suites keep the files that help to debug them")
+}
+
+// suiteFlag adds name to the flags of the suites and returns it,
+// the files of the package register their flags with it, e.g.
+//
+//	flag.StringVar(&Docker, suiteFlag("docker"), Docker, "Path of the docker command")
+func suiteFlag(name string) string {
+	suiteFlags[name] = true
+	return name
+}
+
+var suiteConfig = struct {
+	sync.Once
+	err error
+}{}
+
+// suiteConfigFile is the content of the suite configuration file,
+// empty settings keep the value of the flag
+type suiteConfigFile struct {
+	Runtime struct {
+		Path          string
+		Type          string
+		GlobalOptions []string `toml:"global_options"`
+	}
+
+	Docker struct {
+		Path          string
+		Backend       string
+		Socket        string
+		LocalRegistry bool `toml:"local_registry"`
+	}
+
+	Images struct {
+		Busybox  string
+		Alpine   string
+		Postgres string
+		Manifest string
+	}
+
+	// Timeouts are durations (e.g. "1m30s") or numbers of seconds,
+	// TOML strings or integers
+	Timeouts struct {
+		Default    interface{}
+		Operations map[string]interface{}
+	}
+
+	ArtifactsDir string `toml:"artifacts_dir"`
+
+	ReportDir string `toml:"report_dir"`
+
+	Hypervisors []struct {
+		Name    string
+		Pattern string
+	} `toml:"hypervisor"`
+}
+
+// LoadSuiteConfig applies the suite configuration file and the environment
+// variables to the flags not specified in the command line, suites call it
+// once the flags are parsed, before running the specs
+func LoadSuiteConfig() error {
+	suiteConfig.Do(func() {
+		suiteConfig.err = loadSuiteConfig(flag.CommandLine, suiteFlags, os.Getenv)
+	})
+
+	return suiteConfig.err
+}
+
+// loadSuiteConfig sets the flags of fs named in names
+func loadSuiteConfig(fs *flag.FlagSet, names map[string]bool, getenv func(string) string) error {
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+
+	// the configuration file can be specified in the environment too
+	if !set[suiteConfigFlag] {
+		if path := getenv(suiteConfigEnv(suiteConfigFlag)); path != "" {
+			if err := fs.Set(suiteConfigFlag, path); err != nil {
+				return err
+			}
+		}
+	}
+
+	var path string
+	if f := fs.Lookup(suiteConfigFlag); f != nil {
+		path = f.Value.String()
+	}
+
+	file := &suiteConfigFile{}
+	if path != "" {
+		var err error
+		if file, err = readSuiteConfigFile(path); err != nil {
+			return err
+		}
+	}
+
+	values := file.flagValues()
+	lists := file.flagLists()
+
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		if err != nil || !names[f.Name] || set[f.Name] || f.Name == suiteConfigFlag {
+			return
+		}
+
+		source, value := suiteConfigEnv(f.Name), getenv(suiteConfigEnv(f.Name))
+		if value == "" {
+			// the elements of the lists can contain commas,
+			// they are not given to Set
+			if list, ok := f.Value.(listValue); ok && len(lists[f.Name]) > 0 {
+				*list.list = append(*list.list, lists[f.Name]...)
+				return
+			}
+
+			source, value = path, values[f.Name]
+		}
+
+		if value == "" {
+			return
+		}
+
+		if setErr := fs.Set(f.Name, value); setErr != nil {
+			err = fmt.Errorf("%s: invalid %s: %v", source, f.Name, setErr)
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, h := range file.Hypervisors {
+		if h.Name == "" || h.Pattern == "" {
+			return fmt.Errorf("%s: hypervisors need a name and a pattern", path)
+		}
+
+		if err := RegisterHypervisorMatcher(h.Name, h.Pattern); err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+	}
+
+	return nil
+}
+
+// suiteConfigEnv returns the environment variable of the flag name
+func suiteConfigEnv(name string) string {
+	return suiteConfigEnvPrefix + strings.ToUpper(strings.Replace(name, "-", "_", -1))
+}
+
+func readSuiteConfigFile(path string) (*suiteConfigFile, error) {
+	var file suiteConfigFile
+
+	md, err := toml.DecodeFile(path, &file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read suite config %s: %v", path, err)
+	}
+
+	if undecoded := md.Undecoded(); len(undecoded) > 0 {
+		var keys []string
+		for _, k := range undecoded {
+			keys = append(keys, k.String())
+		}
+
+		return nil, fmt.Errorf("unknown settings in suite config %s: %s", path, strings.Join(keys, ", "))
+	}
+
+	return &file, nil
+}
+
+// flagValues returns the values of the flags set by the file
+func (f *suiteConfigFile) flagValues() map[string]string {
+	values := map[string]string{
+		"runtime":        f.Runtime.Path,
+		"runtime-type":   f.Runtime.Type,
+		"docker":         f.Docker.Path,
+		"docker-backend": f.Docker.Backend,
+		"docker-socket":  f.Docker.Socket,
+		"busybox-image":  f.Images.Busybox,
+		"alpine-image":   f.Images.Alpine,
+		"postgres-image": f.Images.Postgres,
+		"image-manifest": f.Images.Manifest,
+		"artifacts-dir":  f.ArtifactsDir,
+		"report-dir":     f.ReportDir,
+		"timeout":        suiteConfigTimeout(f.Timeouts.Default),
+	}
+
+	var operations []string
+	for op, timeout := range f.Timeouts.Operations {
+		operations = append(operations, op+"="+suiteConfigTimeout(timeout))
+	}
+	sort.Strings(operations)
+	values["operation-timeout"] = strings.Join(operations, ",")
+
+	if f.Docker.LocalRegistry {
+		values["local-registry"] = "true"
+	}
+
+	for name, value := range values {
+		if value == "" {
+			delete(values, name)
+		}
+	}
+
+	return values
+}
+
+// flagLists returns the lists of the list flags set by the file
+func (f *suiteConfigFile) flagLists() map[string][]string {
+	return map[string][]string{
+		"runtime-option": f.Runtime.GlobalOptions,
+	}
+}
+
+// suiteConfigTimeout returns the flag value of a timeout of the suite
+// configuration file, the other types of values are rejected by the flag
+func suiteConfigTimeout(timeout interface{}) string {
+	if timeout == nil {
+		return ""
+	}
+
+	return fmt.Sprint(timeout)
+}
+
+// timeoutValue is a flag.Value of a duration that also
+// accepts a number of seconds, e.g. "15" is "15s"
+type timeoutValue struct {
+	d *time.Duration
+}
+
+func (v timeoutValue) String() string {
+	if v.d == nil {
+		return ""
+	}
+
+	return v.d.String()
+}
+
+func (v timeoutValue) Set(value string) error {
+	d, err := parseTimeout(value)
+	if err != nil {
+		return err
+	}
+
+	*v.d = d
+
+	return nil
+}
+
+func parseTimeout(value string) (time.Duration, error) {
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second, nil
+	}
+
+	return time.ParseDuration(value)
+}
+
+// listValue is a flag.Value of a list, every Set appends
+// the comma separated values
+type listValue struct {
+	list *[]string
+}
+
+func (v listValue) String() string {
+	if v.list == nil {
+		return ""
+	}
+
+	return strings.Join(*v.list, ",")
+}
+
+func (v listValue) Set(value string) error {
+	*v.list = append(*v.list, strings.Split(value, ",")...)
+	return nil
+}
+
+// timeoutsValue is a flag.Value of the timeouts of the operations
+// in the form operation=timeout, comma separated
+type timeoutsValue struct {
+	timeouts map[string]time.Duration
+}
+
+func (v timeoutsValue) String() string {
+	var timeouts []string
+	for op, d := range v.timeouts {
+		timeouts = append(timeouts, op+"="+d.String())
+	}
+
+	sort.Strings(timeouts)
+
+	return strings.Join(timeouts, ",")
+}
+
+func (v timeoutsValue) Set(value string) error {
+	for _, t := range strings.Split(value, ",") {
+		parts := strings.SplitN(t, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return fmt.Errorf("%q is not in the form operation=timeout", t)
+		}
+
+		d, err := parseTimeout(parts[1])
+		if err != nil {
+			return err
+		}
+
+		v.timeouts[parts[0]] = d
+	}
+
+	return nil
+}
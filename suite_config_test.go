@@ -0,0 +1,251 @@
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tests
+
+import (
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testFlags are the flags of a suite configuration test
+type testFlags struct {
+	fs       *flag.FlagSet
+	names    map[string]bool
+	runtime  string
+	docker   string
+	options  []string
+	timeout  time.Duration
+	timeouts map[string]time.Duration
+}
+
+func newTestFlags(args ...string) (*testFlags, error) {
+	f := &testFlags{
+		fs:       flag.NewFlagSet("test", flag.ContinueOnError),
+		timeout:  5 * time.Second,
+		timeouts: map[string]time.Duration{"docker-pull": time.Minute},
+	}
+
+	f.fs.String(suiteConfigFlag, "", "")
+	f.fs.StringVar(&f.runtime, "runtime", "cc-runtime", "")
+	f.fs.StringVar(&f.docker, "docker", "docker", "")
+	f.fs.Var(listValue{&f.options}, "runtime-option", "")
+	f.fs.Var(timeoutValue{&f.timeout}, "timeout", "")
+	f.fs.Var(timeoutsValue{f.timeouts}, "operation-timeout", "")
+
+	f.names = make(map[string]bool)
+	f.fs.VisitAll(func(fl *flag.Flag) {
+		f.names[fl.Name] = true
+	})
+
+	// a flag that is not a flag of the suites, like the ones of go test
+	f.fs.Bool("v", false, "")
+
+	return f, f.fs.Parse(args)
+}
+
+func writeSuiteConfig(t *testing.T, content string) string {
+	dir, err := ioutil.TempDir(testDir, "suite-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(dir, "config.toml")
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestSuiteConfigPrecedence(t *testing.T) {
+	assert := assert.New(t)
+
+	path := writeSuiteConfig(t, `
+[runtime]
+path = "/file/runtime"
+global_options = ["--root", "/run/file"]
+
+[docker]
+path = "/file/docker"
+
+[timeouts]
+default = "30s"
+
+[timeouts.operations]
+docker-stop = "1m"
+`)
+
+	f, err := newTestFlags("-suite-config", path, "-runtime", "/flag/runtime")
+	assert.NoError(err)
+
+	env := map[string]string{
+		"CC_TESTS_DOCKER":  "/env/docker",
+		"CC_TESTS_TIMEOUT": "20",
+	}
+	getenv := func(name string) string { return env[name] }
+
+	assert.NoError(loadSuiteConfig(f.fs, f.names, getenv))
+	assert.Equal("/flag/runtime", f.runtime)
+	assert.Equal("/env/docker", f.docker)
+	assert.Equal([]string{"--root", "/run/file"}, f.options)
+	assert.Equal(20*time.Second, f.timeout)
+	assert.Equal(map[string]time.Duration{
+		"docker-pull": time.Minute,
+		"docker-stop": time.Minute,
+	}, f.timeouts)
+}
+
+func TestSuiteConfigFromEnv(t *testing.T) {
+	assert := assert.New(t)
+
+	path := writeSuiteConfig(t, `
+[timeouts]
+default = "1m30s"
+`)
+
+	f, err := newTestFlags()
+	assert.NoError(err)
+
+	env := map[string]string{
+		"CC_TESTS_SUITE_CONFIG":   path,
+		"CC_TESTS_RUNTIME_OPTION": "--debug,--log-format=json",
+	}
+	getenv := func(name string) string { return env[name] }
+
+	assert.NoError(loadSuiteConfig(f.fs, f.names, getenv))
+	assert.Equal(90*time.Second, f.timeout)
+	assert.Equal([]string{"--debug", "--log-format=json"}, f.options)
+	assert.Equal("cc-runtime", f.runtime)
+}
+
+func TestSuiteConfigListWithCommas(t *testing.T) {
+	assert := assert.New(t)
+
+	path := writeSuiteConfig(t, `
+[runtime]
+global_options = ["--log-opt", "a=1,b=2"]
+`)
+
+	f, err := newTestFlags("-suite-config", path)
+	assert.NoError(err)
+
+	assert.NoError(loadSuiteConfig(f.fs, f.names, func(string) string { return "" }))
+	assert.Equal([]string{"--log-opt", "a=1,b=2"}, f.options)
+}
+
+func TestSuiteConfigErrors(t *testing.T) {
+	assert := assert.New(t)
+	noEnv := func(string) string { return "" }
+
+	for _, content := range []string{
+		"[runtime]\npaht = \"cc-runtime\"\n",
+		"[timeouts]\ndefault = \"15 seconds\"\n",
+		"[timeouts]\ndefault = 1.5\n",
+		"[[hypervisor]]\nname = \"no-pattern\"\n",
+		"[[hypervisor]]\nname = \"bad\"\npattern = \"(\"\n",
+	} {
+		f, err := newTestFlags("-suite-config", writeSuiteConfig(t, content))
+		assert.NoError(err)
+		assert.Error(loadSuiteConfig(f.fs, f.names, noEnv), content)
+	}
+
+	f, err := newTestFlags()
+	assert.NoError(err)
+	assert.Error(loadSuiteConfig(f.fs, f.names, func(name string) string {
+		if name == "CC_TESTS_OPERATION_TIMEOUT" {
+			return "docker-pull"
+		}
+		return ""
+	}))
+}
+
+func TestSuiteConfigIntegerTimeouts(t *testing.T) {
+	assert := assert.New(t)
+
+	path := writeSuiteConfig(t, `
+[timeouts]
+default = 15
+
+[timeouts.operations]
+docker-pull = 1200
+docker-stop = "1m"
+`)
+
+	f, err := newTestFlags("-suite-config", path)
+	assert.NoError(err)
+
+	assert.NoError(loadSuiteConfig(f.fs, f.names, func(string) string { return "" }))
+	assert.Equal(15*time.Second, f.timeout)
+	assert.Equal(map[string]time.Duration{
+		"docker-pull": 20 * time.Minute,
+		"docker-stop": time.Minute,
+	}, f.timeouts)
+}
+
+func TestSuiteConfigOtherFlags(t *testing.T) {
+	assert := assert.New(t)
+
+	f, err := newTestFlags()
+	assert.NoError(err)
+
+	env := map[string]string{
+		"CC_TESTS_V":       "true",
+		"CC_TESTS_RUNTIME": "/env/runtime",
+	}
+	getenv := func(name string) string { return env[name] }
+
+	assert.NoError(loadSuiteConfig(f.fs, f.names, getenv))
+	assert.Equal("/env/runtime", f.runtime)
+	assert.Equal("false", f.fs.Lookup("v").Value.String())
+}
+
+func TestSuiteFlags(t *testing.T) {
+	assert := assert.New(t)
+
+	// every flag of the package is a flag of the suites
+	for _, name := range []string{suiteConfigFlag, "runtime", "timeout", "docker", "docker-backend",
+		"hypervisor-regex", "leak-processes", "config-template-name", "report-dir"} {
+		assert.True(suiteFlags[name], name)
+		assert.NotNil(flag.Lookup(name), name)
+	}
+
+	// the flags of go test are not
+	assert.False(suiteFlags["test.v"])
+	assert.False(suiteFlags["test.timeout"])
+}
+
+func TestParseTimeout(t *testing.T) {
+	assert := assert.New(t)
+
+	for value, expected := range map[string]time.Duration{
+		"15":     15 * time.Second,
+		"0":      0,
+		"2m":     2 * time.Minute,
+		"1m30s":  90 * time.Second,
+		"1500ms": 1500 * time.Millisecond,
+	} {
+		d, err := parseTimeout(value)
+		assert.NoError(err)
+		assert.Equal(expected, d, value)
+	}
+
+	_, err := parseTimeout("soon")
+	assert.Error(err)
+}
@@ -0,0 +1,212 @@
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tests
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// transcriptOutputLimit is the maximum number of bytes of
+// stdout and stderr kept in the transcripts
+const transcriptOutputLimit = 4096
+
+// CommandRecord is the record of a command run by a spec
+type CommandRecord struct {
+	// Args are the command and its arguments, the method
+	// and the URI of the requests of the Engine API
+	Args []string `json:"args"`
+
+	// Env are the environment variables of the command that are not
+	// in the environment of the suite when the reporter started
+	Env []string `json:"env,omitempty"`
+
+	// Start is the time when the command was started
+	Start time.Time `json:"start"`
+
+	// Duration is the time the command took to complete
+	Duration time.Duration `json:"duration"`
+
+	// ExitCode is the exit code of the command, -1 if it could
+	// not be started or was terminated by a signal
+	ExitCode int `json:"exit_code"`
+
+	// TimedOut is true if the command reached its time limit
+	TimedOut bool `json:"timed_out,omitempty"`
+
+	// Error is the error that prevented the command from starting
+	Error string `json:"error,omitempty"`
+
+	// Stdout and Stderr are the output of the command,
+	// truncated to their first transcriptOutputLimit bytes
+	Stdout string `json:"stdout,omitempty"`
+	Stderr string `json:"stderr,omitempty"`
+
+	// StdoutTruncated and StderrTruncated are true
+	// if the output was truncated
+	StdoutTruncated bool `json:"stdout_truncated,omitempty"`
+	StderrTruncated bool `json:"stderr_truncated,omitempty"`
+}
+
+// String returns the record in a readable form
+func (r CommandRecord) String() string {
+	s := fmt.Sprintf("$ %s\n", strings.Join(r.Args, " "))
+
+	if len(r.Env) > 0 {
+		s += fmt.Sprintf("Env: %s\n", strings.Join(r.Env, " "))
+	}
+
+	s += fmt.Sprintf("Start: %s\nDuration: %s\nExit Code: %d\n",
+		r.Start.Format(time.RFC3339Nano), r.Duration, r.ExitCode)
+
+	if r.TimedOut {
+		s += "Timed out: true\n"
+	}
+
+	if r.Error != "" {
+		s += fmt.Sprintf("Error: %s\n", r.Error)
+	}
+
+	s += transcriptOutput("Stdout", r.Stdout, r.StdoutTruncated)
+	s += transcriptOutput("Stderr", r.Stderr, r.StderrTruncated)
+
+	return s
+}
+
+func transcriptOutput(name, output string, truncated bool) string {
+	if output == "" {
+		return ""
+	}
+
+	if truncated {
+		name += " (truncated)"
+	}
+
+	return fmt.Sprintf("%s:\n%s\n", name, strings.TrimRight(output, "\n"))
+}
+
+// transcript is the record of the commands run since the
+// last call to takeTranscript, the commands are only
+// recorded while a SpecReporter is running
+var transcript struct {
+	sync.Mutex
+	enabled bool
+	records []CommandRecord
+
+	// environ is the environment of the suite when the
+	// transcripts were enabled, only the variables of
+	// the commands not in it are recorded
+	environ map[string]bool
+}
+
+func enableTranscript(enabled bool) {
+	transcript.Lock()
+	defer transcript.Unlock()
+
+	transcript.enabled = enabled
+	transcript.records = nil
+	transcript.environ = make(map[string]bool)
+
+	for _, e := range os.Environ() {
+		transcript.environ[e] = true
+	}
+}
+
+// recordCommand adds the command run by c with result to the transcript
+func recordCommand(c *Command, result *CommandResult, err error) {
+	recordResult(c.cmd.Args, commandEnv(c.cmd), result, err)
+}
+
+// commandEnv returns the environment of the command cmd
+func commandEnv(cmd *exec.Cmd) []string {
+	if cmd.Env == nil {
+		return os.Environ()
+	}
+
+	return cmd.Env
+}
+
+// recordResult adds the command args run with the environment env
+// and its result to the transcript, env is nil for the requests
+func recordResult(args, env []string, result *CommandResult, err error) {
+	transcript.Lock()
+	defer transcript.Unlock()
+
+	if !transcript.enabled {
+		return
+	}
+
+	r := CommandRecord{
+		Args:     append([]string{}, args...),
+		Env:      envDelta(env),
+		Start:    result.Start,
+		Duration: result.Duration,
+		ExitCode: result.ExitCode,
+		TimedOut: result.TimedOut,
+	}
+
+	if err != nil {
+		r.Error = err.Error()
+	}
+
+	r.Stdout, r.StdoutTruncated = truncateOutput(result.Stdout)
+	r.Stderr, r.StderrTruncated = truncateOutput(result.Stderr)
+
+	transcript.records = append(transcript.records, r)
+}
+
+// takeTranscript returns the commands recorded and starts a new transcript
+func takeTranscript() []CommandRecord {
+	transcript.Lock()
+	defer transcript.Unlock()
+
+	records := transcript.records
+	transcript.records = nil
+
+	return records
+}
+
+// envDelta returns the variables of env not in the environment
+// of the suite, transcript must be locked
+func envDelta(env []string) []string {
+	var delta []string
+	for _, e := range env {
+		if !transcript.environ[e] {
+			delta = append(delta, e)
+		}
+	}
+
+	return delta
+}
+
+// truncateOutput returns the first transcriptOutputLimit bytes of
+// output, the multi-byte characters at the limit are not split
+func truncateOutput(output string) (string, bool) {
+	if len(output) <= transcriptOutputLimit {
+		return output, false
+	}
+
+	end := transcriptOutputLimit
+	for end > 0 && !utf8.RuneStart(output[end]) {
+		end--
+	}
+
+	return output[:end], true
+}
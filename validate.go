@@ -0,0 +1,236 @@
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+
+	spec "github.com/opencontainers/specs/specs-go"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+// configSchemaFile is the OCI runtime-spec JSON schema of config.json,
+// the other schema files of its directory hold the definitions it refers to
+const configSchemaFile = "config-schema.json"
+
+var (
+	configSchema     *gojsonschema.Schema
+	configSchemaErr  error
+	configSchemaOnce sync.Once
+)
+
+// schemaDir returns the directory of the vendored runtime-spec schema
+func schemaDir() string {
+	_, file, _, _ := runtime.Caller(0)
+	return filepath.Join(filepath.Dir(file), "vendor", "github.com", "opencontainers", "specs", "schema")
+}
+
+// loadConfigSchema compiles the vendored config.json schema. The ids of
+// the schema files are absolute URLs the references are resolved
+// against, they are removed so that the references are resolved against
+// the vendored files rather than downloaded.
+func loadConfigSchema() (*gojsonschema.Schema, error) {
+	dir := schemaDir()
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	loader := gojsonschema.NewSchemaLoader()
+	for _, file := range files {
+		content, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+
+		var doc interface{}
+		if err := json.Unmarshal(content, &doc); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %v", file, err)
+		}
+		removeSchemaIDs(doc)
+
+		if err := loader.AddSchema("file://"+file, gojsonschema.NewGoLoader(doc)); err != nil {
+			return nil, fmt.Errorf("failed to load %s: %v", file, err)
+		}
+	}
+
+	return loader.Compile(gojsonschema.NewReferenceLoader("file://" + filepath.Join(dir, configSchemaFile)))
+}
+
+// removeSchemaIDs removes the ids of the schema doc and of its subschemas
+func removeSchemaIDs(doc interface{}) {
+	switch v := doc.(type) {
+	case map[string]interface{}:
+		if _, ok := v["id"].(string); ok {
+			delete(v, "id")
+		}
+		for _, child := range v {
+			removeSchemaIDs(child)
+		}
+	case []interface{}:
+		for _, child := range v {
+			removeSchemaIDs(child)
+		}
+	}
+}
+
+// configValidator collects the errors found in a configuration
+type configValidator struct {
+	errs []string
+}
+
+func (v *configValidator) errorf(format string, args ...interface{}) {
+	v.errs = append(v.errs, fmt.Sprintf(format, args...))
+}
+
+// Validate checks the Config of the bundle against the OCI runtime-spec
+// schema and the requirements of config.md the schema cannot express,
+// all the errors found are reported
+func (b *Bundle) Validate() error {
+	if b.Config == nil {
+		return fmt.Errorf("bundle has no config")
+	}
+
+	configSchemaOnce.Do(func() {
+		configSchema, configSchemaErr = loadConfigSchema()
+	})
+	if configSchemaErr != nil {
+		return fmt.Errorf("failed to load the config schema: %v", configSchemaErr)
+	}
+
+	result, err := configSchema.Validate(gojsonschema.NewGoLoader(b.Config))
+	if err != nil {
+		return err
+	}
+
+	v := &configValidator{}
+	for _, e := range result.Errors() {
+		v.errorf("%s", e)
+	}
+	v.validate(b.Config)
+
+	if len(v.errs) > 0 {
+		return fmt.Errorf("invalid config.json: %s", strings.Join(v.errs, "; "))
+	}
+
+	return nil
+}
+
+func (v *configValidator) validate(config *spec.Spec) {
+	if config.Version == "" {
+		v.errorf("ociVersion is required")
+	}
+
+	if config.Platform.OS == "" || config.Platform.Arch == "" {
+		v.errorf("platform os and arch are required")
+	}
+
+	if config.Root.Path == "" {
+		v.errorf("root path is required")
+	}
+
+	v.validateProcess(&config.Process)
+
+	for _, m := range config.Mounts {
+		if !filepath.IsAbs(m.Destination) {
+			v.errorf("mount destination %q must be an absolute path", m.Destination)
+		}
+	}
+
+	if config.Hooks != nil {
+		v.validateHooks("prestart", config.Hooks.Prestart)
+		v.validateHooks("poststart", config.Hooks.Poststart)
+		v.validateHooks("poststop", config.Hooks.Poststop)
+	}
+
+	for key := range config.Annotations {
+		if key == "" {
+			v.errorf("annotation keys must not be empty")
+		}
+	}
+
+	if config.Linux != nil {
+		v.validateLinux(config.Linux)
+	}
+}
+
+func (v *configValidator) validateProcess(process *spec.Process) {
+	if !filepath.IsAbs(process.Cwd) {
+		v.errorf("process cwd %q must be an absolute path", process.Cwd)
+	}
+
+	for _, e := range process.Env {
+		if !strings.Contains(e, "=") || strings.HasPrefix(e, "=") {
+			v.errorf("process env %q must be in the form KEY=value", e)
+		}
+	}
+
+	rlimits := make(map[string]bool)
+	for _, r := range process.Rlimits {
+		if rlimits[r.Type] {
+			v.errorf("duplicated rlimit %s", r.Type)
+		}
+		rlimits[r.Type] = true
+
+		if r.Soft > r.Hard {
+			v.errorf("rlimit %s soft limit %d is greater than hard limit %d", r.Type, r.Soft, r.Hard)
+		}
+	}
+}
+
+func (v *configValidator) validateHooks(stage string, hooks []spec.Hook) {
+	for _, h := range hooks {
+		if !filepath.IsAbs(h.Path) {
+			v.errorf("%s hook path %q must be an absolute path", stage, h.Path)
+		}
+
+		if h.Timeout != nil && *h.Timeout <= 0 {
+			v.errorf("%s hook %s timeout must be greater than zero", stage, h.Path)
+		}
+	}
+}
+
+func (v *configValidator) validateLinux(linux *spec.Linux) {
+	namespaces := make(map[spec.LinuxNamespaceType]bool)
+	for _, ns := range linux.Namespaces {
+		if namespaces[ns.Type] {
+			v.errorf("duplicated namespace %s", ns.Type)
+		}
+		namespaces[ns.Type] = true
+
+		if ns.Path != "" && !filepath.IsAbs(ns.Path) {
+			v.errorf("namespace %s path %q must be an absolute path", ns.Type, ns.Path)
+		}
+	}
+
+	for _, d := range linux.Devices {
+		if !filepath.IsAbs(d.Path) {
+			v.errorf("device path %q must be an absolute path", d.Path)
+		}
+	}
+
+	if r := linux.Resources; r != nil {
+		if r.Memory != nil && r.Memory.Swappiness != nil && *r.Memory.Swappiness > 100 {
+			v.errorf("memory swappiness %d out of range [0, 100]", *r.Memory.Swappiness)
+		}
+	}
+}
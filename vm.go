@@ -1,4 +1,4 @@
-/// Copyright (c) 2017 Intel Corporation
+// Copyright (c) 2017 Intel Corporation
 //
 // Licensed under the Apache License, Version 2.0 (the "License");
 // you may not use this file except in compliance with the License.
@@ -15,50 +15,182 @@
 package tests
 
 import (
-	"errors"
-	"io/ioutil"
-	"os"
-	"path/filepath"
+	"flag"
+	"fmt"
 	"regexp"
+	"strings"
+	"sync"
 )
 
-const procPath = "/proc"
+// containerIDPlaceholder is replaced by the container ID
+// in the command line patterns of the hypervisors
+const containerIDPlaceholder = "{{ID}}"
 
-var errFound = errors.New("found")
+// hypervisorRegex is a custom hypervisor command line pattern,
+// given with the -hypervisor-regex option
+var hypervisorRegex string
 
-// IsVMRunning looks in /proc for a hypervisor process that contains
-// the containerID in its command line
-func IsVMRunning(containerID string) bool {
-	err := filepath.Walk(procPath, func(path string, _ os.FileInfo, _ error) error {
-		if path == "" {
-			return filepath.SkipDir
-		}
+// hypervisorRegexValue is the flag.Value of the -hypervisor-regex
+// option, invalid patterns are rejected when the option is parsed
+type hypervisorRegexValue struct {
+	pattern *string
+}
 
-		info, err := os.Stat(path)
-		if err != nil {
-			return filepath.SkipDir
-		}
+func (v hypervisorRegexValue) String() string {
+	if v.pattern == nil {
+		return ""
+	}
+
+	return *v.pattern
+}
+
+func (v hypervisorRegexValue) Set(pattern string) error {
+	if _, err := compileHypervisorPattern(pattern, "id"); err != nil {
+		return fmt.Errorf("invalid hypervisor pattern: %v", err)
+	}
+
+	*v.pattern = pattern
 
-		if !info.IsDir() {
-			return filepath.SkipDir
+	return nil
+}
+
+func init() {
+	flag.Var(hypervisorRegexValue{&hypervisorRegex}, suiteFlag("hypervisor-regex"), "Command line regular expression of a custom hypervisor, {{ID}} stands for the container ID")
+}
+
+// HypervisorProcess is a hypervisor process running a container
+type HypervisorProcess struct {
+	ProcessInfo
+
+	// Hypervisor is the name of the matcher that recognised the process
+	Hypervisor string
+}
+
+// hypervisorMatcher recognises the command line of a hypervisor
+type hypervisorMatcher struct {
+	name    string
+	pattern string
+}
+
+// hypervisorMatchers are the known hypervisors, their patterns
+// start with the executable so that other processes having the
+// hypervisor name and the container ID in their arguments do not match
+var hypervisorMatchers = struct {
+	sync.Mutex
+	matchers []hypervisorMatcher
+}{
+	matchers: []hypervisorMatcher{
+		{
+			name:    "qemu",
+			pattern: `^\S*/qemu\S* .*-name.*{{ID}}.*-qmp.*unix:.*/{{ID}}/.*`,
+		},
+		{
+			name:    "firecracker",
+			pattern: `^(\S*/)?firecracker .*{{ID}}.*`,
+		},
+		{
+			name:    "cloud-hypervisor",
+			pattern: `^(\S*/)?cloud-hypervisor .*{{ID}}.*`,
+		},
+	},
+}
+
+// RegisterHypervisorMatcher adds a hypervisor to the list of hypervisors
+// looked for by IsVMRunning and FindVMProcesses, pattern is a regular
+// expression of the hypervisor command line where {{ID}} stands for
+// the container ID, a matcher with the same name is replaced
+func RegisterHypervisorMatcher(name, pattern string) error {
+	if _, err := compileHypervisorPattern(pattern, "id"); err != nil {
+		return fmt.Errorf("invalid pattern for hypervisor %s: %v", name, err)
+	}
+
+	hypervisorMatchers.Lock()
+	defer hypervisorMatchers.Unlock()
+
+	matcher := hypervisorMatcher{
+		name:    name,
+		pattern: pattern,
+	}
+
+	for i, m := range hypervisorMatchers.matchers {
+		if m.name == name {
+			hypervisorMatchers.matchers[i] = matcher
+			return nil
 		}
+	}
+
+	hypervisorMatchers.matchers = append(hypervisorMatchers.matchers, matcher)
+
+	return nil
+}
+
+func compileHypervisorPattern(pattern, containerID string) (*regexp.Regexp, error) {
+	return regexp.Compile(strings.Replace(pattern, containerIDPlaceholder, regexp.QuoteMeta(containerID), -1))
+}
+
+// FindVMProcesses looks in /proc for hypervisor processes that
+// contain the containerID in their command line
+func FindVMProcesses(containerID string) ([]HypervisorProcess, error) {
+	processes, err := listProcesses()
+	if err != nil {
+		return nil, err
+	}
 
-		content, err := ioutil.ReadFile(filepath.Join(path, "cmdline"))
+	return matchVMProcesses(processes, containerID)
+}
+
+// matchVMProcesses returns the processes that are hypervisors
+// running the container containerID
+func matchVMProcesses(processes []ProcessInfo, containerID string) ([]HypervisorProcess, error) {
+	hypervisorMatchers.Lock()
+	matchers := append([]hypervisorMatcher{}, hypervisorMatchers.matchers...)
+	hypervisorMatchers.Unlock()
+
+	if hypervisorRegex != "" {
+		matchers = append(matchers, hypervisorMatcher{
+			name:    "custom",
+			pattern: hypervisorRegex,
+		})
+	}
+
+	var regexps []*regexp.Regexp
+	for _, m := range matchers {
+		re, err := compileHypervisorPattern(m.pattern, containerID)
 		if err != nil {
-			return filepath.SkipDir
+			return nil, fmt.Errorf("invalid pattern for hypervisor %s: %v", m.name, err)
 		}
+		regexps = append(regexps, re)
+	}
 
-		hypervisorRegexs := []string{".*/qemu.*-name.*" + containerID + ".*-qmp.*unix:.*/" + containerID + "/.*"}
+	var found []HypervisorProcess
 
-		for _, regex := range hypervisorRegexs {
-			matcher := regexp.MustCompile(regex)
-			if matcher.MatchString(string(content)) {
-				return errFound
+	for _, p := range processes {
+		for i, re := range regexps {
+			if re.MatchString(p.Cmdline) {
+				found = append(found, HypervisorProcess{
+					ProcessInfo: p,
+					Hypervisor:  matchers[i].name,
+				})
+				break
 			}
 		}
+	}
+
+	return found, nil
+}
+
+// IsVMRunning looks in /proc for a hypervisor process that contains
+// the containerID in its command line
+func IsVMRunning(containerID string) bool {
+	processes, err := FindVMProcesses(containerID)
+	if err != nil {
+		LogIfFail("could not look for hypervisor processes: %v\n", err)
+		return false
+	}
 
-		return nil
-	})
+	for _, p := range processes {
+		LogIfFail("%s process %d running container %s: %s\n", p.Hypervisor, p.PID, containerID, p.Cmdline)
+	}
 
-	return err == errFound
+	return len(processes) > 0
 }
@@ -0,0 +1,106 @@
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tests
+
+import (
+	"os/exec"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatchVMProcesses(t *testing.T) {
+	const id = "4ba2d1c3"
+
+	defer func(regex string) { hypervisorRegex = regex }(hypervisorRegex)
+	hypervisorRegex = `^/opt/my-vmm --vm {{ID}}$`
+
+	tests := []struct {
+		cmdline    string
+		hypervisor string
+	}{
+		{"/usr/bin/qemu-lite-system-x86_64 -name pod-" + id + " -qmp unix:/run/virtcontainers/pods/" + id + "/ctl,server", "qemu"},
+		{"/usr/bin/qemu-system-x86_64 -name " + id + " -qmp unix:/run/vc/" + id + "/mon,server", "qemu"},
+		{"/usr/bin/firecracker --api-sock /run/fc/" + id + ".sock", "firecracker"},
+		{"firecracker --id " + id, "firecracker"},
+		{"/usr/bin/cloud-hypervisor --api-socket /run/ch/" + id + "/api.sock", "cloud-hypervisor"},
+		{"/opt/my-vmm --vm " + id, "custom"},
+
+		// other containers and other processes
+		{"/usr/bin/qemu-system-x86_64 -name 5ca3e2d4 -qmp unix:/run/vc/5ca3e2d4/mon,server", ""},
+		{"/usr/bin/firecracker --api-sock /run/fc/5ca3e2d4.sock", ""},
+		{"/usr/bin/qemu-system-x86_64 -name " + id, ""},
+		{"/opt/my-vmm --vm " + id + " --debug", ""},
+		{"/usr/bin/cc-shim -c " + id, ""},
+		{"grep firecracker " + id, ""},
+	}
+
+	for _, test := range tests {
+		processes, err := matchVMProcesses([]ProcessInfo{{PID: 42, Cmdline: test.cmdline}}, id)
+		assert.NoError(t, err)
+
+		if test.hypervisor == "" {
+			assert.Empty(t, processes, test.cmdline)
+			continue
+		}
+
+		if assert.Len(t, processes, 1, test.cmdline) {
+			assert.Equal(t, test.hypervisor, processes[0].Hypervisor, test.cmdline)
+			assert.Equal(t, 42, processes[0].PID)
+		}
+	}
+}
+
+func TestRegisterHypervisorMatcher(t *testing.T) {
+	assert := assert.New(t)
+
+	defer func(matchers []hypervisorMatcher) {
+		hypervisorMatchers.matchers = matchers
+	}(append([]hypervisorMatcher{}, hypervisorMatchers.matchers...))
+
+	assert.Error(RegisterHypervisorMatcher("bad", "("))
+	assert.NoError(RegisterHypervisorMatcher("sleep", `^sleep-{{ID}} 30$`))
+
+	// the container ID is in the name of the process
+	id := RandID(20)
+	cmd := exec.Command("sleep", "30")
+	cmd.Args[0] = "sleep-" + id
+	assert.NoError(cmd.Start())
+	defer cmd.Wait()
+	defer cmd.Process.Kill()
+
+	processes, err := FindVMProcesses(id)
+	assert.NoError(err)
+	if assert.Len(processes, 1) {
+		assert.Equal("sleep", processes[0].Hypervisor)
+		assert.Equal(cmd.Process.Pid, processes[0].PID)
+	}
+
+	assert.True(IsVMRunning(id))
+	assert.False(IsVMRunning(RandID(20)))
+}
+
+func TestHypervisorRegexValue(t *testing.T) {
+	assert := assert.New(t)
+
+	var pattern string
+	value := hypervisorRegexValue{&pattern}
+
+	assert.Error(value.Set(".*{{ID}}(.*"))
+	assert.Equal("", pattern)
+
+	assert.NoError(value.Set(".*/my-vmm .*{{ID}}.*"))
+	assert.Equal(".*/my-vmm .*{{ID}}.*", value.String())
+}